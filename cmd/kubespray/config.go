@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate the CLI configuration",
+		Long: `Inspect and validate the CLI configuration.
+
+The configuration is built from layers, each overriding the ones before it:
+
+  1. built-in defaults
+  2. the config file (--config, default $HOME/.kubespray.yaml)
+  3. the cluster profile $HOME/.kubespray/clusters/<name>.yaml
+     (--cluster, default $KUBESPRAY_CLUSTER)
+  4. environment variables named after the key, such as
+     KUBESPRAY_KUBERNETES_VERSION for kubernetes.version
+  5. --set key=value flags`,
+	}
+
+	cmd.AddCommand(newConfigValidateCmd())
+	cmd.AddCommand(newConfigViewCmd())
+	cmd.AddCommand(newConfigMigrateCmd())
+	cmd.AddCommand(newConfigSchemaCmd())
+
+	return cmd
+}
+
+func newConfigValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check every configuration value before it reaches a deployment",
+		Long: `Check every configuration value before it reaches a deployment.
+
+Unknown keys, enumerated settings, the Kubernetes version, subnets and
+addresses, ports, counts and paths are checked. Each problem is reported with
+the file, line and column of the offending value when it comes from the config
+file.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadWithOptions(configOptions())
+			if err != nil {
+				return err
+			}
+
+			source := cfg.File()
+			if source == "" {
+				source = "built-in defaults"
+			}
+
+			problems := cfg.Warnings()
+			err = cfg.Validate()
+			var verr *config.ValidationError
+			if errors.As(err, &verr) {
+				problems = append(problems, verr.Errors...)
+			} else if err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				for _, fe := range problems {
+					fmt.Println(fe)
+				}
+				return fmt.Errorf("configuration %s is invalid", source)
+			}
+
+			fmt.Printf("Configuration valid: %s\n", source)
+			return nil
+		},
+	}
+}
+
+func newConfigViewCmd() *cobra.Command {
+	var effective bool
+
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Print the merged configuration",
+		Long: `Print the merged configuration as YAML.
+
+With --effective every setting is listed with its value and the layer it came
+from: default, file, cluster, env or flag.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if !effective {
+				out, err := yaml.Marshal(cfg.Map())
+				if err != nil {
+					return fmt.Errorf("failed to encode config: %w", err)
+				}
+				fmt.Print(string(out))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+			for _, s := range cfg.Settings() {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, formatSetting(s.Value), s.Origin)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&effective, "effective", false, "list every setting with the layer that set it")
+
+	return cmd
+}
+
+// formatSetting renders a setting value on one line
+func formatSetting(v interface{}) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if s, ok := v.(string); ok && s != "" {
+		return s
+	}
+	return string(out)
+}
+
+func newConfigMigrateCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate [file]",
+		Short: "Upgrade a config file to the current apiVersion",
+		Long: fmt.Sprintf(`Upgrade a config file to the current apiVersion (%s).
+
+Older files are upgraded one schema version at a time and rewritten in place;
+the original is kept next to it with a .bak suffix. Comments are preserved.
+Without a file argument the cluster profile selected by --cluster, the file
+given with --config or $HOME/.kubespray.yaml is migrated.`, config.CurrentAPIVersion),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := migrateTarget(args)
+			if err != nil {
+				return err
+			}
+
+			if dryRun {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read config: %w", err)
+				}
+				out, applied, err := config.MigrateData(data)
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				printMigrations(path, applied)
+				if len(applied) > 0 {
+					fmt.Print(string(out))
+				}
+				return nil
+			}
+
+			applied, backup, err := config.MigrateFile(path)
+			if err != nil {
+				return err
+			}
+			printMigrations(path, applied)
+			if len(applied) > 0 {
+				fmt.Printf("Migrated %s to %s (backup: %s)\n", path, config.CurrentAPIVersion, backup)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrated file instead of writing it")
+
+	return cmd
+}
+
+// migrateTarget returns the config file config migrate upgrades
+func migrateTarget(args []string) (string, error) {
+	switch {
+	case len(args) > 0:
+		return args[0], nil
+	case cfgCluster != "":
+		return config.ClusterFile(cfgCluster)
+	case cfgFile != "":
+		return cfgFile, nil
+	}
+	if path := config.DefaultFile(); path != "" {
+		return path, nil
+	}
+	return "", fmt.Errorf("no config file to migrate: pass a file or use --config")
+}
+
+// printMigrations lists the migrations applied to a file
+func printMigrations(path string, applied []config.Migration) {
+	if len(applied) == 0 {
+		fmt.Printf("%s is already at %s\n", path, config.CurrentAPIVersion)
+		return
+	}
+	for _, m := range applied {
+		from := m.From
+		if from == "" {
+			from = "unversioned"
+		}
+		fmt.Printf("  %s -> %s: %s\n", from, m.To, m.Description)
+	}
+}
+
+func newConfigSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config file",
+		Long: `Print the JSON Schema of the config file, generated from the settings and
+their defaults. Point your editor at it for completion and validation, for
+example with the YAML language server:
+
+  kubespray config schema > ~/.kubespray.schema.json
+
+and as the first line of ~/.kubespray.yaml:
+
+  # yaml-language-server: $schema=./.kubespray.schema.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := json.MarshalIndent(config.Schema(), "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode schema: %w", err)
+			}
+			fmt.Println(string(out))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/deploy"
+	"github.com/vjranagit/kubespray/pkg/inventory"
+	"github.com/vjranagit/kubespray/pkg/preflight"
+)
+
+func newDeployCmd() *cobra.Command {
+	var (
+		flags         playbookFlags
+		masters       []string
+		nodes         []string
+		etcd          []string
+		networkPlugin string
+		limit         []string
+		tags          []string
+		resume        string
+		plan          bool
+		check         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "deploy",
+		Short: "Deploy a Kubernetes cluster with Kubespray",
+		Long: fmt.Sprintf(`Deploy a Kubernetes cluster with Kubespray.
+
+The Kubespray checkout at <kubespray_path>/src is prepared first: an existing
+checkout is used as it is unless --kubespray-version asks for another release,
+and %s is fetched when there is none. The inventory and its group_vars are
+then rendered from the config into <kubespray_path>/inventory/<cluster_name>
+(or --inventory is used as it is), the config and inventory are validated and
+ansible-playbook cluster.yml runs with the ssh settings of the config.
+
+With --output compact, the default, the plays, the running task and failures
+are shown as they happen and the full playbook output is written to a log
+file under <kubespray_path>/logs. --output full streams the playbook output.
+
+The state of every run is saved under <kubespray_path>/runs as it progresses.
+--resume <run-id> resumes a run that failed with the same inventory, Kubespray
+commit, tags and extra vars: the playbook starts at the task that failed and
+is limited to the hosts that failed, or to --limit when given. Extra vars
+whose names look like secrets (containing pass, secret, token, credential,
+private_key, api_key or access_key) are not saved with the run, so they have
+to be given again with -e when resuming.
+
+--plan shows what a deploy would do without changing any host: the resolved
+config, the rendered inventory, the pod and service subnets, the inventory
+and group_vars changes since the last deploy, and the playbook, tags and hosts
+of the run. --check also runs the playbook in Ansible check mode, which
+reports the changes the deploy would make on the hosts.`, deploy.DefaultVersion),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if networkPlugin != "" {
+				cfg.Kubernetes.NetworkPlugin = networkPlugin
+			}
+
+			runner, opts, err := flags.setup(cfg)
+			if err != nil {
+				return err
+			}
+			opts.Limit, opts.Tags = limit, tags
+			if resume != "" {
+				resumed, err := resumeOptions(cfg, resume, limit, opts.ExtraVars)
+				if err != nil {
+					return err
+				}
+				resumed.Repository, resumed.Verbosity, resumed.RunID = opts.Repository, opts.Verbosity, opts.RunID
+				opts = resumed
+				fmt.Printf("Resuming run %s at task %q on %s\n", resume, opts.StartAtTask, describeLimit(opts.Limit))
+			}
+			if len(masters) > 0 || len(nodes) > 0 || len(etcd) > 0 {
+				runner.WithInventory(&inventory.Inventory{Masters: masters, Nodes: nodes, Etcd: etcd})
+			}
+			if plan || check {
+				return planDeploy(cfg, &flags, runner, opts, check)
+			}
+
+			_, err = flags.run(cfg, runner, opts.RunID, func(ctx context.Context) (*deploy.Result, error) {
+				return runner.Run(ctx, opts)
+			})
+			return err
+		},
+	}
+
+	addPlaybookFlags(cmd, &flags)
+	cmd.Flags().StringSliceVar(&masters, "masters", nil, "comma-separated list of control plane IPs (default: from config)")
+	cmd.Flags().StringSliceVar(&nodes, "nodes", nil, "comma-separated list of worker IPs (default: from config)")
+	cmd.Flags().StringSliceVar(&etcd, "etcd", nil, "comma-separated list of etcd IPs (default: masters)")
+	cmd.MarkFlagsMutuallyExclusive("inventory", "masters")
+	cmd.MarkFlagsMutuallyExclusive("inventory", "nodes")
+	cmd.MarkFlagsMutuallyExclusive("inventory", "etcd")
+	cmd.Flags().StringVar(&networkPlugin, "network-plugin", "", "CNI plugin (default: from config)")
+	cmd.Flags().StringSliceVar(&limit, "limit", nil, "comma-separated hosts or groups to limit the run to")
+	cmd.Flags().StringSliceVar(&tags, "tags", nil, "comma-separated tags of the tasks to run")
+	cmd.Flags().StringVar(&resume, "resume", "", "resume the failed run with this id")
+	cmd.Flags().BoolVar(&plan, "plan", false, "show what the deploy would do without changing any host")
+	cmd.Flags().BoolVar(&check, "check", false, "show the plan and run the playbook in check mode")
+	for _, flag := range []string{"inventory", "masters", "nodes", "etcd", "kubespray-version", "tags"} {
+		cmd.MarkFlagsMutuallyExclusive("resume", flag)
+	}
+
+	return cmd
+}
+
+// playbookFlags are the flags of the commands that run a Kubespray playbook
+type playbookFlags struct {
+	inventoryPath string
+	sshUser       string
+	sshKey        string
+	version       string
+	repository    string
+	extraVars     []string
+	verbosity     int
+	output        string
+	logPath       string
+}
+
+func addPlaybookFlags(cmd *cobra.Command, f *playbookFlags) {
+	cmd.Flags().StringVarP(&f.inventoryPath, "inventory", "i", "", "existing inventory file or directory to use instead of rendering one from the config")
+	cmd.Flags().StringVar(&f.sshUser, "ssh-user", "", "SSH user (default from config)")
+	cmd.Flags().StringVar(&f.sshKey, "ssh-key", "", "SSH private key path (default from config)")
+	cmd.Flags().StringVar(&f.version, "kubespray-version", "", "Kubespray tag, branch or commit to check out (default: the existing checkout, or "+deploy.DefaultVersion+")")
+	cmd.Flags().StringVar(&f.repository, "kubespray-repo", deploy.DefaultRepository, "Kubespray git repository")
+	cmd.Flags().StringArrayVarP(&f.extraVars, "extra-var", "e", nil, "extra variable for the playbook, as key=value (repeatable)")
+	cmd.Flags().CountVarP(&f.verbosity, "verbose", "v", "increase ansible-playbook verbosity (repeatable)")
+	cmd.Flags().StringVarP(&f.output, "output", "o", outputCompact, "playbook output: compact or full")
+	cmd.Flags().StringVar(&f.logPath, "log", "", "file the playbook output is written to with --output compact (default: <kubespray_path>/logs/<run-id>.log)")
+}
+
+// setup applies the ssh flags to cfg and returns a runner for it with the
+// options of a new run
+func (f *playbookFlags) setup(cfg *config.Config) (*deploy.Runner, deploy.Options, error) {
+	if f.sshUser != "" {
+		cfg.SSH.User = f.sshUser
+	}
+	if f.sshKey != "" {
+		cfg.SSH.KeyPath = f.sshKey
+	}
+	if f.output != outputCompact && f.output != outputFull {
+		return nil, deploy.Options{}, fmt.Errorf("invalid output %q: must be %s or %s", f.output, outputCompact, outputFull)
+	}
+	vars, err := parseExtraVars(f.extraVars)
+	if err != nil {
+		return nil, deploy.Options{}, err
+	}
+
+	runner := deploy.NewRunner(cfg)
+	if cfg.Inventory.Hostnames.Source == inventory.HostnameSSH {
+		runner.WithResolver(preflight.NewChecker(nil, cfg.SSH.User, cfg.SSH.KeyPath, cfg.SSH.Port))
+	}
+	return runner, deploy.Options{
+		InventoryPath: f.inventoryPath,
+		Repository:    f.repository,
+		Version:       f.version,
+		ExtraVars:     vars,
+		Verbosity:     f.verbosity,
+		RunID:         deploy.NewRunID(cfg.Inventory.ClusterName, time.Now()),
+	}, nil
+}
+
+// run runs a playbook with op, showing its output as the flags ask, and
+// prints the result. The run is interrupted by SIGINT or SIGTERM.
+func (f *playbookFlags) run(cfg *config.Config, runner *deploy.Runner, runID string, op func(ctx context.Context) (*deploy.Result, error)) (*deploy.Result, error) {
+	if f.output == outputCompact {
+		logPath := f.logPath
+		if logPath == "" {
+			logPath = filepath.Join(cfg.KubesprayPath, "logs", runID+".log")
+		}
+		log, err := createLog(logPath)
+		if err != nil {
+			return nil, err
+		}
+		defer log.Close()
+		fmt.Printf("Playbook output is logged to %s\n", logPath)
+		runner.WithLog(log).WithEvents(newProgress(os.Stdout).event)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := op(ctx)
+	if result != nil {
+		printResult(result)
+		if result.ExitCode != 0 && !result.Check {
+			printResumeHint(cfg.Cluster(), result.RunID)
+		}
+	}
+	return result, err
+}
+
+// parseExtraVars parses key=value playbook variables. Values are read as
+// YAML, so numbers, booleans and lists keep their type.
+func parseExtraVars(vars []string) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(vars))
+	for _, v := range vars {
+		key, raw, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid extra variable %q: expected key=value", v)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+			value = raw
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// resumeOptions returns the options resuming run id. --limit replaces the
+// failed hosts and extra vars are added to those of the run.
+func resumeOptions(cfg *config.Config, id string, limit []string, vars map[string]interface{}) (deploy.Options, error) {
+	state, err := deploy.LoadRunState(cfg, id)
+	if err != nil {
+		return deploy.Options{}, err
+	}
+	opts, err := state.ResumeOptions(vars)
+	if err != nil {
+		return deploy.Options{}, err
+	}
+	if len(limit) > 0 {
+		opts.Limit = limit
+	}
+	return opts, nil
+}
+
+// describeLimit names the hosts a run is limited to
+func describeLimit(limit []string) string {
+	if len(limit) == 0 {
+		return "all hosts"
+	}
+	return strings.Join(limit, ", ")
+}
+
+// printResumeHint prints the command resuming a failed run
+func printResumeHint(cluster, id string) {
+	resume := "kubespray deploy"
+	if cluster != "" {
+		resume += " --cluster " + cluster
+	}
+	fmt.Printf("\nResume with: %s --resume %s\n", resume, id)
+}
+
+// createLog creates the playbook log file and its directory
+func createLog(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log: %w", err)
+	}
+	return f, nil
+}
+
+// printResult prints the per-host summary of a playbook run
+func printResult(result *deploy.Result) {
+	fmt.Println()
+	if len(result.Hosts) > 0 {
+		names := make([]string, 0, len(result.Hosts))
+		for name := range result.Hosts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tOK\tCHANGED\tFAILED\tUNREACHABLE")
+		for _, name := range names {
+			s := result.Hosts[name]
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", name, s.OK, s.Changed, s.Failed, s.Unreachable)
+		}
+		w.Flush()
+	}
+
+	status := "succeeded"
+	if result.ExitCode != 0 {
+		status = fmt.Sprintf("failed (exit status %d)", result.ExitCode)
+	}
+	fmt.Printf("%s %s in %s\n", result.Playbook, status, result.Duration().Round(time.Second))
+	if failed := result.FailedHosts(); len(failed) > 0 {
+		fmt.Printf("Failed hosts: %s\n", strings.Join(failed, ", "))
+	}
+	printFailures(result.Failures)
+}
+
+// printFailures prints the failed tasks of every host, hosts in name order
+func printFailures(failures []deploy.Event) {
+	if len(failures) == 0 {
+		return
+	}
+	byHost := map[string][]deploy.Event{}
+	for _, f := range failures {
+		byHost[f.Host] = append(byHost[f.Host], f)
+	}
+	hosts := make([]string, 0, len(byHost))
+	for host := range byHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	fmt.Println("\nFailures:")
+	for _, host := range hosts {
+		fmt.Printf("  %s\n", host)
+		for _, f := range byHost[host] {
+			task := f.Task
+			if f.Item != "" {
+				task += fmt.Sprintf(" (item=%s)", f.Item)
+			}
+			fmt.Printf("    %s [%s]", f.Status, task)
+			if f.Message != "" {
+				fmt.Printf(": %s", f.Message)
+			}
+			fmt.Println()
+		}
+	}
+}
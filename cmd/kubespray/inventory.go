@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/inventory"
+	"github.com/vjranagit/kubespray/pkg/preflight"
+)
+
+func newInventoryCmd() *cobra.Command {
+	var (
+		list bool
+		host string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "inventory",
+		Short: "Generate and validate Ansible inventories",
+		Long: `Generate and validate Ansible inventories.
+
+With --list or --host the command acts as an Ansible dynamic inventory script,
+printing the hosts of the config's inventory section as JSON.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !list && host == "" {
+				return cmd.Help()
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			gen := newGenerator(cfg)
+			inv := inventory.FromConfig(cfg)
+
+			var data []byte
+			if list {
+				data, err = gen.List(inv)
+			} else {
+				data, err = gen.HostVars(inv, host)
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&list, "list", false, "print all groups and host variables as dynamic inventory JSON")
+	cmd.Flags().StringVar(&host, "host", "", "print the variables of one host as JSON")
+	cmd.MarkFlagsMutuallyExclusive("list", "host")
+
+	cmd.AddCommand(newInventoryGenerateCmd())
+	cmd.AddCommand(newInventoryValidateCmd())
+	cmd.AddCommand(newInventoryAddNodeCmd())
+	cmd.AddCommand(newInventoryRemoveNodeCmd())
+	cmd.AddCommand(newInventoryImportCmd())
+
+	return cmd
+}
+
+func newInventoryGenerateCmd() *cobra.Command {
+	var (
+		masters []string
+		nodes   []string
+		etcd    []string
+		output  string
+		dir     string
+		format  string
+		cluster string
+		source  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a Kubespray inventory from host addresses",
+		Long: `Generate a Kubespray inventory from host addresses.
+
+By default a single inventory file is written. With --dir a complete inventory
+directory is rendered instead: the hosts file plus group_vars/all,
+group_vars/k8s_cluster/k8s-cluster.yml and addons.yml filled from the config.
+Re-rendering updates the variables marked "managed by kubespray" and keeps
+everything else, so remove the marker from a variable to override it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if format == "" {
+				format = inventory.DetectFormat(output)
+				if dir != "" {
+					format = inventory.FormatYAML
+				}
+			}
+			if cluster != "" {
+				cfg.Inventory.ClusterName = cluster
+			}
+			if source != "" {
+				cfg.Inventory.Hostnames.Source = source
+			}
+
+			// Addresses given on the command line replace those in the config
+			inv := inventory.FromConfig(cfg)
+			if len(masters) > 0 || len(nodes) > 0 || len(etcd) > 0 {
+				inv = &inventory.Inventory{
+					Masters: masters,
+					Nodes:   nodes,
+					Etcd:    etcd,
+				}
+			}
+			if dir != "" {
+				if err := newGenerator(cfg).GenerateDir(inv, dir, format); err != nil {
+					return err
+				}
+				fmt.Printf("Inventory rendered to %s\n", dir)
+				return nil
+			}
+
+			if err := newGenerator(cfg).GenerateFormat(inv, output, format); err != nil {
+				return err
+			}
+
+			fmt.Printf("Inventory written to %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&masters, "masters", nil, "comma-separated list of control plane IPs (default: from config)")
+	cmd.Flags().StringSliceVar(&nodes, "nodes", nil, "comma-separated list of worker IPs (default: from config)")
+	cmd.Flags().StringSliceVar(&etcd, "etcd", nil, "comma-separated list of etcd IPs (default: masters)")
+	cmd.Flags().StringVarP(&output, "output", "o", "inventory/hosts.ini", "inventory file to write")
+	cmd.Flags().StringVar(&dir, "dir", "", "render an inventory directory with group_vars instead of a single file")
+	cmd.Flags().StringVar(&format, "format", "", "inventory format: ini or yaml (default: from output extension, yaml with --dir)")
+	cmd.MarkFlagsMutuallyExclusive("output", "dir")
+	cmd.Flags().StringVar(&cluster, "cluster-name", "", "cluster name used in hostname templates (default: from config)")
+	cmd.Flags().StringVar(&source, "hostname-source", "", "how hosts are named: template, dns or ssh (default: from config)")
+
+	return cmd
+}
+
+func newInventoryImportCmd() *cobra.Command {
+	var (
+		from   string
+		rules  []string
+		output string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <source>",
+		Short: "Import an inventory from CSV, Terraform JSON or a Kubespray inventory directory",
+		Long: `Import an inventory from CSV, Terraform JSON or a Kubespray inventory directory.
+
+The source type is taken from --from or guessed from the path: a directory is
+a Kubespray inventory, a .csv file a CSV export and anything else Terraform
+state, show or output JSON.
+
+Role rules, written field=pattern:roles, assign roles to imported hosts. The
+first matching rule wins and a rule without roles leaves hosts out:
+
+  kubespray inventory import hosts.csv --role-rule 'name=*-cp-*:control-plane,etcd'
+  kubespray inventory import terraform.tfstate --role-rule 'tag.pool=gpu:worker'
+  kubespray inventory import inventory/prod --role-rule 'group=bastion:'`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			roleRules := make([]inventory.RoleRule, 0, len(rules))
+			for _, r := range rules {
+				rule, err := inventory.ParseRoleRule(r)
+				if err != nil {
+					return err
+				}
+				roleRules = append(roleRules, rule)
+			}
+
+			if from == "" {
+				from = inventory.DetectSource(args[0])
+			}
+			inv, err := inventory.Import(from, args[0], roleRules)
+			if err != nil {
+				return err
+			}
+
+			if format == "" {
+				format = inventory.DetectFormat(output)
+			}
+			if err := newGenerator(cfg).GenerateFormat(inv, output, format); err != nil {
+				return err
+			}
+
+			fmt.Printf("Imported %d hosts from %s into %s\n", len(inv.Hosts), args[0], output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "source type: csv, terraform or kubespray (default: from path)")
+	cmd.Flags().StringArrayVar(&rules, "role-rule", nil, "role rule as field=pattern:roles (repeatable)")
+	cmd.Flags().StringVarP(&output, "output", "o", "inventory/hosts.ini", "inventory file to write")
+	cmd.Flags().StringVar(&format, "format", "", "inventory format: ini or yaml (default: from output extension)")
+
+	return cmd
+}
+
+func newInventoryValidateCmd() *cobra.Command {
+	var (
+		disable   []string
+		listRules bool
+		online    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "validate <inventory>",
+		Short: "Validate an INI or YAML Kubespray inventory",
+		Long: `Validate an INI or YAML Kubespray inventory.
+
+With --online every host is also contacted over SSH, with the ansible_user,
+ansible_port and ansible_ssh_private_key_file the inventory sets for it or
+else the ssh section of the config, to check that its ansible_host is
+reachable, that the login succeeds, that its ip is configured on one of its
+interfaces and that its hostname matches its inventory name.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if listRules {
+				return nil
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if listRules {
+				for _, r := range inventory.Rules() {
+					desc := r.Description
+					if r.Online {
+						desc += " (--online)"
+					}
+					fmt.Printf("%-30s %s\n", r.ID, desc)
+				}
+				return nil
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			validator := inventory.NewValidator(cfg)
+			if err := validator.Disable(disable...); err != nil {
+				return err
+			}
+
+			inv, err := inventory.ParseFile(args[0])
+			if err != nil {
+				return err
+			}
+			findings := validator.ValidateInventory(inv)
+
+			if online {
+				validator.WithProber(preflight.NewChecker(nil, cfg.SSH.User, cfg.SSH.KeyPath, cfg.SSH.Port))
+				hostFindings, err := validator.ValidateOnline(inv)
+				if err != nil {
+					return err
+				}
+				findings = append(findings, hostFindings...)
+			}
+
+			for _, f := range findings {
+				fmt.Println(f)
+			}
+			if inventory.HasErrors(findings) {
+				return fmt.Errorf("inventory %s is invalid", args[0])
+			}
+
+			fmt.Printf("Inventory validation successful: %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&disable, "disable", nil, "comma-separated list of rule ids to skip")
+	cmd.Flags().BoolVar(&listRules, "list-rules", false, "list the validation rules and exit")
+	cmd.Flags().BoolVar(&online, "online", false, "also connect to every host over SSH and check it matches the inventory")
+
+	return cmd
+}
+
+func newInventoryAddNodeCmd() *cobra.Command {
+	var (
+		host   inventory.Host
+		labels []string
+		opts   editOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add-node <inventory>",
+		Short: "Add a host to an existing inventory",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			host.Labels, err = parseLabels(labels)
+			if err != nil {
+				return err
+			}
+
+			return editInventory(cfg, args[0], opts, func(data []byte, format string) ([]byte, error) {
+				return newGenerator(cfg).AddHost(data, format, host)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&host.Name, "name", "", "inventory hostname (default: from the hostname source)")
+	cmd.Flags().StringVar(&host.Address, "address", "", "address Ansible connects to")
+	cmd.Flags().StringVar(&host.IP, "ip", "", "internal IP (default: address)")
+	cmd.Flags().StringVar(&host.AccessIP, "access-ip", "", "IP other nodes reach the host on (default: ip)")
+	cmd.Flags().StringVar(&host.User, "user", "", "SSH user for this host")
+	cmd.Flags().StringSliceVar(&host.Roles, "role", []string{inventory.RoleNode}, "groups to add the host to: kube_control_plane, kube_node, etcd, calico_rr")
+	cmd.Flags().StringSliceVar(&labels, "label", nil, "node label as key=value (repeatable)")
+	cmd.Flags().StringSliceVar(&host.Taints, "taint", nil, "node taint as key[=value]:Effect (repeatable)")
+	addEditFlags(cmd, &opts)
+	cmd.MarkFlagRequired("address")
+
+	return cmd
+}
+
+func newInventoryRemoveNodeCmd() *cobra.Command {
+	var opts editOptions
+
+	cmd := &cobra.Command{
+		Use:   "remove-node <inventory> <name|address>",
+		Short: "Remove a host from an existing inventory",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			return editInventory(cfg, args[0], opts, func(data []byte, format string) ([]byte, error) {
+				return inventory.RemoveHost(data, format, args[1])
+			})
+		},
+	}
+
+	addEditFlags(cmd, &opts)
+
+	return cmd
+}
+
+// editOptions are the flags shared by commands that modify an inventory
+type editOptions struct {
+	yes     bool
+	dryRun  bool
+	disable []string
+}
+
+func addEditFlags(cmd *cobra.Command, opts *editOptions) {
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "write the change without asking for confirmation")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "show the diff without writing")
+	cmd.Flags().StringSliceVar(&opts.disable, "disable", nil, "comma-separated list of validation rule ids to skip")
+}
+
+// editInventory applies edit to the inventory at path, validates the
+// result, shows a unified diff and writes it once confirmed
+func editInventory(cfg *config.Config, path string, opts editOptions, edit func(data []byte, format string) ([]byte, error)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	format := inventory.DetectFormat(path)
+	edited, err := edit(data, format)
+	if err != nil {
+		return err
+	}
+
+	validator := inventory.NewValidator(cfg)
+	if err := validator.Disable(opts.disable...); err != nil {
+		return err
+	}
+	parsed, err := inventory.Parse(edited, format)
+	if err != nil {
+		return err
+	}
+	findings := validator.ValidateInventory(parsed)
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if inventory.HasErrors(findings) {
+		return fmt.Errorf("edited inventory is invalid; %s left unchanged", path)
+	}
+
+	fmt.Print(inventory.UnifiedDiff(path, path, data, edited))
+	if opts.dryRun {
+		return nil
+	}
+
+	if !opts.yes && !confirm(fmt.Sprintf("Write changes to %s?", path)) {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	if err := os.WriteFile(path, edited, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	fmt.Printf("Inventory written to %s\n", path)
+	return nil
+}
+
+// updateHostsFile applies edit to the hosts file at path and writes it back
+func updateHostsFile(path string, edit func(data []byte, format string) ([]byte, error)) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory: %w", err)
+	}
+	edited, err := edit(data, inventory.DetectFormat(path))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, edited, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	return nil
+}
+
+// confirm asks a yes or no question on stdin; anything but yes is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	a := strings.ToLower(strings.TrimSpace(answer))
+	return a == "y" || a == "yes"
+}
+
+// newGenerator creates an inventory generator, resolving hostnames over SSH
+// when the config asks for it
+func newGenerator(cfg *config.Config) *inventory.Generator {
+	gen := inventory.NewGenerator(cfg)
+	if cfg.Inventory.Hostnames.Source == inventory.HostnameSSH {
+		gen.WithResolver(preflight.NewChecker(nil, cfg.SSH.User, cfg.SSH.KeyPath, cfg.SSH.Port))
+	}
+	return gen
+}
+
+// parseLabels parses key=value node labels
+func parseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(labels))
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q: expected key=value", label)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+var (
+	cfgFile    string
+	cfgCluster string
+	cfgSet     []string
+	cfgStrict  bool
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "kubespray",
+	Short: "Deploy Kubernetes clusters with Kubespray",
+	Long: `kubespray is a CLI and offline deployment tool for Kubernetes clusters
+built on top of the Kubespray Ansible playbooks.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.kubespray.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgCluster, "cluster", "", "cluster profile from $HOME/.kubespray/clusters layered over the config file (default $KUBESPRAY_CLUSTER)")
+	rootCmd.PersistentFlags().StringArrayVar(&cfgSet, "set", nil, "override a config value, as key=value (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&cfgStrict, "strict", false, "reject unknown keys in config files instead of warning")
+
+	rootCmd.AddCommand(newDeployCmd())
+	rootCmd.AddCommand(newScaleCmd())
+	rootCmd.AddCommand(newRemoveNodeCmd())
+	rootCmd.AddCommand(newUpgradeCmd())
+	rootCmd.AddCommand(newResetCmd())
+	rootCmd.AddCommand(newValidateCmd())
+	rootCmd.AddCommand(newNetworkCmd())
+	rootCmd.AddCommand(newInventoryCmd())
+	rootCmd.AddCommand(newConfigCmd())
+}
+
+// loadConfig reads the configuration selected by the --config, --cluster,
+// --set and --strict flags. Unknown keys are reported on stderr.
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.LoadWithOptions(configOptions())
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	return cfg, nil
+}
+
+// configOptions returns the config layers selected by the global flags
+func configOptions() config.Options {
+	return config.Options{
+		File:    cfgFile,
+		Cluster: cfgCluster,
+		Set:     cfgSet,
+		Strict:  cfgStrict,
+	}
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
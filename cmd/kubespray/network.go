@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/vjranagit/kubespray/pkg/network"
+	"github.com/vjranagit/kubespray/pkg/preflight"
+)
+
+func newNetworkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "Plan and validate cluster networking",
+	}
+
+	cmd.AddCommand(newNetworkPlanCmd())
+	cmd.AddCommand(newNetworkEndpointCmd())
+	cmd.AddCommand(newNetworkMetalLBCmd())
+
+	return cmd
+}
+
+func newNetworkPlanCmd() *cobra.Command {
+	var (
+		podSubnet    string
+		nodePrefix   int
+		maxPods      int
+		nodes        int
+		plannedNodes int
+		plugin       string
+		nodeMTU      int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Compute per-node pod CIDR capacity",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if podSubnet == "" {
+				podSubnet = cfg.Kubernetes.PodSubnet
+			}
+			if nodePrefix == 0 {
+				nodePrefix = cfg.Kubernetes.NodePrefix
+			}
+			if maxPods == 0 {
+				maxPods = cfg.Kubernetes.MaxPods
+			}
+			if plugin == "" {
+				plugin = cfg.Kubernetes.NetworkPlugin
+			}
+
+			calc := network.NewCalculator()
+			plan, err := calc.PlanCapacity(network.CapacityInput{
+				PodSubnet:    podSubnet,
+				NodePrefix:   nodePrefix,
+				MaxPods:      maxPods,
+				Nodes:        nodes,
+				PlannedNodes: plannedNodes,
+			})
+			if err != nil {
+				return err
+			}
+
+			printCapacityPlan(plan)
+
+			cni, err := network.LookupCNI(plugin)
+			if err != nil {
+				return err
+			}
+			printCNIPlan(cni, nodeMTU)
+			if err := calc.ValidateCNI(plugin, podSubnet, cfg.Kubernetes.ServiceSubnet, nodeMTU); err != nil {
+				return err
+			}
+
+			if !plan.Fits() {
+				return fmt.Errorf("pod network capacity is insufficient")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&podSubnet, "pod-subnet", "", "pod subnet CIDR (default from config)")
+	cmd.Flags().IntVar(&nodePrefix, "node-prefix", 0, "per-node pod CIDR prefix length (default from config)")
+	cmd.Flags().IntVar(&maxPods, "max-pods", 0, "kubelet max pods per node (default from config)")
+	cmd.Flags().IntVar(&nodes, "nodes", 0, "number of nodes in the inventory")
+	cmd.Flags().IntVar(&plannedNodes, "planned-nodes", 0, "expected number of nodes after growth")
+	cmd.Flags().StringVar(&plugin, "network-plugin", "", "CNI network plugin (default from config)")
+	cmd.Flags().IntVar(&nodeMTU, "mtu", 1500, "MTU of the node network interface")
+
+	return cmd
+}
+
+func newNetworkEndpointCmd() *cobra.Command {
+	var (
+		masters []string
+		mode    string
+		address string
+		subnet  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "endpoint",
+		Short: "Validate the control plane API endpoint and print its Kubespray variables",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			ep := cfg.APIEndpoint
+			if mode != "" {
+				ep.Mode = mode
+			}
+			if address != "" {
+				ep.Address = address
+			}
+			if subnet != "" {
+				ep.Subnet = subnet
+			}
+
+			calc := network.NewCalculator()
+			if ep.Mode == network.EndpointKubeVIP && ep.Address == "" && ep.Subnet != "" {
+				vip, err := calc.AllocateVIP(ep.Subnet, masters)
+				if err != nil {
+					return err
+				}
+				ep.Address = vip
+				fmt.Printf("Allocated VIP %s from %s\n\n", vip, ep.Subnet)
+			}
+
+			if err := calc.ValidateAPIEndpoint(ep, masters); err != nil {
+				return err
+			}
+
+			out, err := yaml.Marshal(network.APIEndpointVars(ep))
+			if err != nil {
+				return fmt.Errorf("failed to render variables: %w", err)
+			}
+
+			fmt.Printf("# API endpoint mode: %s\n", ep.Mode)
+			fmt.Print(string(out))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&masters, "masters", nil, "comma-separated list of control plane IPs")
+	cmd.Flags().StringVar(&mode, "mode", "", "API endpoint mode: localhost, kube-vip or external (default from config)")
+	cmd.Flags().StringVar(&address, "address", "", "VIP or external load balancer address (default from config)")
+	cmd.Flags().StringVar(&subnet, "subnet", "", "node subnet the VIP must belong to (default from config)")
+
+	return cmd
+}
+
+func newNetworkMetalLBCmd() *cobra.Command {
+	var (
+		hosts       []string
+		poolRange   string
+		exclude     []string
+		checkRoutes bool
+		output      string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "metallb",
+		Short: "Allocate MetalLB LoadBalancer address pools",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if poolRange != "" {
+				cfg.MetalLB.Range = poolRange
+			}
+			cfg.MetalLB.Exclude = append(cfg.MetalLB.Exclude, exclude...)
+			if len(cfg.MetalLB.Pools) == 0 {
+				return fmt.Errorf("no MetalLB pools configured (metallb.pools)")
+			}
+
+			pools, err := network.NewCalculator().AllocatePools(cfg, hosts)
+			if err != nil {
+				return err
+			}
+
+			for _, pool := range pools {
+				fmt.Printf("%-16s %-8s %s (%d addresses)\n", pool.Name, pool.Protocol, pool.Range, pool.Range.Size())
+			}
+
+			if checkRoutes {
+				checker := preflight.NewChecker(hosts, cfg.SSH.User, cfg.SSH.KeyPath, cfg.SSH.Port)
+				routes, err := checker.GatherRoutes(context.Background())
+				if err != nil {
+					return err
+				}
+				for _, host := range hosts {
+					for _, pool := range pools {
+						if err := network.CheckPoolRoutes(pool, routes[host]); err != nil {
+							return fmt.Errorf("%s: %w", host, err)
+						}
+					}
+				}
+				fmt.Println("\n✓ Pools do not conflict with host routes")
+			}
+
+			out, err := yaml.Marshal(network.MetalLBVars(pools, cfg.MetalLB.Peers))
+			if err != nil {
+				return fmt.Errorf("failed to render variables: %w", err)
+			}
+
+			if output == "" {
+				fmt.Println()
+				fmt.Print(string(out))
+				return nil
+			}
+			if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+			if err := os.WriteFile(output, out, 0644); err != nil {
+				return fmt.Errorf("failed to write variables: %w", err)
+			}
+			fmt.Printf("\nMetalLB variables written to %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&hosts, "hosts", nil, "comma-separated list of node IPs to keep out of the pools")
+	cmd.Flags().StringVar(&poolRange, "range", "", "address range to allocate pools from (default from config)")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", nil, "additional addresses or ranges to skip, e.g. DHCP scopes")
+	cmd.Flags().BoolVar(&checkRoutes, "check-routes", false, "check pools against routes gathered from the hosts over SSH")
+	cmd.Flags().StringVar(&output, "output", "", "write the MetalLB variables to this file instead of stdout")
+
+	return cmd
+}
+
+// printCapacityPlan writes a capacity plan in human-readable form
+func printCapacityPlan(plan *network.CapacityPlan) {
+	fmt.Printf("Pod subnet:     %s\n", plan.PodSubnet)
+	fmt.Printf("Node prefix:    /%d\n", plan.NodePrefix)
+	fmt.Printf("Max nodes:      %d\n", plan.MaxNodes)
+	fmt.Printf("Pods per node:  %d\n", plan.PodsPerNode)
+	if plan.Nodes > 0 {
+		fmt.Printf("Nodes:          %d\n", plan.Nodes)
+	}
+	if plan.PlannedNodes > 0 {
+		fmt.Printf("Planned nodes:  %d\n", plan.PlannedNodes)
+	}
+
+	if plan.Fits() {
+		fmt.Println("\n✓ Capacity is sufficient")
+		return
+	}
+
+	fmt.Println()
+	for _, w := range plan.Warnings {
+		fmt.Printf("✗ %s\n", w)
+	}
+
+	if len(plan.Alternatives) == 0 {
+		fmt.Println("\nNo node prefix fits; consider a larger pod subnet")
+		return
+	}
+
+	fmt.Println("\nAlternative node prefixes:")
+	for _, opt := range plan.Alternatives {
+		fmt.Printf("  /%d: %d nodes, %d pods per node\n", opt.NodePrefix, opt.MaxNodes, opt.PodsPerNode)
+	}
+}
+
+// printCNIPlan writes the network plugin requirements
+func printCNIPlan(plugin network.CNIPlugin, nodeMTU int) {
+	fmt.Printf("\nNetwork plugin: %s\n", plugin.Name)
+	fmt.Printf("IP families:    %s\n", strings.Join(plugin.IPFamilies, ", "))
+	fmt.Printf("Encapsulation:  %d bytes (pod MTU %d)\n", plugin.EncapOverhead, plugin.PodMTU(nodeMTU))
+	if plugin.MinKernel != "" {
+		fmt.Printf("Min kernel:     %s\n", plugin.MinKernel)
+	}
+	if len(plugin.KernelModules) > 0 {
+		fmt.Printf("Kernel modules: %s\n", strings.Join(plugin.KernelModules, ", "))
+	}
+	if len(plugin.Ports) > 0 {
+		fmt.Println("Required ports:")
+		for _, p := range plugin.Ports {
+			fmt.Printf("  %-10s %s\n", p.String(), p.Description)
+		}
+	}
+}
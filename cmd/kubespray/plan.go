@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/deploy"
+	"github.com/vjranagit/kubespray/pkg/network"
+)
+
+// planDeploy prints what a deploy with opts would do and, with check, runs
+// the playbook in check mode against the planned inventory
+func planDeploy(cfg *config.Config, flags *playbookFlags, runner *deploy.Runner, opts deploy.Options, check bool) error {
+	plan, err := runner.Plan(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	defer plan.Close()
+
+	if err := printPlan(cfg, plan); err != nil {
+		return err
+	}
+	if !check {
+		return nil
+	}
+
+	fmt.Println("\nRunning the playbook in check mode; no host is changed")
+	opts.InventoryPath, opts.Check = plan.Inventory, true
+	_, err = flags.run(cfg, runner, opts.RunID, func(ctx context.Context) (*deploy.Result, error) {
+		return runner.Run(ctx, opts)
+	})
+	return err
+}
+
+// printPlan prints the resolved config, the inventory, the subnets, the
+// inventory changes and the playbook run of a plan
+func printPlan(cfg *config.Config, plan *deploy.Plan) error {
+	out, err := yaml.Marshal(cfg.Map())
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	fmt.Printf("Config:\n%s", indent(string(out)))
+
+	fmt.Printf("\nKubespray:\n  %s\n", plan.Checkout)
+	fmt.Printf("\nInventory:\n%s", indent(string(plan.HostsFile)))
+
+	fmt.Println("\nNetwork:")
+	if err := printSubnets(cfg, plan.Nodes); err != nil {
+		return err
+	}
+
+	fmt.Println("\nChanges since the last deploy:")
+	if len(plan.Changes) == 0 {
+		fmt.Println("  none")
+	}
+	for _, c := range plan.Changes {
+		fmt.Print(indent(c.Diff))
+	}
+
+	fmt.Println("\nPlaybook:")
+	fmt.Printf("  Playbook:  %s\n", plan.Playbook)
+	tags := "all"
+	if len(plan.Tags) > 0 {
+		tags = strings.Join(plan.Tags, ", ")
+	}
+	fmt.Printf("  Tags:      %s\n", tags)
+	if plan.StartAtTask != "" {
+		fmt.Printf("  Start at:  %s\n", plan.StartAtTask)
+	}
+	fmt.Printf("  Hosts:     %s (%d)\n", strings.Join(plan.Hosts, ", "), len(plan.Hosts))
+	if len(plan.ExtraVars) > 0 {
+		vars, err := yaml.Marshal(plan.ExtraVars)
+		if err != nil {
+			return fmt.Errorf("failed to encode extra vars: %w", err)
+		}
+		fmt.Printf("  Extra vars:\n%s", indent(indent(string(vars))))
+	}
+	fmt.Printf("  Command:   ansible-playbook %s\n", strings.Join(plan.Args, " "))
+	return nil
+}
+
+// printSubnets prints the pod and service subnets of the cluster and the
+// pod capacity of the nodes
+func printSubnets(cfg *config.Config, nodes int) error {
+	k8s := cfg.Kubernetes
+	calc := network.NewCalculator()
+	capacity, err := calc.PlanCapacity(network.CapacityInput{
+		PodSubnet:  k8s.PodSubnet,
+		NodePrefix: k8s.NodePrefix,
+		MaxPods:    k8s.MaxPods,
+		Nodes:      nodes,
+	})
+	if err != nil {
+		return err
+	}
+	apiIP, err := calc.KubernetesServiceIP(k8s.ServiceSubnet)
+	if err != nil {
+		return err
+	}
+	dnsIP, err := calc.ClusterDNSIP(k8s.ServiceSubnet)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("  Pod subnet:      %s, /%d per node\n", capacity.PodSubnet, capacity.NodePrefix)
+	fmt.Printf("  Capacity:        %d of %d nodes, %d pods per node\n", capacity.Nodes, capacity.MaxNodes, capacity.PodsPerNode)
+	fmt.Printf("  Service subnet:  %s\n", k8s.ServiceSubnet)
+	fmt.Printf("  API service IP:  %s\n", apiIP)
+	fmt.Printf("  Cluster DNS IP:  %s\n", dnsIP)
+	for _, w := range capacity.Warnings {
+		fmt.Printf("  ✗ %s\n", w)
+	}
+	return nil
+}
+
+// indent indents every line of s by two spaces
+func indent(s string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vjranagit/kubespray/pkg/deploy"
+)
+
+// Output modes of playbook runs
+const (
+	outputCompact = "compact"
+	outputFull    = "full"
+)
+
+// statusWidth bounds the task status line so it fits a terminal line
+const statusWidth = 78
+
+// progress prints a compact view of a playbook run from its events: the
+// plays, failures as they happen and, on a terminal, the running task on a
+// status line that is rewritten in place
+type progress struct {
+	out    io.Writer
+	tty    bool
+	tasks  int
+	status bool
+}
+
+func newProgress(f *os.File) *progress {
+	info, err := f.Stat()
+	return &progress{out: f, tty: err == nil && info.Mode()&os.ModeCharDevice != 0}
+}
+
+func (p *progress) event(e deploy.Event) {
+	switch e.Type {
+	case deploy.EventPlayStart:
+		p.clear()
+		fmt.Fprintf(p.out, "PLAY %s\n", e.Play)
+	case deploy.EventTaskStart:
+		p.tasks++
+		if p.tty {
+			line := fmt.Sprintf("  [%d] %s", p.tasks, e.Task)
+			if len(line) > statusWidth {
+				line = line[:statusWidth-3] + "..."
+			}
+			fmt.Fprintf(p.out, "\r\033[K%s", line)
+			p.status = true
+		}
+	case deploy.EventHostResult:
+		if e.Failed() {
+			p.clear()
+			fmt.Fprintf(p.out, "  %s\n", formatFailure(e))
+		}
+	case deploy.EventRecap:
+		p.clear()
+	}
+}
+
+// clear removes the status line
+func (p *progress) clear() {
+	if p.status {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.status = false
+	}
+}
+
+// formatFailure describes a failed host result on one line
+func formatFailure(e deploy.Event) string {
+	s := fmt.Sprintf("%s: [%s] %s", e.Status, e.Host, e.Task)
+	if e.Item != "" {
+		s += fmt.Sprintf(" (item=%s)", e.Item)
+	}
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
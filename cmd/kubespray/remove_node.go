@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/deploy"
+	"github.com/vjranagit/kubespray/pkg/inventory"
+)
+
+func newRemoveNodeCmd() *cobra.Command {
+	var (
+		flags  playbookFlags
+		remove deploy.RemoveOptions
+		yes    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "remove-node <name|address>...",
+		Short: "Drain and remove nodes from a cluster",
+		Long: `Drain nodes, remove them from the cluster and reset them with Kubespray
+remove-node.yml.
+
+Once the playbook succeeds the nodes are removed from the config file that
+holds the inventory, or from the hosts file of --inventory. Nodes that can no
+longer be reached are removed with --unreachable, which deletes them from the
+cluster without draining or resetting them.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			runner, opts, err := flags.setup(cfg)
+			if err != nil {
+				return err
+			}
+
+			hosts, err := lookupNodes(cfg, flags.inventoryPath, args)
+			if err != nil {
+				return err
+			}
+			described := make([]string, len(hosts))
+			names := make([]string, len(hosts))
+			for i, h := range hosts {
+				described[i] = fmt.Sprintf("%s (%s)", h.Name, h.Address)
+				names[i] = h.Name
+			}
+			if !yes && !confirm(fmt.Sprintf("Remove %s from cluster %s?", strings.Join(described, ", "), cfg.Inventory.ClusterName)) {
+				fmt.Println("Aborted")
+				return nil
+			}
+
+			_, err = flags.run(cfg, runner, opts.RunID, func(ctx context.Context) (*deploy.Result, error) {
+				return runner.RemoveNodes(ctx, names, remove, opts)
+			})
+			if err != nil {
+				return err
+			}
+			return removeNodes(cfg, flags.inventoryPath, hosts)
+		},
+	}
+
+	addPlaybookFlags(cmd, &flags)
+	cmd.Flags().DurationVar(&remove.DrainTimeout, "drain-timeout", 0, "how long to wait for a node to drain (default: Kubespray's 360s)")
+	cmd.Flags().DurationVar(&remove.DrainGracePeriod, "drain-grace-period", 0, "grace period of the pods evicted by the drain (default: Kubespray's 300s)")
+	cmd.Flags().BoolVar(&remove.Unreachable, "unreachable", false, "remove nodes that cannot be reached, without draining or resetting them")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "remove the nodes without asking for confirmation")
+
+	return cmd
+}
+
+// lookupNodes finds hosts given by name or address in the inventory of the
+// config, or in the inventory at path
+func lookupNodes(cfg *config.Config, path string, names []string) ([]inventory.Host, error) {
+	var hosts []inventory.Host
+	if path != "" {
+		hostsFile, err := deploy.HostsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		inv, err := inventory.ParseFile(hostsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range inv.HostOrder {
+			hosts = append(hosts, inventory.Host{Name: name, Address: inv.Address(name)})
+		}
+	} else {
+		var err error
+		hosts, err = newGenerator(cfg).Hosts(inventory.FromConfig(cfg))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	found := make([]inventory.Host, 0, len(names))
+	for _, name := range names {
+		h, ok := findHost(hosts, name)
+		if !ok {
+			return nil, fmt.Errorf("host %s not found in inventory", name)
+		}
+		found = append(found, h)
+	}
+	return found, nil
+}
+
+// findHost finds a host by name or address
+func findHost(hosts []inventory.Host, name string) (inventory.Host, bool) {
+	for _, h := range hosts {
+		if name == h.Name || name == h.Address || name == h.IP {
+			return h, true
+		}
+	}
+	return inventory.Host{}, false
+}
+
+// removeNodes removes hosts from the config file that holds the inventory,
+// or from the hosts file of the inventory at path
+func removeNodes(cfg *config.Config, path string, hosts []inventory.Host) error {
+	if path != "" {
+		hostsFile, err := deploy.HostsFile(path)
+		if err != nil {
+			return err
+		}
+		err = updateHostsFile(hostsFile, func(data []byte, format string) ([]byte, error) {
+			for _, h := range hosts {
+				if data, err = inventory.RemoveHost(data, format, h.Name); err != nil {
+					return nil, err
+				}
+			}
+			return data, nil
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d node(s) from %s\n", len(hosts), hostsFile)
+		return nil
+	}
+
+	file, err := cfg.FileFor(inventoryKeys...)
+	if err != nil {
+		return err
+	}
+	for _, h := range hosts {
+		found, err := config.RemoveInventoryHost(file, h.Name, h.Address)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%s is not listed in %s; remove it from the inventory by hand", h.Name, file)
+		}
+	}
+	fmt.Printf("Removed %d node(s) from %s\n", len(hosts), file)
+	return nil
+}
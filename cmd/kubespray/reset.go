@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vjranagit/kubespray/pkg/deploy"
+)
+
+func newResetCmd() *cobra.Command {
+	var (
+		flags playbookFlags
+		yes   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Remove Kubernetes from every host of a cluster",
+		Long: `Remove Kubernetes, etcd and all their data from every host of a cluster
+with Kubespray reset.yml. This destroys the cluster and cannot be undone.
+
+The cluster name has to be typed to confirm, unless --yes is given. The
+inventory is left as it is, so the cluster can be deployed again.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			runner, opts, err := flags.setup(cfg)
+			if err != nil {
+				return err
+			}
+
+			name := cfg.Inventory.ClusterName
+			if !yes {
+				fmt.Printf("This removes Kubernetes, etcd and all their data from every host of cluster %s.\n", name)
+				fmt.Print("Type the cluster name to continue: ")
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				if strings.TrimSpace(answer) != name {
+					fmt.Println("Aborted")
+					return nil
+				}
+			}
+
+			_, err = flags.run(cfg, runner, opts.RunID, func(ctx context.Context) (*deploy.Result, error) {
+				return runner.Reset(ctx, opts)
+			})
+			return err
+		},
+	}
+
+	addPlaybookFlags(cmd, &flags)
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "reset the cluster without asking for confirmation")
+
+	return cmd
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/deploy"
+	"github.com/vjranagit/kubespray/pkg/inventory"
+)
+
+// inventoryKeys are the settings listing the hosts of the cluster; changes
+// to the hosts are written to the config file that sets them
+var inventoryKeys = []string{"inventory.hosts", "inventory.nodes", "inventory.masters", "inventory.etcd"}
+
+func newScaleCmd() *cobra.Command {
+	var flags playbookFlags
+
+	cmd := &cobra.Command{
+		Use:   "scale <address>...",
+		Short: "Add worker nodes to a cluster",
+		Long: `Add worker nodes to a running cluster with Kubespray scale.yml.
+
+The addresses are added to inventory.nodes of the config file that holds the
+inventory, or to the hosts file of --inventory, and scale.yml runs limited to
+the new nodes. The nodes stay in the inventory when the run fails, so it can
+be resumed with deploy --resume.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			runner, opts, err := flags.setup(cfg)
+			if err != nil {
+				return err
+			}
+			for _, addr := range args {
+				if net.ParseIP(addr) == nil {
+					return fmt.Errorf("invalid IP address: %s", addr)
+				}
+			}
+
+			if flags.inventoryPath != "" {
+				err = addInventoryNodes(cfg, flags.inventoryPath, args)
+			} else {
+				err = addConfigNodes(cfg, args)
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = flags.run(cfg, runner, opts.RunID, func(ctx context.Context) (*deploy.Result, error) {
+				return runner.Scale(ctx, args, opts)
+			})
+			return err
+		},
+	}
+
+	addPlaybookFlags(cmd, &flags)
+
+	return cmd
+}
+
+// addConfigNodes adds worker addresses to the inventory of the config, and
+// to the config file that holds it
+func addConfigNodes(cfg *config.Config, addrs []string) error {
+	hosts, err := newGenerator(cfg).Hosts(inventory.FromConfig(cfg))
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		for _, h := range hosts {
+			if addr == h.Address || addr == h.IP || addr == h.AccessIP {
+				return fmt.Errorf("address %s is already used by host %s", addr, h.Name)
+			}
+		}
+	}
+
+	file, err := cfg.FileFor(inventoryKeys...)
+	if err != nil {
+		return err
+	}
+	if err := config.AddInventoryNodes(file, addrs...); err != nil {
+		return err
+	}
+	cfg.Inventory.Nodes = append(cfg.Inventory.Nodes, addrs...)
+	fmt.Printf("Added %d node(s) to %s\n", len(addrs), file)
+	return nil
+}
+
+// addInventoryNodes adds worker addresses to the hosts file of an inventory
+func addInventoryNodes(cfg *config.Config, path string, addrs []string) error {
+	hostsFile, err := deploy.HostsFile(path)
+	if err != nil {
+		return err
+	}
+	gen := newGenerator(cfg)
+	err = updateHostsFile(hostsFile, func(data []byte, format string) ([]byte, error) {
+		for _, addr := range addrs {
+			if data, err = gen.AddHost(data, format, inventory.Host{Address: addr, Roles: []string{inventory.RoleNode}}); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %d node(s) to %s\n", len(addrs), hostsFile)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/deploy"
+)
+
+func newUpgradeCmd() *cobra.Command {
+	var (
+		flags   playbookFlags
+		version string
+		serial  int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade the Kubernetes version of a cluster",
+		Long: `Upgrade a cluster to a new Kubernetes release with Kubespray
+upgrade-cluster.yml.
+
+kubernetes.version in the config is the version the cluster runs. The new
+version must be newer and at most one minor version ahead, as Kubernetes does
+not support skipping minor versions. Nodes are upgraded --serial at a time,
+one by default. Once the playbook succeeds kubernetes.version is updated in
+the config file that sets it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if err := deploy.ValidateUpgrade(cfg.Kubernetes.Version, version); err != nil {
+				return err
+			}
+			runner, opts, err := flags.setup(cfg)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Upgrading cluster %s from %s to %s, %d node(s) at a time\n", cfg.Inventory.ClusterName, cfg.Kubernetes.Version, version, serial)
+			_, err = flags.run(cfg, runner, opts.RunID, func(ctx context.Context) (*deploy.Result, error) {
+				return runner.Upgrade(ctx, version, serial, opts)
+			})
+			if err != nil {
+				return err
+			}
+
+			file, err := cfg.FileFor("kubernetes.version")
+			if err == nil {
+				err = config.SetFileValue(file, "kubernetes.version", version)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: set kubernetes.version to %s in the config: %v\n", version, err)
+				return nil
+			}
+			fmt.Printf("kubernetes.version set to %s in %s\n", version, file)
+			return nil
+		},
+	}
+
+	addPlaybookFlags(cmd, &flags)
+	cmd.Flags().StringVar(&version, "version", "", "Kubernetes version to upgrade to, such as v1.30.2")
+	cmd.Flags().IntVar(&serial, "serial", 1, "number of nodes upgraded at a time")
+	cmd.MarkFlagRequired("version")
+
+	return cmd
+}
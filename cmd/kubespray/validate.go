@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vjranagit/kubespray/pkg/preflight"
+)
+
+func newValidateCmd() *cobra.Command {
+	var (
+		hosts   []string
+		sshUser string
+		sshKey  string
+		sshPort int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Run preflight validation checks against cluster hosts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if len(hosts) == 0 {
+				return fmt.Errorf("at least one host is required (--hosts)")
+			}
+			if sshUser == "" {
+				sshUser = cfg.SSH.User
+			}
+			if sshKey == "" {
+				sshKey = cfg.SSH.KeyPath
+			}
+			if sshPort == 0 {
+				sshPort = cfg.SSH.Port
+			}
+
+			fmt.Println("Running preflight validation checks...")
+			fmt.Println()
+
+			checker := preflight.NewChecker(hosts, sshUser, sshKey, sshPort).WithConfig(cfg)
+			results, err := checker.RunAll(context.Background())
+			if err != nil {
+				return err
+			}
+
+			passed, failed := 0, 0
+			for _, r := range results {
+				mark := "✓"
+				if r.Passed {
+					passed++
+				} else {
+					mark = "✗"
+					failed++
+				}
+				fmt.Printf("%s %s: %s\n", mark, r.Name, r.Message)
+			}
+
+			fmt.Printf("\nSummary: %d passed, %d failed\n", passed, failed)
+			if failed > 0 {
+				return fmt.Errorf("%d preflight checks failed", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&hosts, "hosts", nil, "comma-separated list of hosts to validate")
+	cmd.Flags().StringVar(&sshUser, "ssh-user", "", "SSH user (default from config)")
+	cmd.Flags().StringVar(&sshKey, "ssh-key", "", "SSH private key path (default from config)")
+	cmd.Flags().IntVar(&sshPort, "ssh-port", 0, "SSH port (default from config)")
+
+	return cmd
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds the kubespray CLI configuration. Any string setting may be a
+// secret reference, resolved when the configuration is loaded; see
+// ResolveSecret. Fields tagged secret are redacted by Settings and Map.
+type Config struct {
+	// APIVersion is the schema version of the config file
+	APIVersion    string            `mapstructure:"apiVersion"`
+	LogLevel      string            `mapstructure:"log_level"`
+	KubesprayPath string            `mapstructure:"kubespray_path"`
+	Cloud         CloudConfig       `mapstructure:"cloud"`
+	Kubernetes    KubernetesConfig  `mapstructure:"kubernetes"`
+	Addons        AddonsConfig      `mapstructure:"addons"`
+	APIEndpoint   APIEndpointConfig `mapstructure:"api_endpoint"`
+	MetalLB       MetalLBConfig     `mapstructure:"metallb"`
+	Inventory     InventoryConfig   `mapstructure:"inventory"`
+	SSH           SSHConfig         `mapstructure:"ssh"`
+	Offline       OfflineConfig     `mapstructure:"offline"`
+
+	// file is the global config file the values were read from, if any
+	file string
+	// cluster is the name of the cluster profile layered over file
+	cluster string
+	// origins records the layer that set each setting
+	origins map[string]Origin
+	// secrets holds the secret reference each resolved setting was read from
+	secrets map[string]string
+	// warnings lists the unknown keys of the config files
+	warnings []FieldError
+}
+
+// CloudConfig holds cloud provider defaults
+type CloudConfig struct {
+	AWS       AWSConfig       `mapstructure:"aws"`
+	GCP       GCPConfig       `mapstructure:"gcp"`
+	OpenStack OpenStackConfig `mapstructure:"openstack"`
+}
+
+// AWSConfig holds AWS settings
+type AWSConfig struct {
+	Region          string `mapstructure:"region"`
+	InstanceType    string `mapstructure:"instance_type"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key" secret:"true"`
+}
+
+// GCPConfig holds GCP settings
+type GCPConfig struct {
+	Project     string `mapstructure:"project"`
+	Zone        string `mapstructure:"zone"`
+	MachineType string `mapstructure:"machine_type"`
+	// Credentials is the service account key JSON
+	Credentials string `mapstructure:"credentials" secret:"true"`
+}
+
+// OpenStackConfig holds OpenStack settings
+type OpenStackConfig struct {
+	Region   string `mapstructure:"region"`
+	Flavor   string `mapstructure:"flavor"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password" secret:"true"`
+}
+
+// KubernetesConfig holds cluster settings passed to Kubespray
+type KubernetesConfig struct {
+	Version       string `mapstructure:"version"`
+	NetworkPlugin string `mapstructure:"network_plugin"`
+	ServiceSubnet string `mapstructure:"service_subnet"`
+	PodSubnet     string `mapstructure:"pod_subnet"`
+	// NodePrefix is the per-node pod CIDR size (kube_network_node_prefix)
+	NodePrefix int `mapstructure:"node_prefix"`
+	// MaxPods is the kubelet pod limit per node (kubelet_max_pods)
+	MaxPods int `mapstructure:"max_pods"`
+}
+
+// AddonsConfig selects the Kubespray addons deployed with the cluster
+type AddonsConfig struct {
+	Helm                 bool `mapstructure:"helm"`
+	MetricsServer        bool `mapstructure:"metrics_server"`
+	IngressNginx         bool `mapstructure:"ingress_nginx"`
+	CertManager          bool `mapstructure:"cert_manager"`
+	LocalPathProvisioner bool `mapstructure:"local_path_provisioner"`
+	Dashboard            bool `mapstructure:"dashboard"`
+}
+
+// APIEndpointConfig describes how clients reach the Kubernetes API of an HA
+// control plane. Mode is one of "localhost" (per-node nginx/haproxy proxy),
+// "kube-vip" (virtual IP announced by kube-vip) or "external" (existing LB).
+type APIEndpointConfig struct {
+	Mode string `mapstructure:"mode"`
+	// LocalhostType selects the localhost proxy: nginx or haproxy
+	LocalhostType string `mapstructure:"localhost_type"`
+	// Address is the kube-vip VIP or the external load balancer address
+	Address string `mapstructure:"address"`
+	// Domain is the DNS name of the API endpoint, if any
+	Domain string `mapstructure:"domain"`
+	Port   int    `mapstructure:"port"`
+	// Interface is the network interface kube-vip announces the VIP on
+	Interface string `mapstructure:"interface"`
+	// Subnet is the node network the VIP must belong to
+	Subnet string `mapstructure:"subnet"`
+}
+
+// MetalLBConfig describes LoadBalancer address pools for bare-metal clusters
+type MetalLBConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Range is the address space pools are allocated from (CIDR or start-end)
+	Range string `mapstructure:"range"`
+	// Exclude lists addresses, CIDRs or ranges that must not be allocated,
+	// such as DHCP scopes or gateways
+	Exclude []string      `mapstructure:"exclude"`
+	Pools   []MetalLBPool `mapstructure:"pools"`
+	Peers   []MetalLBPeer `mapstructure:"peers"`
+}
+
+// MetalLBPool requests a pool of Size addresses announced over Protocol
+// (layer2 or bgp)
+type MetalLBPool struct {
+	Name     string `mapstructure:"name"`
+	Size     int    `mapstructure:"size"`
+	Protocol string `mapstructure:"protocol"`
+}
+
+// MetalLBPeer is a BGP router MetalLB speakers peer with
+type MetalLBPeer struct {
+	Name    string `mapstructure:"name"`
+	Address string `mapstructure:"address"`
+	ASN     int    `mapstructure:"asn"`
+	MyASN   int    `mapstructure:"my_asn"`
+}
+
+// InventoryConfig holds the cluster hosts and inventory generation settings.
+// Masters, Nodes and Etcd list host addresses by role; Hosts describes hosts
+// individually.
+type InventoryConfig struct {
+	// ClusterName is substituted for {{cluster}} in hostname templates
+	ClusterName string         `mapstructure:"cluster_name"`
+	Hostnames   HostnameConfig `mapstructure:"hostnames"`
+	Masters     []string       `mapstructure:"masters"`
+	Nodes       []string       `mapstructure:"nodes"`
+	Etcd        []string       `mapstructure:"etcd"`
+	Hosts       []HostConfig   `mapstructure:"hosts"`
+}
+
+// HostConfig describes a single cluster host. Roles are Kubespray group
+// names: kube_control_plane, kube_node, etcd or calico_rr.
+type HostConfig struct {
+	Name     string                 `mapstructure:"name"`
+	Address  string                 `mapstructure:"address"`
+	IP       string                 `mapstructure:"ip"`
+	AccessIP string                 `mapstructure:"access_ip"`
+	User     string                 `mapstructure:"user"`
+	Roles    []string               `mapstructure:"roles"`
+	Vars     map[string]interface{} `mapstructure:"vars"`
+	Labels   map[string]string      `mapstructure:"labels"`
+	Taints   []string               `mapstructure:"taints"`
+}
+
+// HostnameConfig selects how generated hosts are named. Source is one of
+// "template", "dns" (reverse DNS of the host address) or "ssh" (the hostname
+// reported by the host itself). The per-role templates are used by the
+// template source and accept {{cluster}}, {{index}}, {{number}} and {{ip}}.
+type HostnameConfig struct {
+	Source       string `mapstructure:"source"`
+	ControlPlane string `mapstructure:"control_plane"`
+	Node         string `mapstructure:"node"`
+	Etcd         string `mapstructure:"etcd"`
+}
+
+// SSHConfig holds SSH connection settings
+type SSHConfig struct {
+	User    string `mapstructure:"user"`
+	KeyPath string `mapstructure:"key_path"`
+	Port    int    `mapstructure:"port"`
+	// BecomePassword is the sudo password (ansible_become_password)
+	BecomePassword string `mapstructure:"become_password" secret:"true"`
+}
+
+// OfflineConfig holds offline deployment settings
+type OfflineConfig struct {
+	Workers    int            `mapstructure:"workers"`
+	RetryCount int            `mapstructure:"retry_count"`
+	Registry   RegistryConfig `mapstructure:"registry"`
+	Cache      CacheConfig    `mapstructure:"cache"`
+}
+
+// RegistryConfig holds local registry settings
+type RegistryConfig struct {
+	Port        int    `mapstructure:"port"`
+	StoragePath string `mapstructure:"storage_path"`
+	Username    string `mapstructure:"username"`
+	Password    string `mapstructure:"password" secret:"true"`
+}
+
+// CacheConfig holds download cache settings
+type CacheConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+}
+
+// NewConfig creates a configuration populated with defaults
+func NewConfig() *Config {
+	home, _ := os.UserHomeDir()
+
+	return &Config{
+		APIVersion:    CurrentAPIVersion,
+		LogLevel:      "info",
+		KubesprayPath: filepath.Join(home, ".kubespray"),
+		Cloud: CloudConfig{
+			AWS: AWSConfig{
+				Region:       "us-west-2",
+				InstanceType: "t3.medium",
+			},
+			GCP: GCPConfig{
+				Zone:        "us-central1-a",
+				MachineType: "n1-standard-2",
+			},
+		},
+		Kubernetes: KubernetesConfig{
+			Version:       "v1.29.0",
+			NetworkPlugin: "calico",
+			ServiceSubnet: "10.233.0.0/18",
+			PodSubnet:     "10.233.64.0/18",
+			NodePrefix:    24,
+			MaxPods:       110,
+		},
+		APIEndpoint: APIEndpointConfig{
+			Mode:          "localhost",
+			LocalhostType: "nginx",
+			Port:          6443,
+		},
+		Inventory: InventoryConfig{
+			ClusterName: "cluster",
+			Hostnames: HostnameConfig{
+				Source:       "template",
+				ControlPlane: "master-{{index}}",
+				Node:         "node-{{index}}",
+				Etcd:         "etcd-{{index}}",
+			},
+		},
+		SSH: SSHConfig{
+			User:    "root",
+			KeyPath: filepath.Join(home, ".ssh", "id_rsa"),
+			Port:    22,
+		},
+		Offline: OfflineConfig{
+			Workers:    20,
+			RetryCount: 3,
+			Registry: RegistryConfig{
+				Port:        5000,
+				StoragePath: "/var/lib/registry",
+			},
+			Cache: CacheConfig{
+				Enabled: true,
+				Path:    "/var/cache/kubespray",
+			},
+		},
+	}
+}
+
+// Load reads configuration from path, or ~/.kubespray.yaml when path is empty,
+// with KUBESPRAY_* environment overrides. Values not present in the file keep
+// their defaults.
+func Load(path string) (*Config, error) {
+	return LoadWithOptions(Options{File: path})
+}
+
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileFor returns the config file a change to the settings with the given
+// dotted keys is written to: the file that sets the first of them, else the
+// cluster profile in use, else the global config file. Settings from the
+// environment or --set cannot be written back.
+func (c *Config) FileFor(keys ...string) (string, error) {
+	for _, key := range keys {
+		switch o := c.Origin(key); o.Source {
+		case SourceFile, SourceCluster:
+			return o.Detail, nil
+		case SourceEnv, SourceFlag:
+			return "", fmt.Errorf("%s is set by %s; change it there", key, o)
+		}
+	}
+	if c.cluster != "" {
+		return ClusterFile(c.cluster)
+	}
+	if c.file != "" {
+		return c.file, nil
+	}
+	return "", fmt.Errorf("no config file to record %s in; create ~/.kubespray.yaml or pass --config", strings.Join(keys, ", "))
+}
+
+// EditFile applies edit to the document mapping of the config file at path
+// and writes the file back when edit reports a change. Comments are kept.
+func EditFile(path string, edit func(doc *yaml.Node) (bool, error)) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return false, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return false, fmt.Errorf("config %s is not a YAML mapping", path)
+	}
+
+	changed, err := edit(doc)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return false, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return false, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := replaceFile(path, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetFileValue sets the setting with the dotted key, such as
+// kubernetes.version, to a string value in the config file at path
+func SetFileValue(path, key, value string) error {
+	_, err := EditFile(path, func(doc *yaml.Node) (bool, error) {
+		parts := strings.Split(key, ".")
+		m := doc
+		for _, p := range parts[:len(parts)-1] {
+			m = childMapping(m, p)
+		}
+		n := mappingValue(m, parts[len(parts)-1])
+		if n == nil {
+			m.Content = append(m.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: parts[len(parts)-1]},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+			return true, nil
+		}
+		if n.Kind == yaml.ScalarNode && n.Value == value {
+			return false, nil
+		}
+		if n.Kind != yaml.ScalarNode {
+			n.Style = 0
+		}
+		n.Kind, n.Tag, n.Value, n.Content = yaml.ScalarNode, "!!str", value, nil
+		return true, nil
+	})
+	return err
+}
+
+// AddInventoryNodes appends worker addresses to inventory.nodes of the
+// config file at path, skipping those already listed
+func AddInventoryNodes(path string, addrs ...string) error {
+	_, err := EditFile(path, func(doc *yaml.Node) (bool, error) {
+		inv := childMapping(doc, "inventory")
+		nodes := mappingValue(inv, "nodes")
+		if nodes == nil || nodes.Kind != yaml.SequenceNode {
+			if nodes == nil {
+				nodes = &yaml.Node{}
+				inv.Content = append(inv.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "nodes"}, nodes)
+			}
+			*nodes = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+
+		changed := false
+		for _, addr := range addrs {
+			listed := false
+			for _, n := range nodes.Content {
+				listed = listed || n.Value == addr
+			}
+			if !listed {
+				nodes.Content = append(nodes.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: addr})
+				changed = true
+			}
+		}
+		// A list that was written inline stays readable one address per line.
+		// Its comment moves to the key, or it would follow the last item.
+		if changed && nodes.Style&yaml.FlowStyle != 0 {
+			nodes.Style &^= yaml.FlowStyle
+			if key := mappingKey(inv, "nodes"); key != nil && key.LineComment == "" {
+				key.LineComment, nodes.LineComment = nodes.LineComment, ""
+			}
+		}
+		return changed, nil
+	})
+	return err
+}
+
+// RemoveInventoryHost removes a host from the inventory section of the
+// config file at path: its address from the masters, nodes and etcd lists
+// and the hosts entries with its name or address. It reports whether the
+// host was found.
+func RemoveInventoryHost(path, name, address string) (bool, error) {
+	return EditFile(path, func(doc *yaml.Node) (bool, error) {
+		inv := mappingValue(doc, "inventory")
+		if inv == nil || inv.Kind != yaml.MappingNode {
+			return false, nil
+		}
+
+		removed := false
+		for _, key := range []string{"masters", "nodes", "etcd", "hosts"} {
+			list := mappingValue(inv, key)
+			if list == nil || list.Kind != yaml.SequenceNode {
+				continue
+			}
+			kept := list.Content[:0]
+			for _, n := range list.Content {
+				match := n.Kind == yaml.ScalarNode && n.Value == address
+				if n.Kind == yaml.MappingNode {
+					for _, field := range []string{"name", "address"} {
+						if v := mappingValue(n, field); v != nil && v.Value != "" && (v.Value == name || v.Value == address) {
+							match = true
+						}
+					}
+				}
+				if match {
+					removed = true
+					continue
+				}
+				kept = append(kept, n)
+			}
+			list.Content = kept
+			if len(kept) == 0 {
+				list.Style |= yaml.FlowStyle
+			}
+		}
+		return removed, nil
+	})
+}
+
+// mappingKey returns the key node of key in a mapping
+func mappingKey(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i]
+		}
+	}
+	return nil
+}
+
+// childMapping returns the mapping under key, adding it when missing
+func childMapping(m *yaml.Node, key string) *yaml.Node {
+	if n := mappingValue(m, key); n != nil {
+		if n.Kind != yaml.MappingNode {
+			*n = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		return n
+	}
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, n)
+	return n
+}
+
+// replaceFile writes data to path through a temporary file in the same
+// directory, so the file is never left half written
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
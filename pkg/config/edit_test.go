@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileFor(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+
+	global := filepath.Join(home, ".kubespray.yaml")
+	profile := filepath.Join(home, ".kubespray", "clusters", "prod.yaml")
+	writeFile(t, global, "kubernetes:\n  version: v1.29.3\n")
+	writeFile(t, profile, "inventory:\n  masters: [10.0.0.1]\n")
+	t.Setenv("KUBESPRAY_KUBERNETES_NETWORK_PLUGIN", "cilium")
+
+	cfg, err := LoadWithOptions(Options{Cluster: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keys      []string
+		expected  string
+		expectErr bool
+	}{
+		{[]string{"kubernetes.version"}, global, false},
+		{[]string{"inventory.hosts", "inventory.nodes", "inventory.masters"}, profile, false},
+		{[]string{"ssh.user"}, profile, false},
+		{[]string{"kubernetes.network_plugin"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := cfg.FileFor(tt.keys...)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("FileFor(%v): expected error but got nil", tt.keys)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("FileFor(%v) = %q, %v; want %q", tt.keys, got, err, tt.expected)
+		}
+	}
+
+	cfg, err = LoadWithOptions(Options{File: global})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cfg.FileFor("inventory.nodes"); err != nil || got != global {
+		t.Errorf("Expected the global file without a profile, got %q, %v", got, err)
+	}
+}
+
+func TestEditInventory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `apiVersion: kubespray/v1
+# The prod cluster
+inventory:
+  cluster_name: prod
+  masters: [10.0.0.1]
+  nodes: [10.0.0.2] # workers
+  hosts:
+    - name: gpu-0
+      address: 10.0.0.5
+      roles: [kube_node]
+kubernetes:
+  version: v1.29.3
+`)
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AddInventoryNodes(path, "10.0.0.2", "10.0.0.3", "10.0.0.4"); err != nil {
+		t.Fatalf("AddInventoryNodes failed: %v", err)
+	}
+	if found, err := RemoveInventoryHost(path, "node-0", "10.0.0.2"); err != nil || !found {
+		t.Fatalf("RemoveInventoryHost = %v, %v", found, err)
+	}
+	if found, err := RemoveInventoryHost(path, "gpu-0", "10.0.0.5"); err != nil || !found {
+		t.Fatalf("RemoveInventoryHost = %v, %v", found, err)
+	}
+	if found, err := RemoveInventoryHost(path, "node-9", "10.0.0.9"); err != nil || found {
+		t.Errorf("Expected unknown host not to be found, got %v, %v", found, err)
+	}
+	if err := SetFileValue(path, "kubernetes.version", "v1.30.0"); err != nil {
+		t.Fatalf("SetFileValue failed: %v", err)
+	}
+	if err := SetFileValue(path, "offline.registry.url", "registry.local:5000"); err != nil {
+		t.Fatalf("SetFileValue failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{
+		"# The prod cluster",
+		"masters: [10.0.0.1]",
+		"  nodes: # workers\n    - 10.0.0.3\n    - 10.0.0.4\n",
+		"hosts: []",
+		"version: v1.30.0",
+		"offline:\n  registry:\n    url: registry.local:5000\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q in:\n%s", want, data)
+		}
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be kept, got %v", info.Mode().Perm())
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.yaml")
+	writeFile(t, empty, "")
+	if err := AddInventoryNodes(empty, "10.0.0.7"); err != nil {
+		t.Fatalf("AddInventoryNodes on an empty file failed: %v", err)
+	}
+	if data, _ := os.ReadFile(empty); string(data) != "inventory:\n  nodes:\n    - 10.0.0.7\n" {
+		t.Errorf("Unexpected file:\n%s", data)
+	}
+}
@@ -0,0 +1,341 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration layers, lowest precedence first. Each layer overrides the
+// values set by the layers before it.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceCluster = "cluster"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// envPrefix prefixes the environment variable of every setting, as in
+// KUBESPRAY_KUBERNETES_VERSION for kubernetes.version
+const envPrefix = "KUBESPRAY_"
+
+// Origin tells where a setting came from
+type Origin struct {
+	// Source is one of the Source constants
+	Source string
+	// Detail is the file, environment variable or flag that set the value
+	Detail string
+}
+
+// String formats the origin for display
+func (o Origin) String() string {
+	if o.Detail == "" {
+		return o.Source
+	}
+	return o.Source + " " + o.Detail
+}
+
+// Options select the configuration layers to load
+type Options struct {
+	// File is the global config file; ~/.kubespray.yaml is used, when it
+	// exists, if empty
+	File string
+	// Cluster names the profile in ClustersDir layered over the global file;
+	// KUBESPRAY_CLUSTER is used if empty
+	Cluster string
+	// Set holds key=value overrides from the command line, such as
+	// kubernetes.version=v1.30.0
+	Set []string
+	// Strict rejects config files with keys the schema does not describe.
+	// Otherwise unknown keys are ignored and reported by Warnings.
+	Strict bool
+}
+
+// ClustersDir returns the directory holding cluster profiles,
+// ~/.kubespray/clusters
+func ClustersDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".kubespray", "clusters"), nil
+}
+
+// ClusterFile returns the path of the named cluster profile
+func ClusterFile(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid cluster name %q", name)
+	}
+	dir, err := ClustersDir()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range []string{".yaml", ".yml"} {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("cluster profile %s not found in %s", name, dir)
+}
+
+// LoadWithOptions builds the configuration from its layers, lowest
+// precedence first: built-in defaults, the global config file, the cluster
+// profile, KUBESPRAY_* environment variables and --set flags. The origin of
+// every setting is recorded and available through Origin. Keys of the files
+// that are not in Schema are reported by Warnings, or rejected with Strict.
+func LoadWithOptions(opts Options) (*Config, error) {
+	cfg := NewConfig()
+	cfg.origins = map[string]Origin{}
+
+	keys := settingKeys()
+	v := viper.New()
+
+	file := opts.File
+	if file == "" {
+		file = DefaultFile()
+	}
+	if file != "" {
+		if err := cfg.mergeFile(v, file, Origin{SourceFile, file}); err != nil {
+			return nil, err
+		}
+		cfg.file = file
+	}
+
+	cluster := opts.Cluster
+	if cluster == "" {
+		cluster = os.Getenv(envPrefix + "CLUSTER")
+	}
+	if cluster != "" {
+		path, err := ClusterFile(cluster)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.mergeFile(v, path, Origin{SourceCluster, path}); err != nil {
+			return nil, err
+		}
+		cfg.cluster = cluster
+	}
+
+	for _, key := range keys {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if value, ok := os.LookupEnv(name); ok {
+			v.Set(key, value)
+			cfg.origins[key] = Origin{SourceEnv, name}
+		}
+	}
+
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[strings.ToLower(key)] = true
+	}
+	for _, set := range opts.Set {
+		key, raw, ok := strings.Cut(set, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || !known[key] {
+			return nil, fmt.Errorf("invalid --set %q: expected key=value with a key such as kubernetes.version", set)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil || value == nil {
+			value = raw
+		}
+		v.Set(key, value)
+		cfg.origins[key] = Origin{SourceFlag, "--set " + key}
+	}
+
+	if opts.Strict && len(cfg.warnings) > 0 {
+		return nil, &ValidationError{Errors: cfg.warnings}
+	}
+
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	cfg.KubesprayPath = expandHome(cfg.KubesprayPath)
+	cfg.SSH.KeyPath = expandHome(cfg.SSH.KeyPath)
+
+	return cfg, nil
+}
+
+// mergeFile merges a YAML config file into v, after upgrading it to
+// CurrentAPIVersion, and records its settings
+func (c *Config) mergeFile(v *viper.Viper, path string, origin Origin) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	values := map[string]interface{}{}
+	if root.Kind != 0 {
+		// Older files are upgraded in memory; config migrate rewrites them
+		if _, err := MigrateDocument(&root); err != nil {
+			return fmt.Errorf("failed to read config %s: %w", path, err)
+		}
+		if err := root.Decode(&values); err != nil {
+			return fmt.Errorf("failed to read config %s: %w", path, err)
+		}
+		c.warnings = append(c.warnings, unknownKeys(&root, Schema(), path)...)
+	}
+	if err := v.MergeConfigMap(values); err != nil {
+		return fmt.Errorf("failed to merge config %s: %w", path, err)
+	}
+	for _, key := range fileKeys(values, "") {
+		c.origins[key] = origin
+	}
+	return nil
+}
+
+// DefaultFile returns ~/.kubespray.yaml (or .yml) when it exists
+func DefaultFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{".kubespray.yaml", ".kubespray.yml"} {
+		path := filepath.Join(home, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Warnings returns the keys of the config files that are not settings, such
+// as misspelled keys, which were ignored when loading
+func (c *Config) Warnings() []FieldError {
+	return c.warnings
+}
+
+// Cluster returns the name of the cluster profile in use, if any
+func (c *Config) Cluster() string {
+	return c.cluster
+}
+
+// Origin returns where the setting with the dotted key came from. Settings
+// inside lists, such as inventory.hosts[0].address, have the origin of the
+// list.
+func (c *Config) Origin(key string) Origin {
+	key = strings.ToLower(key)
+	for {
+		if o, ok := c.origins[key]; ok {
+			return o
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			return Origin{Source: SourceDefault}
+		}
+		key = key[:i]
+	}
+}
+
+// Setting is a single configuration value with its origin
+type Setting struct {
+	Key    string
+	Value  interface{}
+	Origin Origin
+}
+
+// Settings returns every setting of the configuration in key order. Secret
+// values are redacted.
+func (c *Config) Settings() []Setting {
+	values := map[string]interface{}{}
+	walkFields(reflect.ValueOf(*c), "", func(key string, field reflect.StructField, v reflect.Value) {
+		values[key] = c.redact(key, field, v.Interface())
+	})
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	settings := make([]Setting, len(keys))
+	for i, k := range keys {
+		settings[i] = Setting{Key: k, Value: values[k], Origin: c.Origin(k)}
+	}
+	return settings
+}
+
+// Map returns the configuration as nested maps keyed like the config file.
+// Secret values are redacted.
+func (c *Config) Map() map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, s := range c.Settings() {
+		parts := strings.Split(s.Key, ".")
+		m := out
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := m[p].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[p] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = s.Value
+	}
+	return out
+}
+
+// settingKeys returns the dotted key of every setting
+func settingKeys() []string {
+	keys := []string{}
+	walkFields(reflect.ValueOf(Config{}), "", func(key string, _ reflect.StructField, _ reflect.Value) {
+		keys = append(keys, key)
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// walkFields calls fn for each exported field of a struct with its dotted
+// mapstructure key. Nested structs are walked; lists, maps and scalars are
+// settings.
+func walkFields(v reflect.Value, prefix string, fn func(key string, field reflect.StructField, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+		if field.Type.Kind() == reflect.Struct {
+			walkFields(v.Field(i), key, fn)
+			continue
+		}
+		fn(key, field, v.Field(i))
+	}
+}
+
+// fileKeys returns the dotted keys set by a decoded config file. Mappings
+// are walked; anything else is a setting.
+func fileKeys(values map[string]interface{}, prefix string) []string {
+	keys := []string{}
+	for k, v := range values {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			keys = append(keys, fileKeys(m, key)...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
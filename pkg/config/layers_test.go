@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFile writes content to path, creating its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+
+	global := filepath.Join(home, ".kubespray.yaml")
+	profile := filepath.Join(home, ".kubespray", "clusters", "prod.yaml")
+	writeFile(t, global, `kubernetes:
+  version: v1.28.6
+  network_plugin: cilium
+  max_pods: 200
+offline:
+  workers: 10
+ssh:
+  user: admin
+`)
+	writeFile(t, profile, `kubernetes:
+  version: v1.29.3
+  max_pods: 150
+inventory:
+  masters: [10.0.0.1, 10.0.0.2, 10.0.0.3]
+`)
+	t.Setenv("KUBESPRAY_KUBERNETES_MAX_PODS", "180")
+	t.Setenv("KUBESPRAY_SSH_PORT", "2222")
+
+	cfg, err := LoadWithOptions(Options{
+		Cluster: "prod",
+		Set:     []string{"offline.workers=40", "kubernetes.max_pods=250"},
+	})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		origin Origin
+	}{
+		{"kubernetes.version", "v1.29.3", Origin{SourceCluster, profile}},
+		{"kubernetes.network_plugin", "cilium", Origin{SourceFile, global}},
+		{"kubernetes.pod_subnet", "10.233.64.0/18", Origin{Source: SourceDefault}},
+		{"kubernetes.max_pods", 250, Origin{SourceFlag, "--set kubernetes.max_pods"}},
+		{"offline.workers", 40, Origin{SourceFlag, "--set offline.workers"}},
+		{"ssh.user", "admin", Origin{SourceFile, global}},
+		{"ssh.port", 2222, Origin{SourceEnv, "KUBESPRAY_SSH_PORT"}},
+		{"inventory.masters", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, Origin{SourceCluster, profile}},
+	}
+
+	settings := map[string]Setting{}
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			s, ok := settings[tt.key]
+			if !ok {
+				t.Fatalf("Setting %s not found", tt.key)
+			}
+			if !reflect.DeepEqual(s.Value, tt.value) {
+				t.Errorf("Expected value %v, got %v", tt.value, s.Value)
+			}
+			if s.Origin != tt.origin {
+				t.Errorf("Expected origin %v, got %v", tt.origin, s.Origin)
+			}
+		})
+	}
+
+	if cfg.Cluster() != "prod" || cfg.File() != global {
+		t.Errorf("Unexpected cluster %q or file %q", cfg.Cluster(), cfg.File())
+	}
+	if cfg.Origin("inventory.masters[1]") != (Origin{SourceCluster, profile}) {
+		t.Errorf("Expected list entries to have the origin of the list, got %v", cfg.Origin("inventory.masters[1]"))
+	}
+	if got := cfg.Map()["kubernetes"].(map[string]interface{})["version"]; got != "v1.29.3" {
+		t.Errorf("Expected nested map value v1.29.3, got %v", got)
+	}
+}
+
+func TestLoadClusterFromEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile(t, filepath.Join(home, ".kubespray", "clusters", "staging.yml"), "log_level: debug\n")
+	t.Setenv("KUBESPRAY_CLUSTER", "staging")
+
+	cfg, err := LoadWithOptions(Options{})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+	if cfg.LogLevel != "debug" || cfg.Cluster() != "staging" {
+		t.Errorf("Expected staging profile to be loaded, got log_level %q cluster %q", cfg.LogLevel, cfg.Cluster())
+	}
+}
+
+func TestLoadLayerErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"Missing profile", Options{Cluster: "prod"}},
+		{"Path as cluster name", Options{Cluster: "../prod"}},
+		{"Missing file", Options{File: filepath.Join(home, "missing.yaml")}},
+		{"Unknown --set key", Options{Set: []string{"kubernetes.verison=v1.30.0"}}},
+		{"Malformed --set", Options{Set: []string{"kubernetes.version"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadWithOptions(tt.opts); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestValidateLocatesProfileValues(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+
+	profile := filepath.Join(home, ".kubespray", "clusters", "lab.yaml")
+	writeFile(t, profile, "api_endpoint:\n  mode: vip\n")
+
+	cfg, err := LoadWithOptions(Options{Cluster: "lab"})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+	err = cfg.Validate()
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 1 {
+		t.Fatalf("Expected one validation error, got %v", err)
+	}
+	if fe := verr.Errors[0]; fe.File != profile || fe.Line != 2 {
+		t.Errorf("Expected error at %s:2, got %s:%d", profile, fe.File, fe.Line)
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentAPIVersion is the config file schema written and understood by this
+// version of the CLI
+const CurrentAPIVersion = "kubespray/v1"
+
+// apiVersionKey is the document key holding the schema version
+const apiVersionKey = "apiVersion"
+
+// Migration upgrades a config document from one schema version to the next.
+// Documents without apiVersion predate versioning and have version "".
+type Migration struct {
+	From        string
+	To          string
+	Description string
+	// Apply rewrites the document mapping in place. It may be nil when only
+	// the version changes. apiVersion is set to To after Apply succeeds.
+	Apply func(doc *yaml.Node) error
+}
+
+// migrations is the upgrade chain, oldest first. Each migration starts from
+// the version the previous one produced and the last one ends at
+// CurrentAPIVersion.
+var migrations = []Migration{
+	{
+		From:        "",
+		To:          "kubespray/v1",
+		Description: "record the schema version of unversioned files",
+	},
+}
+
+// Migrations returns the upgrade chain, oldest first
+func Migrations() []Migration {
+	return append([]Migration(nil), migrations...)
+}
+
+// MigrateDocument upgrades a parsed config document to CurrentAPIVersion one
+// step at a time and returns the migrations applied. A document newer than
+// CurrentAPIVersion, or of an unknown version, is an error.
+func MigrateDocument(root *yaml.Node) ([]Migration, error) {
+	doc := root
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+		}
+		doc = doc.Content[0]
+	}
+	if doc.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config must be a mapping")
+	}
+
+	version := ""
+	if n := mappingValue(doc, apiVersionKey); n != nil {
+		version = n.Value
+	}
+
+	applied := []Migration{}
+	for version != CurrentAPIVersion {
+		m, ok := migrationFrom(version)
+		if !ok {
+			return nil, fmt.Errorf("unsupported apiVersion %q, this kubespray understands %s; upgrade kubespray to read newer files", version, CurrentAPIVersion)
+		}
+		if m.Apply != nil {
+			if err := m.Apply(doc); err != nil {
+				return nil, fmt.Errorf("migration from %q to %s failed: %w", m.From, m.To, err)
+			}
+		}
+		setAPIVersion(doc, m.To)
+		applied = append(applied, m)
+		version = m.To
+	}
+	return applied, nil
+}
+
+// MigrateData upgrades a YAML config document and returns the rewritten
+// document, or data unchanged when it is already current. Comments are kept.
+func MigrateData(data []byte) ([]byte, []Migration, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if root.Kind == 0 {
+		root.Kind = yaml.DocumentNode
+	}
+	line := versionLine(&root)
+	applied, err := MigrateDocument(&root)
+	if err != nil || len(applied) == 0 {
+		return data, applied, err
+	}
+
+	versionOnly := true
+	for _, m := range applied {
+		versionOnly = versionOnly && m.Apply == nil
+	}
+	if versionOnly && line != 0 {
+		return setVersionLine(data, line), applied, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), applied, nil
+}
+
+// MigrateFile upgrades the config file at path in place. The original is
+// kept next to it with a .bak suffix; the backup path is returned. Nothing is
+// written when the file is already current.
+func MigrateFile(path string) ([]Migration, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read config: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read config: %w", err)
+	}
+
+	out, applied, err := MigrateData(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	if len(applied) == 0 {
+		return applied, "", nil
+	}
+
+	backup := path + ".bak"
+	if err := os.WriteFile(backup, data, info.Mode().Perm()); err != nil {
+		return nil, "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := replaceFile(path, out, info.Mode().Perm()); err != nil {
+		return nil, "", err
+	}
+	return applied, backup, nil
+}
+
+// versionLine returns the line apiVersion is written on: the line of the
+// current apiVersion key, or that of the first key of the document and its
+// comment when it has none. It is 0 when the document is empty.
+func versionLine(root *yaml.Node) int {
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return 0
+	}
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == apiVersionKey {
+			return doc.Content[i].Line
+		}
+	}
+	if len(doc.Content) == 0 || doc.Style&yaml.FlowStyle != 0 {
+		return 0
+	}
+	first := doc.Content[0]
+	if first.HeadComment == "" {
+		return -first.Line
+	}
+	return -(first.Line - strings.Count(first.HeadComment, "\n") - 1)
+}
+
+// setVersionLine writes the current apiVersion into data without touching
+// the rest of the file. A positive line holds the apiVersion key to replace;
+// a negative one is the first key, which the new key is inserted above.
+func setVersionLine(data []byte, line int) []byte {
+	entry := apiVersionKey + ": " + CurrentAPIVersion
+	lines := strings.SplitAfter(string(data), "\n")
+	if line > 0 {
+		lines[line-1] = entry + "\n"
+	} else {
+		i := -line - 1
+		lines = append(lines[:i], append([]string{entry + "\n"}, lines[i:]...)...)
+	}
+	return []byte(strings.Join(lines, ""))
+}
+
+// migrationFrom returns the migration upgrading version
+func migrationFrom(version string) (Migration, bool) {
+	for _, m := range migrations {
+		if m.From == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// mappingValue returns the value of key in a mapping node
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setAPIVersion sets the apiVersion of a document, adding it as the first key
+func setAPIVersion(doc *yaml.Node, version string) {
+	if n := mappingValue(doc, apiVersionKey); n != nil {
+		n.Kind, n.Tag, n.Value, n.Style = yaml.ScalarNode, "!!str", version, 0
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: apiVersionKey}
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: version}
+	doc.Content = append([]*yaml.Node{key, value}, doc.Content...)
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateData(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		applied  int
+		wantErr  bool
+	}{
+		{
+			name:     "Unversioned file",
+			input:    "# Cluster settings\n\n# Logging\nlog_level: debug\nssh:\n  user: admin # deploy user\n",
+			expected: "# Cluster settings\n\napiVersion: kubespray/v1\n# Logging\nlog_level: debug\nssh:\n  user: admin # deploy user\n",
+			applied:  1,
+		},
+		{
+			name:     "Layout is kept",
+			input:    "log_level: info\n\nssh:\n  user:   admin\n",
+			expected: "apiVersion: kubespray/v1\nlog_level: info\n\nssh:\n  user:   admin\n",
+			applied:  1,
+		},
+		{
+			name:     "Empty file",
+			input:    "",
+			expected: "apiVersion: kubespray/v1\n",
+			applied:  1,
+		},
+		{
+			name:     "Current file",
+			input:    "apiVersion: kubespray/v1\nlog_level:   debug\n",
+			expected: "apiVersion: kubespray/v1\nlog_level:   debug\n",
+		},
+		{
+			name:    "Newer file",
+			input:   "apiVersion: kubespray/v2\n",
+			wantErr: true,
+		},
+		{
+			name:    "Not a mapping",
+			input:   "- log_level\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, applied, err := MigrateData([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(out) != tt.expected {
+				t.Errorf("Unexpected output:\n%s\nwant:\n%s", out, tt.expected)
+			}
+			if len(applied) != tt.applied {
+				t.Errorf("Expected %d migrations, got %d", tt.applied, len(applied))
+			}
+		})
+	}
+}
+
+func TestMigrateDocumentSteps(t *testing.T) {
+	orig := migrations
+	defer func() { migrations = orig }()
+
+	var order []string
+	migrations = []Migration{
+		{From: "", To: "kubespray/v0", Apply: func(doc *yaml.Node) error {
+			order = append(order, "v0")
+			return nil
+		}},
+		{From: "kubespray/v0", To: CurrentAPIVersion, Apply: func(doc *yaml.Node) error {
+			order = append(order, "v1")
+			for i := 0; i+1 < len(doc.Content); i += 2 {
+				if doc.Content[i].Value == "sshuser" {
+					user := &yaml.Node{Kind: yaml.ScalarNode, Value: "user"}
+					doc.Content[i].Value = "ssh"
+					doc.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{user, doc.Content[i+1]}}
+				}
+			}
+			return nil
+		}},
+	}
+
+	out, applied, err := MigrateData([]byte("sshuser: admin\n"))
+	if err != nil {
+		t.Fatalf("MigrateData failed: %v", err)
+	}
+	if len(applied) != 2 || strings.Join(order, ",") != "v0,v1" {
+		t.Errorf("Expected both migrations in order, got %v", order)
+	}
+	expected := "apiVersion: kubespray/v1\nssh:\n  user: admin\n"
+	if string(out) != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out, expected)
+	}
+
+	// A document part way through the chain only takes the remaining steps
+	order = nil
+	if _, _, err := MigrateData([]byte("apiVersion: kubespray/v0\n")); err != nil {
+		t.Fatalf("MigrateData failed: %v", err)
+	}
+	if strings.Join(order, ",") != "v1" {
+		t.Errorf("Expected only the last migration, got %v", order)
+	}
+
+	migrations[1].Apply = func(*yaml.Node) error { return fmt.Errorf("boom") }
+	if _, _, err := MigrateData([]byte("log_level: info\n")); err == nil {
+		t.Error("Expected failing migration to return an error")
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	original := "kubernetes:\n  version: v1.29.3\n"
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, backup, err := MigrateFile(path)
+	if err != nil {
+		t.Fatalf("MigrateFile failed: %v", err)
+	}
+	if len(applied) != 1 || backup != path+".bak" {
+		t.Errorf("Unexpected result %v, %q", applied, backup)
+	}
+
+	data, err := os.ReadFile(backup)
+	if err != nil || string(data) != original {
+		t.Errorf("Expected backup to hold the original file, got %q, %v", data, err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil || string(data) != "apiVersion: kubespray/v1\n"+original {
+		t.Errorf("Unexpected migrated file %q, %v", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file mode to be kept, got %v", info.Mode())
+	}
+
+	applied, backup, err = MigrateFile(path)
+	if err != nil || len(applied) != 0 || backup != "" {
+		t.Errorf("Expected current file to be left alone, got %v, %q, %v", applied, backup, err)
+	}
+}
+
+func TestLoadMigratesOlderFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+
+	legacy := filepath.Join(home, "legacy.yaml")
+	writeFile(t, legacy, "log_level: debug\n")
+	cfg, err := LoadWithOptions(Options{File: legacy})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+	if cfg.APIVersion != CurrentAPIVersion || cfg.LogLevel != "debug" {
+		t.Errorf("Expected legacy file to load at %s, got %q", CurrentAPIVersion, cfg.APIVersion)
+	}
+
+	newer := filepath.Join(home, "newer.yaml")
+	writeFile(t, newer, "apiVersion: kubespray/v9\n")
+	if _, err := LoadWithOptions(Options{File: newer}); err == nil {
+		t.Error("Expected error for a newer apiVersion")
+	}
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaDialect is the JSON Schema version Schema conforms to
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a JSON Schema document or subschema
+type JSONSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	// AdditionalProperties is false for structs and the value schema for maps
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema `json:"items,omitempty"`
+	Enum                 []string    `json:"enum,omitempty"`
+	Pattern              string      `json:"pattern,omitempty"`
+	Default              interface{} `json:"default,omitempty"`
+	WriteOnly            bool        `json:"writeOnly,omitempty"`
+}
+
+// schemaEnums are the allowed values of enumerated settings by path. List
+// items are written as [].
+var schemaEnums = map[string][]string{
+	"apiVersion":                  {CurrentAPIVersion},
+	"log_level":                   logLevels,
+	"kubernetes.network_plugin":   NetworkPlugins,
+	"api_endpoint.mode":           endpointModes,
+	"api_endpoint.localhost_type": localhostTypes,
+	"inventory.hostnames.source":  hostnameSources,
+	"inventory.hosts[].roles[]":   hostRoles,
+	"metallb.pools[].protocol":    metalLBProtos,
+}
+
+// schemaPatterns are the regular expressions string settings must match
+var schemaPatterns = map[string]string{
+	"kubernetes.version": versionPattern.String(),
+}
+
+// Schema returns the JSON Schema of the config file, generated from the
+// Config struct tags with the built-in defaults
+func Schema() *JSONSchema {
+	s := schemaFor(reflect.TypeOf(Config{}), reflect.ValueOf(*NewConfig()), "")
+	s.Schema = schemaDialect
+	s.Title = "kubespray CLI configuration"
+	s.Description = "Configuration file of the kubespray CLI, such as ~/.kubespray.yaml or a cluster profile"
+	return s
+}
+
+// schemaFor builds the schema of a type. v holds the default value, or is
+// invalid inside lists and maps.
+func schemaFor(t reflect.Type, v reflect.Value, path string) *JSONSchema {
+	s := &JSONSchema{}
+	switch t.Kind() {
+	case reflect.Struct:
+		s.Type = "object"
+		s.Properties = map[string]*JSONSchema{}
+		s.AdditionalProperties = false
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("mapstructure")
+			if !field.IsExported() || tag == "" || tag == "-" {
+				continue
+			}
+			key := tag
+			if path != "" {
+				key = path + "." + tag
+			}
+			var fv reflect.Value
+			if v.IsValid() {
+				fv = v.Field(i)
+			}
+			prop := schemaFor(field.Type, fv, key)
+			if field.Tag.Get("secret") == "true" {
+				prop.WriteOnly = true
+				prop.Description = "Secret; prefer a reference such as env:NAME, file:PATH, exec:COMMAND, sops:PATH#key or age:PATH"
+			}
+			s.Properties[tag] = prop
+		}
+		return s
+	case reflect.Slice:
+		s.Type = "array"
+		s.Items = schemaFor(t.Elem(), reflect.Value{}, path+"[]")
+	case reflect.Map:
+		s.Type = "object"
+		s.AdditionalProperties = schemaFor(t.Elem(), reflect.Value{}, path+"{}")
+	case reflect.String:
+		s.Type = "string"
+		s.Enum = schemaEnums[path]
+		s.Pattern = schemaPatterns[path]
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.Type = "integer"
+	case reflect.Interface:
+		// Any value
+		return s
+	}
+
+	if v.IsValid() && !v.IsZero() && t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		s.Default = schemaDefault(v.Interface())
+	}
+	return s
+}
+
+// schemaDefault shortens default paths under the home directory to ~ so the
+// schema does not depend on who generated it
+func schemaDefault(value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return s
+	}
+	if s == home {
+		return "~"
+	}
+	if strings.HasPrefix(s, home+string(os.PathSeparator)) {
+		return "~/" + strings.TrimPrefix(s, home+string(os.PathSeparator))
+	}
+	return s
+}
+
+// unknownKeys returns an error for every key of a config document the schema
+// does not describe, located in file
+func unknownKeys(root *yaml.Node, schema *JSONSchema, file string) []FieldError {
+	errs := []FieldError{}
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	checkKeys(root, schema, "", file, &errs)
+	return errs
+}
+
+// checkKeys walks a YAML node alongside its schema
+func checkKeys(n *yaml.Node, s *JSONSchema, path, file string, errs *[]FieldError) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		if s.Type != "object" {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			field := key.Value
+			if path != "" {
+				field = path + "." + key.Value
+			}
+			if prop := s.property(key.Value); prop != nil {
+				checkKeys(value, prop, field, file, errs)
+				continue
+			}
+			if extra, ok := s.AdditionalProperties.(*JSONSchema); ok {
+				checkKeys(value, extra, field, file, errs)
+				continue
+			}
+			msg := "unknown key"
+			if suggestion := s.closestProperty(key.Value); suggestion != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+			*errs = append(*errs, FieldError{Field: field, Message: msg, File: file, Line: key.Line, Column: key.Column})
+		}
+	case yaml.SequenceNode:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range n.Content {
+			checkKeys(item, s.Items, fmt.Sprintf("%s[%d]", path, i), file, errs)
+		}
+	}
+}
+
+// property returns the schema of a property. Keys match case-insensitively,
+// as viper lowercases them when loading.
+func (s *JSONSchema) property(key string) *JSONSchema {
+	for name, prop := range s.Properties {
+		if strings.EqualFold(name, key) {
+			return prop
+		}
+	}
+	return nil
+}
+
+// closestProperty returns the property name within two edits of key, if any
+func (s *JSONSchema) closestProperty(key string) string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDist := "", 3
+	for _, name := range names {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
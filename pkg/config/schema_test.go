@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSchema(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	s := Schema()
+
+	lookup := func(path string) *JSONSchema {
+		cur := s
+		for _, part := range strings.Split(path, ".") {
+			if part == "[]" {
+				cur = cur.Items
+			} else {
+				cur = cur.Properties[part]
+			}
+			if cur == nil {
+				t.Fatalf("No schema for %s", path)
+			}
+		}
+		return cur
+	}
+
+	if s.AdditionalProperties != false || lookup("kubernetes").AdditionalProperties != false {
+		t.Error("Expected structs to reject additional properties")
+	}
+	if got := lookup("kubernetes.version"); got.Type != "string" || got.Default != "v1.29.0" || got.Pattern == "" {
+		t.Errorf("Unexpected kubernetes.version schema %+v", got)
+	}
+	if got := lookup("kubernetes.max_pods"); got.Type != "integer" || got.Default != 110 {
+		t.Errorf("Unexpected kubernetes.max_pods schema %+v", got)
+	}
+	if got := lookup("kubespray_path"); got.Default != "~/.kubespray" {
+		t.Errorf("Expected home-relative default, got %v", got.Default)
+	}
+	if got := lookup("kubernetes.network_plugin"); !reflect.DeepEqual(got.Enum, NetworkPlugins) {
+		t.Errorf("Expected network plugin enum, got %v", got.Enum)
+	}
+	if got := lookup("inventory.hosts.[].roles.[]"); !reflect.DeepEqual(got.Enum, hostRoles) {
+		t.Errorf("Expected host role enum, got %v", got.Enum)
+	}
+	if got := lookup("inventory.hosts.[].vars"); got.Type != "object" || !reflect.DeepEqual(got.AdditionalProperties, &JSONSchema{}) {
+		t.Errorf("Expected host vars to accept any value, got %+v", got)
+	}
+	if got := lookup("ssh.become_password"); !got.WriteOnly || got.Default != nil {
+		t.Errorf("Expected secret to be write-only without default, got %+v", got)
+	}
+	if got := lookup("metallb.enabled"); got.Type != "boolean" || got.Default != nil {
+		t.Errorf("Expected no default for false, got %+v", got)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"additionalProperties":false`) {
+		t.Error("Expected additionalProperties false to be encoded")
+	}
+}
+
+func TestSchemaCoversSettings(t *testing.T) {
+	s := Schema()
+	for _, key := range settingKeys() {
+		cur := s
+		for _, part := range strings.Split(key, ".") {
+			cur = cur.Properties[part]
+			if cur == nil {
+				t.Errorf("Setting %s missing from schema", key)
+				break
+			}
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "Known keys",
+			content:  "apiVersion: kubespray/v1\nLog_Level: info\nssh:\n  user: admin\ninventory:\n  hosts:\n    - name: a\n      vars:\n        anything: [1, 2]\n",
+			expected: []string{},
+		},
+		{
+			name:    "Misspelled keys",
+			content: "kubernets:\n  version: v1.29.0\nssh:\n  usr: admin\n",
+			expected: []string{
+				"f.yaml:1:1: kubernets: unknown key (did you mean kubernetes?)",
+				"f.yaml:4:3: ssh.usr: unknown key (did you mean user?)",
+			},
+		},
+		{
+			name:     "List entries",
+			content:  "metallb:\n  pools:\n    - name: a\n    - nmae: b\n      color: red\n",
+			expected: []string{"f.yaml:4:7: metallb.pools[1].nmae: unknown key (did you mean name?)", "f.yaml:5:7: metallb.pools[1].color: unknown key"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.content), &root); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, fe := range unknownKeys(&root, Schema(), "f.yaml") {
+				got = append(got, fe.Error())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Unexpected errors:\n got %q\nwant %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadUnknownKeys(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+
+	path := filepath.Join(home, "cluster.yaml")
+	writeFile(t, path, "log_level: debug\nlog_levle: warn\n")
+
+	cfg, err := LoadWithOptions(Options{File: path})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+	if cfg.LogLevel != "debug" || len(cfg.Warnings()) != 1 || cfg.Warnings()[0].Line != 2 {
+		t.Errorf("Expected unknown key to be ignored with a warning, got %q %v", cfg.LogLevel, cfg.Warnings())
+	}
+
+	_, err = LoadWithOptions(Options{File: path, Strict: true})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 1 || verr.Errors[0].Field != "log_levle" {
+		t.Errorf("Expected strict load to reject the unknown key, got %v", err)
+	}
+}
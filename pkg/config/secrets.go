@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Redacted replaces the value of secret settings in Settings and Map
+const Redacted = "[redacted]"
+
+// secretSchemes are the prefixes of secret references
+var secretSchemes = []string{"env:", "file:", "exec:", "sops:", "age:"}
+
+// runSecretCommand runs a command and returns its standard output. It is a
+// variable so tests can stand in for sops and age.
+var runSecretCommand = func(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	return cmd.Output()
+}
+
+// IsSecretRef reports whether value is a secret reference such as env:NAME
+func IsSecretRef(value string) bool {
+	for _, scheme := range secretSchemes {
+		if strings.HasPrefix(value, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSecret returns the value a secret reference points to:
+//
+//	env:NAME         the environment variable NAME
+//	file:PATH        the contents of PATH
+//	exec:COMMAND     the output of COMMAND, run with sh -c
+//	sops:PATH[#KEY]  PATH decrypted with sops, or the dotted KEY inside it
+//	age:PATH         PATH decrypted with age using the identity file in
+//	                 KUBESPRAY_AGE_IDENTITY, SOPS_AGE_KEY_FILE or
+//	                 ~/.config/sops/age/keys.txt
+//
+// Trailing newlines are removed. Errors never include the secret value.
+func ResolveSecret(ref string) (string, error) {
+	scheme, arg, _ := strings.Cut(ref, ":")
+	if arg == "" {
+		return "", fmt.Errorf("empty secret reference %q", ref)
+	}
+
+	var out []byte
+	var err error
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", arg)
+		}
+		return value, nil
+	case "file":
+		out, err = os.ReadFile(expandHome(arg))
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+	case "exec":
+		out, err = runSecretCommand("sh", "-c", arg)
+		if err != nil {
+			return "", fmt.Errorf("secret command %q failed: %w", arg, err)
+		}
+	case "sops":
+		path, key, _ := strings.Cut(arg, "#")
+		args := []string{"--decrypt"}
+		if key != "" {
+			args = append(args, "--extract", sopsPath(key))
+		}
+		out, err = runSecretCommand("sops", append(args, expandHome(path))...)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt %s with sops: %w", path, err)
+		}
+	case "age":
+		identity, err := ageIdentity()
+		if err != nil {
+			return "", err
+		}
+		out, err = runSecretCommand("age", "--decrypt", "--identity", identity, expandHome(arg))
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt %s with age: %w", arg, err)
+		}
+	default:
+		return "", fmt.Errorf("unknown secret reference scheme %q", scheme)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// sopsPath converts a dotted key into a sops --extract path, as in
+// ["registry"]["password"] for registry.password
+func sopsPath(key string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(key, ".") {
+		fmt.Fprintf(&b, "[%q]", part)
+	}
+	return b.String()
+}
+
+// ageIdentity returns the age identity file used to decrypt age: references
+func ageIdentity() (string, error) {
+	for _, name := range []string{envPrefix + "AGE_IDENTITY", "SOPS_AGE_KEY_FILE"} {
+		if path := os.Getenv(name); path != "" {
+			return expandHome(path), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "sops", "age", "keys.txt"), nil
+}
+
+// resolveSecrets replaces every string setting holding a secret reference
+// with the value it points to, remembering the reference for display
+func (c *Config) resolveSecrets() error {
+	c.secrets = map[string]string{}
+	var err error
+	walkFields(reflect.ValueOf(c).Elem(), "", func(key string, _ reflect.StructField, v reflect.Value) {
+		if err != nil || v.Kind() != reflect.String || !IsSecretRef(v.String()) {
+			return
+		}
+		ref := v.String()
+		value, rerr := ResolveSecret(ref)
+		if rerr != nil {
+			err = fmt.Errorf("failed to resolve %s from %s: %w", key, ref, rerr)
+			return
+		}
+		v.SetString(value)
+		c.secrets[key] = ref
+	})
+	return err
+}
+
+// redact hides a setting value that is or was read from a secret. Resolved
+// references show the reference; other secret settings show Redacted.
+func (c *Config) redact(key string, field reflect.StructField, value interface{}) interface{} {
+	if ref, ok := c.secrets[key]; ok {
+		return ref
+	}
+	if field.Tag.Get("secret") == "true" && value != "" {
+		return Redacted
+	}
+	return value
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "password")
+	writeFile(t, secretFile, "hunter2\n")
+	t.Setenv("TEST_SECRET", "s3cr3t")
+
+	tests := []struct {
+		name     string
+		ref      string
+		expected string
+		wantErr  bool
+	}{
+		{"Environment", "env:TEST_SECRET", "s3cr3t", false},
+		{"File", "file:" + secretFile, "hunter2", false},
+		{"Command", "exec:printf 'token\\n'", "token", false},
+		{"Missing variable", "env:TEST_SECRET_MISSING", "", true},
+		{"Missing file", "file:" + filepath.Join(dir, "missing"), "", true},
+		{"Failing command", "exec:exit 3", "", true},
+		{"Empty reference", "env:", "", true},
+		{"Unknown scheme", "vault:secret/data/ssh", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSecret(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveEncryptedSecret(t *testing.T) {
+	t.Setenv("KUBESPRAY_AGE_IDENTITY", "/keys/age.txt")
+
+	var calls [][]string
+	orig := runSecretCommand
+	runSecretCommand = func(name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		return []byte("decrypted\n"), nil
+	}
+	defer func() { runSecretCommand = orig }()
+
+	tests := []struct {
+		ref      string
+		expected []string
+	}{
+		{"sops:/secrets/prod.enc.yaml", []string{"sops", "--decrypt", "/secrets/prod.enc.yaml"}},
+		{"sops:/secrets/prod.enc.yaml#registry.password", []string{"sops", "--decrypt", "--extract", `["registry"]["password"]`, "/secrets/prod.enc.yaml"}},
+		{"age:/secrets/become.age", []string{"age", "--decrypt", "--identity", "/keys/age.txt", "/secrets/become.age"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			calls = nil
+			got, err := ResolveSecret(tt.ref)
+			if err != nil {
+				t.Fatalf("ResolveSecret failed: %v", err)
+			}
+			if got != "decrypted" {
+				t.Errorf("Expected decrypted, got %q", got)
+			}
+			if len(calls) != 1 || !reflect.DeepEqual(calls[0], tt.expected) {
+				t.Errorf("Expected command %v, got %v", tt.expected, calls)
+			}
+		})
+	}
+}
+
+func TestLoadResolvesSecrets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+	t.Setenv("AWS_SECRET", "aws-secret-value")
+	t.Setenv("DEPLOY_KEY", "/keys/deploy")
+
+	writeFile(t, filepath.Join(home, "registry"), "registry-password\n")
+	path := filepath.Join(home, "cluster.yaml")
+	writeFile(t, path, `cloud:
+  aws:
+    access_key_id: AKIAEXAMPLE
+    secret_access_key: env:AWS_SECRET
+ssh:
+  key_path: env:DEPLOY_KEY
+  become_password: plain-password
+offline:
+  registry:
+    password: file:~/registry
+`)
+
+	cfg, err := LoadWithOptions(Options{File: path})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+
+	if cfg.Cloud.AWS.SecretAccessKey != "aws-secret-value" || cfg.SSH.KeyPath != "/keys/deploy" ||
+		cfg.Offline.Registry.Password != "registry-password" {
+		t.Errorf("Secrets not resolved: %+v %+v", cfg.Cloud.AWS, cfg.SSH)
+	}
+
+	expected := map[string]interface{}{
+		"cloud.aws.access_key_id":     "AKIAEXAMPLE",
+		"cloud.aws.secret_access_key": "env:AWS_SECRET",
+		"ssh.key_path":                "env:DEPLOY_KEY",
+		"ssh.become_password":         Redacted,
+		"offline.registry.password":   "file:~/registry",
+		"cloud.gcp.credentials":       "",
+	}
+	for _, s := range cfg.Settings() {
+		if want, ok := expected[s.Key]; ok && s.Value != want {
+			t.Errorf("Expected %s to display %v, got %v", s.Key, want, s.Value)
+		}
+	}
+
+	dump, err := yaml.Marshal(cfg.Map())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"aws-secret-value", "plain-password", "registry-password"} {
+		if strings.Contains(string(dump), secret) {
+			t.Errorf("Config dump leaks %q:\n%s", secret, dump)
+		}
+	}
+}
+
+func TestLoadSecretErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("KUBESPRAY_CLUSTER", "")
+	t.Setenv("KUBESPRAY_SSH_BECOME_PASSWORD", "env:UNSET_BECOME_PASSWORD")
+	os.Unsetenv("UNSET_BECOME_PASSWORD")
+
+	_, err := LoadWithOptions(Options{})
+	if err == nil {
+		t.Fatal("Expected error but got nil")
+	}
+	if !strings.Contains(err.Error(), "ssh.become_password") {
+		t.Errorf("Expected error to name the setting, got %v", err)
+	}
+}
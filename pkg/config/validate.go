@@ -0,0 +1,346 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NetworkPlugins are the kube_network_plugin values Validate accepts. They
+// match the plugins modelled by the network package.
+var NetworkPlugins = []string{"calico", "cilium", "flannel", "kube-ovn", "kube-router", "macvlan", "weave"}
+
+// Accepted values of the enumerated settings
+var (
+	logLevels       = []string{"debug", "info", "warn", "error"}
+	endpointModes   = []string{"localhost", "kube-vip", "external"}
+	localhostTypes  = []string{"nginx", "haproxy"}
+	hostnameSources = []string{"template", "dns", "ssh"}
+	hostRoles       = []string{"kube_control_plane", "kube_node", "etcd", "calico_rr"}
+	metalLBProtos   = []string{"layer2", "bgp"}
+)
+
+// versionPattern matches Kubernetes release versions such as v1.29.0
+var versionPattern = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+// FieldError is an invalid configuration value. File and Line locate the
+// value when it was read from a config file; they are empty for defaults and
+// values set through the environment.
+type FieldError struct {
+	// Field is the dotted key of the value, such as inventory.hosts[1].address
+	Field   string
+	Message string
+	File    string
+	Line    int
+	Column  int
+}
+
+// Error formats the error as file:line:column: field: message
+func (e FieldError) Error() string {
+	if e.File != "" && e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column, e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError lists every invalid value of a configuration
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error joins the field errors, one per line
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// File returns the config file the configuration was loaded from, or an
+// empty string when only defaults and the environment were used
+func (c *Config) File() string {
+	return c.file
+}
+
+// Validate checks every setting: enumerated values, versions, CIDRs and
+// addresses, ports, counts and paths. All problems are returned together as
+// a *ValidationError, each located in the config file when it came from one.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.oneOf("apiVersion", c.APIVersion, []string{CurrentAPIVersion})
+	v.oneOf("log_level", c.LogLevel, logLevels)
+	v.required("kubespray_path", c.KubesprayPath)
+
+	k8s := c.Kubernetes
+	if !versionPattern.MatchString(k8s.Version) {
+		v.errorf("kubernetes.version", "invalid Kubernetes version %q, expected vMAJOR.MINOR.PATCH", k8s.Version)
+	}
+	v.oneOf("kubernetes.network_plugin", k8s.NetworkPlugin, NetworkPlugins)
+	service := v.cidr("kubernetes.service_subnet", k8s.ServiceSubnet)
+	pods := v.cidr("kubernetes.pod_subnet", k8s.PodSubnet)
+	if service != nil && pods != nil && (service.Contains(pods.IP) || pods.Contains(service.IP)) {
+		v.errorf("kubernetes.pod_subnet", "%s overlaps service_subnet %s", k8s.PodSubnet, k8s.ServiceSubnet)
+	}
+	if pods != nil {
+		ones, bits := pods.Mask.Size()
+		if k8s.NodePrefix <= ones || k8s.NodePrefix > bits {
+			v.errorf("kubernetes.node_prefix", "must be between %d and %d for pod_subnet %s", ones+1, bits, k8s.PodSubnet)
+		}
+	}
+	v.positive("kubernetes.max_pods", k8s.MaxPods)
+
+	ep := c.APIEndpoint
+	v.oneOf("api_endpoint.mode", ep.Mode, endpointModes)
+	if ep.Mode == "localhost" {
+		v.oneOf("api_endpoint.localhost_type", ep.LocalhostType, localhostTypes)
+	}
+	v.port("api_endpoint.port", ep.Port)
+	if ep.Address != "" {
+		v.ip("api_endpoint.address", ep.Address)
+	}
+	if ep.Mode == "kube-vip" && ep.Address == "" {
+		v.errorf("api_endpoint.address", "is required in kube-vip mode")
+	}
+	if ep.Mode == "external" && ep.Address == "" && ep.Domain == "" {
+		v.errorf("api_endpoint.address", "address or domain is required in external mode")
+	}
+	if ep.Subnet != "" {
+		v.cidr("api_endpoint.subnet", ep.Subnet)
+	}
+
+	if c.MetalLB.Enabled {
+		v.required("metallb.range", c.MetalLB.Range)
+	}
+	if c.MetalLB.Range != "" {
+		v.addressRange("metallb.range", c.MetalLB.Range)
+	}
+	for i, r := range c.MetalLB.Exclude {
+		v.addressRange(fmt.Sprintf("metallb.exclude[%d]", i), r)
+	}
+	pools := map[string]bool{}
+	for i, p := range c.MetalLB.Pools {
+		field := fmt.Sprintf("metallb.pools[%d]", i)
+		if p.Name == "" {
+			v.errorf(field+".name", "is required")
+		} else if pools[p.Name] {
+			v.errorf(field+".name", "duplicate pool name %q", p.Name)
+		}
+		pools[p.Name] = true
+		v.positive(field+".size", p.Size)
+		// A pool without a protocol is announced over layer2
+		if p.Protocol != "" {
+			v.oneOf(field+".protocol", p.Protocol, metalLBProtos)
+		}
+	}
+	for i, p := range c.MetalLB.Peers {
+		field := fmt.Sprintf("metallb.peers[%d]", i)
+		v.ip(field+".address", p.Address)
+		v.asn(field+".asn", p.ASN)
+		v.asn(field+".my_asn", p.MyASN)
+	}
+
+	inv := c.Inventory
+	v.oneOf("inventory.hostnames.source", inv.Hostnames.Source, hostnameSources)
+	for i, addr := range inv.Masters {
+		v.ip(fmt.Sprintf("inventory.masters[%d]", i), addr)
+	}
+	for i, addr := range inv.Nodes {
+		v.ip(fmt.Sprintf("inventory.nodes[%d]", i), addr)
+	}
+	for i, addr := range inv.Etcd {
+		v.ip(fmt.Sprintf("inventory.etcd[%d]", i), addr)
+	}
+	for i, h := range inv.Hosts {
+		field := fmt.Sprintf("inventory.hosts[%d]", i)
+		v.required(field+".address", h.Address)
+		if h.IP != "" {
+			v.ip(field+".ip", h.IP)
+		}
+		if h.AccessIP != "" {
+			v.ip(field+".access_ip", h.AccessIP)
+		}
+		for j, role := range h.Roles {
+			v.oneOf(fmt.Sprintf("%s.roles[%d]", field, j), role, hostRoles)
+		}
+	}
+
+	v.required("ssh.user", c.SSH.User)
+	v.required("ssh.key_path", c.SSH.KeyPath)
+	v.port("ssh.port", c.SSH.Port)
+
+	v.positive("offline.workers", c.Offline.Workers)
+	if c.Offline.RetryCount < 0 {
+		v.errorf("offline.retry_count", "must not be negative, got %d", c.Offline.RetryCount)
+	}
+	v.port("offline.registry.port", c.Offline.Registry.Port)
+	v.absolute("offline.registry.storage_path", c.Offline.Registry.StoragePath)
+	if c.Offline.Cache.Enabled {
+		v.absolute("offline.cache.path", c.Offline.Cache.Path)
+	}
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+	if err := c.locate(v.errors); err != nil {
+		return err
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// validator collects field errors
+type validator struct {
+	errors []FieldError
+}
+
+func (v *validator) errorf(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.errorf(field, "is required")
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed []string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.errorf(field, "invalid value %q (allowed: %s)", value, strings.Join(allowed, ", "))
+}
+
+func (v *validator) cidr(field, value string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		v.errorf(field, "invalid CIDR %q", value)
+		return nil
+	}
+	return ipNet
+}
+
+func (v *validator) ip(field, value string) {
+	if net.ParseIP(value) == nil {
+		v.errorf(field, "invalid IP address %q", value)
+	}
+}
+
+// addressRange accepts a single address, a CIDR or a start-end range
+func (v *validator) addressRange(field, value string) {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return
+	}
+	start, end, found := strings.Cut(value, "-")
+	if net.ParseIP(strings.TrimSpace(start)) != nil && (!found || net.ParseIP(strings.TrimSpace(end)) != nil) {
+		return
+	}
+	v.errorf(field, "invalid address range %q, expected an address, a CIDR or start-end", value)
+}
+
+func (v *validator) port(field string, value int) {
+	if value < 1 || value > 65535 {
+		v.errorf(field, "invalid port %d", value)
+	}
+}
+
+func (v *validator) positive(field string, value int) {
+	if value <= 0 {
+		v.errorf(field, "must be positive, got %d", value)
+	}
+}
+
+func (v *validator) asn(field string, value int) {
+	if value < 1 || int64(value) > 4294967295 {
+		v.errorf(field, "invalid AS number %d", value)
+	}
+}
+
+func (v *validator) absolute(field, value string) {
+	if !filepath.IsAbs(value) {
+		v.errorf(field, "must be an absolute path, got %q", value)
+	}
+}
+
+// locate fills in the file position of each error whose value was set in
+// the global config file or the cluster profile. A value missing from a list
+// entry is located at the entry.
+func (c *Config) locate(errs []FieldError) error {
+	positions := map[string]map[string]*yaml.Node{}
+	for i := range errs {
+		origin := c.Origin(errs[i].Field)
+		if origin.Source != SourceFile && origin.Source != SourceCluster {
+			continue
+		}
+
+		file := origin.Detail
+		if _, ok := positions[file]; !ok {
+			nodes, err := fileNodes(file)
+			if err != nil {
+				return err
+			}
+			positions[file] = nodes
+		}
+
+		field := strings.ToLower(errs[i].Field)
+		n, ok := positions[file][field]
+		for !ok && strings.Contains(field, "].") {
+			field = field[:strings.LastIndex(field, "].")+1]
+			n, ok = positions[file][field]
+		}
+		if ok {
+			errs[i].File = file
+			errs[i].Line = n.Line
+			errs[i].Column = n.Column
+		}
+	}
+	return nil
+}
+
+// fileNodes returns the value nodes of a config file by dotted field
+func fileNodes(file string) (map[string]*yaml.Node, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	positions := map[string]*yaml.Node{}
+	if len(root.Content) > 0 {
+		indexNodes(root.Content[0], "", positions)
+	}
+	return positions, nil
+}
+
+// indexNodes records the value node of every key under its dotted field
+// path. Keys are lowercased, as viper does when loading.
+func indexNodes(n *yaml.Node, path string, positions map[string]*yaml.Node) {
+	if path != "" {
+		positions[path] = n
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := strings.ToLower(n.Content[i].Value)
+			if path != "" {
+				key = path + "." + key
+			}
+			indexNodes(n.Content[i+1], key, positions)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			indexNodes(item, path+"["+strconv.Itoa(i)+"]", positions)
+		}
+	}
+}
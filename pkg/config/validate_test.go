@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	if err := NewConfig().Validate(); err != nil {
+		t.Errorf("Expected defaults to be valid, got: %v", err)
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		expected []string
+	}{
+		{
+			name:     "Unknown network plugin",
+			modify:   func(c *Config) { c.Kubernetes.NetworkPlugin = "canal" },
+			expected: []string{"kubernetes.network_plugin"},
+		},
+		{
+			name:     "Malformed version",
+			modify:   func(c *Config) { c.Kubernetes.Version = "1.29" },
+			expected: []string{"kubernetes.version"},
+		},
+		{
+			name: "Bad subnets",
+			modify: func(c *Config) {
+				c.Kubernetes.ServiceSubnet = "10.233.0.0/33"
+				c.Kubernetes.PodSubnet = "10.0.0.0/8"
+				c.Kubernetes.NodePrefix = 8
+			},
+			expected: []string{"kubernetes.service_subnet", "kubernetes.node_prefix"},
+		},
+		{
+			name: "Overlapping subnets",
+			modify: func(c *Config) {
+				c.Kubernetes.ServiceSubnet = "10.233.0.0/16"
+			},
+			expected: []string{"kubernetes.pod_subnet"},
+		},
+		{
+			name: "Negative workers and bad ports",
+			modify: func(c *Config) {
+				c.Offline.Workers = -1
+				c.Offline.RetryCount = -2
+				c.SSH.Port = 0
+				c.APIEndpoint.Port = 70000
+			},
+			expected: []string{"api_endpoint.port", "ssh.port", "offline.workers", "offline.retry_count"},
+		},
+		{
+			name: "kube-vip without address",
+			modify: func(c *Config) {
+				c.APIEndpoint.Mode = "kube-vip"
+			},
+			expected: []string{"api_endpoint.address"},
+		},
+		{
+			name: "MetalLB",
+			modify: func(c *Config) {
+				c.MetalLB.Enabled = true
+				c.MetalLB.Exclude = []string{"192.168.1.1-192.168.1.x"}
+				c.MetalLB.Pools = []MetalLBPool{{Name: "a", Size: 1, Protocol: "layer2"}, {Name: "a", Size: 0, Protocol: "arp"}, {Name: "b", Size: 2}}
+				c.MetalLB.Peers = []MetalLBPeer{{Name: "tor", Address: "192.168.1.1", ASN: 64512}}
+			},
+			expected: []string{
+				"metallb.range", "metallb.exclude[0]",
+				"metallb.pools[1].name", "metallb.pools[1].size", "metallb.pools[1].protocol",
+				"metallb.peers[0].my_asn",
+			},
+		},
+		{
+			name: "Inventory",
+			modify: func(c *Config) {
+				c.Inventory.Hostnames.Source = "mdns"
+				c.Inventory.Nodes = []string{"10.0.0.1", "node-2"}
+				c.Inventory.Hosts = []HostConfig{{IP: "10.0.0.300", Roles: []string{"worker"}}}
+			},
+			expected: []string{
+				"inventory.hostnames.source", "inventory.nodes[1]",
+				"inventory.hosts[0].address", "inventory.hosts[0].ip", "inventory.hosts[0].roles[0]",
+			},
+		},
+		{
+			name: "Paths and enums",
+			modify: func(c *Config) {
+				c.LogLevel = "verbose"
+				c.Offline.Cache.Path = "cache"
+				c.APIEndpoint.LocalhostType = "envoy"
+			},
+			expected: []string{"log_level", "api_endpoint.localhost_type", "offline.cache.path"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Expected *ValidationError, got %v", err)
+			}
+
+			fields := []string{}
+			for _, fe := range verr.Errors {
+				fields = append(fields, fe.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.expected) {
+				t.Errorf("Expected errors for %v, got %v", tt.expected, verr)
+			}
+		})
+	}
+}
+
+func TestValidateMetalLBPoolWithoutProtocol(t *testing.T) {
+	cfg := NewConfig()
+	cfg.MetalLB.Enabled = true
+	cfg.MetalLB.Range = "192.168.1.240-192.168.1.250"
+	cfg.MetalLB.Pools = []MetalLBPool{{Name: "default", Size: 4}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a pool without protocol to be valid, got: %v", err)
+	}
+}
+
+func TestValidateLocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	content := `kubernetes:
+  network_plugin: canal
+  service_subnet: 10.233.0.0/18
+offline:
+  workers: -5
+inventory:
+  hosts:
+    - name: node-1
+      roles: [kube_node]
+    - name: node-2
+      address: 10.0.0.2
+      ip: not-an-ip
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cfg.SSH.User = ""
+
+	err = cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+
+	expected := []string{
+		path + ":2:19: kubernetes.network_plugin: invalid value \"canal\" (allowed: calico, cilium, flannel, kube-ovn, kube-router, macvlan, weave)",
+		path + ":8:7: inventory.hosts[0].address: is required",
+		path + ":12:11: inventory.hosts[1].ip: invalid IP address \"not-an-ip\"",
+		"ssh.user: is required",
+		path + ":5:12: offline.workers: must be positive, got -5",
+	}
+	got := []string{}
+	for _, fe := range verr.Errors {
+		got = append(got, fe.Error())
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected errors:\n got %q\nwant %q", got, expected)
+	}
+}
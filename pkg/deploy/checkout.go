@@ -0,0 +1,109 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// Kubespray source used when no checkout exists yet
+const (
+	DefaultRepository = "https://github.com/kubernetes-sigs/kubespray.git"
+	DefaultVersion    = "v2.25.0"
+)
+
+// CheckoutDir returns the directory of the Kubespray checkout,
+// <kubespray_path>/src. It is kept apart from the cluster profiles, runs,
+// logs and rendered inventories under kubespray_path, so git never touches
+// them.
+func CheckoutDir(cfg *config.Config) string {
+	return filepath.Join(cfg.KubesprayPath, "src")
+}
+
+// prepareCheckout makes dir a Kubespray checkout at version. An existing
+// checkout is used as it is when version is empty. Otherwise the version is
+// checked out, fetching it from repository unless it is already available.
+// The checkout is created with git init and fetch rather than clone, so a
+// shallow fetch of a commit works too.
+func (r *Runner) prepareCheckout(ctx context.Context, dir, repository, version string) error {
+	_, gitErr := os.Stat(filepath.Join(dir, ".git"))
+	if version == "" {
+		if gitErr == nil || fileExists(filepath.Join(dir, "cluster.yml")) {
+			return nil
+		}
+		version = DefaultVersion
+	}
+	if repository == "" {
+		repository = DefaultRepository
+	}
+
+	if gitErr != nil {
+		if fileExists(filepath.Join(dir, "cluster.yml")) {
+			return fmt.Errorf("%s holds a Kubespray tree that is not a git checkout; remove it or deploy without a version", dir)
+		}
+		fmt.Fprintf(r.out, "Fetching Kubespray %s into %s\n", version, dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if _, err := r.git(ctx, dir, "init", "--quiet"); err != nil {
+			return err
+		}
+		if _, err := r.git(ctx, dir, "remote", "add", "origin", repository); err != nil {
+			return err
+		}
+		return r.fetchVersion(ctx, dir, version)
+	}
+
+	head, err := r.git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	if commit, err := r.git(ctx, dir, "rev-parse", "--verify", "--quiet", version+"^{commit}"); err == nil {
+		if commit == head {
+			return nil
+		}
+		fmt.Fprintf(r.out, "Checking out Kubespray %s in %s\n", version, dir)
+		_, err := r.git(ctx, dir, "checkout", "--quiet", commit)
+		return err
+	}
+	fmt.Fprintf(r.out, "Fetching Kubespray %s into %s\n", version, dir)
+	return r.fetchVersion(ctx, dir, version)
+}
+
+// fetchVersion fetches a tag, branch or commit from origin and checks it out
+func (r *Runner) fetchVersion(ctx context.Context, dir, version string) error {
+	if _, err := r.git(ctx, dir, "fetch", "--quiet", "--depth", "1", "origin", version); err != nil {
+		return err
+	}
+	_, err := r.git(ctx, dir, "checkout", "--quiet", "FETCH_HEAD")
+	return err
+}
+
+// git runs a git command in dir and returns its trimmed output
+func (r *Runner) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, r.gitCmd, args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
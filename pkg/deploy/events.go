@@ -0,0 +1,225 @@
+package deploy
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EventType identifies what happened in a playbook run
+type EventType string
+
+// Event types, in the order they appear in a run
+const (
+	EventPlayStart  EventType = "play_start"
+	EventTaskStart  EventType = "task_start"
+	EventHostResult EventType = "host_result"
+	EventRecap      EventType = "recap"
+)
+
+// Host result statuses
+const (
+	StatusOK          = "ok"
+	StatusChanged     = "changed"
+	StatusSkipped     = "skipped"
+	StatusFailed      = "failed"
+	StatusUnreachable = "unreachable"
+	// StatusIgnored is a failure the task ignores with ignore_errors
+	StatusIgnored = "ignored"
+)
+
+// Event is a step of a playbook run parsed from ansible-playbook output
+type Event struct {
+	Type EventType
+	// Play and Task name the play and task the event belongs to. Task is
+	// written "role : name" for role tasks.
+	Play string
+	Task string
+	// Handler is set for tasks run as handlers
+	Handler bool
+	// Host, Status, Item and Message describe a host result; Item is the
+	// loop item, if any, and Message the module message of failures
+	Host    string
+	Status  string
+	Item    string
+	Message string
+	// Stats are the task counts of Host in a recap event
+	Stats HostStats
+}
+
+// Failed reports whether the event is a host failure that stops the host
+func (e Event) Failed() bool {
+	return e.Type == EventHostResult && (e.Status == StatusFailed || e.Status == StatusUnreachable)
+}
+
+var (
+	playLine    = regexp.MustCompile(`^PLAY \[(.*)\] \**$`)
+	taskLine    = regexp.MustCompile(`^(TASK|RUNNING HANDLER) \[(.*)\] \**$`)
+	hostLine    = regexp.MustCompile(`^(ok|changed|skipping|fatal|failed): \[([^\]]+)\](.*)$`)
+	itemPattern = regexp.MustCompile(`\(item=(.*?)\)`)
+	ansiEscape  = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+)
+
+// eventParser turns the lines of the default Ansible callback output into
+// events. A failure is held back for a line, since Ansible reports ignored
+// errors with a following "...ignoring".
+type eventParser struct {
+	emit    func(Event)
+	play    string
+	task    string
+	handler bool
+	inRecap bool
+	pending *Event
+}
+
+func (p *eventParser) line(line string) {
+	line = ansiEscape.ReplaceAllString(line, "")
+	if p.pending != nil {
+		e := *p.pending
+		p.pending = nil
+		if strings.TrimSpace(line) == "...ignoring" {
+			e.Status = StatusIgnored
+			p.emit(e)
+			return
+		}
+		p.emit(e)
+	}
+
+	if m := playLine.FindStringSubmatch(line); m != nil {
+		p.play, p.task, p.inRecap = m[1], "", false
+		p.emit(Event{Type: EventPlayStart, Play: p.play})
+		return
+	}
+	if m := taskLine.FindStringSubmatch(line); m != nil {
+		p.task, p.handler, p.inRecap = m[2], m[1] == "RUNNING HANDLER", false
+		p.emit(Event{Type: EventTaskStart, Play: p.play, Task: p.task, Handler: p.handler})
+		return
+	}
+	if strings.HasPrefix(line, "PLAY RECAP") {
+		p.inRecap = true
+		return
+	}
+	if p.inRecap {
+		p.recapLine(line)
+		return
+	}
+	if m := hostLine.FindStringSubmatch(line); m != nil {
+		p.hostResult(m[1], m[2], m[3])
+	}
+}
+
+// hostResult handles a per-host result line such as
+//
+//	fatal: [node-0]: FAILED! => {"changed": false, "msg": "..."}
+func (p *eventParser) hostResult(status, host, rest string) {
+	e := Event{
+		Type:    EventHostResult,
+		Play:    p.play,
+		Task:    p.task,
+		Handler: p.handler,
+		Host:    host,
+	}
+	// Delegated tasks name the delegate as "host -> delegate"
+	if name, _, ok := strings.Cut(host, " -> "); ok {
+		e.Host = name
+	}
+
+	switch status {
+	case "skipping":
+		e.Status = StatusSkipped
+	case "fatal", "failed":
+		e.Status = StatusFailed
+		if strings.HasPrefix(rest, ": UNREACHABLE!") {
+			e.Status = StatusUnreachable
+		}
+	default:
+		e.Status = status
+	}
+
+	if m := itemPattern.FindStringSubmatch(rest); m != nil {
+		e.Item = m[1]
+	}
+	if _, result, ok := strings.Cut(rest, "=> "); ok && (e.Status == StatusFailed || e.Status == StatusUnreachable) {
+		e.Message = resultMessage(result)
+	}
+
+	if e.Status == StatusFailed {
+		p.pending = &e
+		return
+	}
+	p.emit(e)
+}
+
+// recapLine handles a PLAY RECAP line:
+//
+//	node1 : ok=20 changed=5 unreachable=0 failed=0 skipped=3 rescued=0 ignored=0
+func (p *eventParser) recapLine(line string) {
+	name, counts, ok := strings.Cut(line, " : ")
+	if !ok {
+		p.inRecap = strings.TrimSpace(line) == ""
+		return
+	}
+
+	var s HostStats
+	for _, field := range strings.Fields(counts) {
+		key, value, _ := strings.Cut(field, "=")
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "ok":
+			s.OK = n
+		case "changed":
+			s.Changed = n
+		case "unreachable":
+			s.Unreachable = n
+		case "failed":
+			s.Failed = n
+		case "skipped":
+			s.Skipped = n
+		case "rescued":
+			s.Rescued = n
+		case "ignored":
+			s.Ignored = n
+		}
+	}
+	p.emit(Event{Type: EventRecap, Play: p.play, Host: strings.TrimSpace(name), Stats: s})
+}
+
+// flush emits a failure still held back at the end of the output
+func (p *eventParser) flush() {
+	if p.pending != nil {
+		p.emit(*p.pending)
+		p.pending = nil
+	}
+}
+
+// resultMessage extracts the message of a module result printed as JSON,
+// falling back to the raw text
+func resultMessage(result string) string {
+	var r struct {
+		Msg    interface{} `json:"msg"`
+		Stderr string      `json:"stderr"`
+	}
+	if err := json.Unmarshal([]byte(result), &r); err != nil {
+		return strings.TrimSpace(result)
+	}
+	msg := ""
+	switch m := r.Msg.(type) {
+	case string:
+		msg = m
+	case nil:
+	default:
+		data, _ := json.Marshal(m)
+		msg = string(data)
+	}
+	if r.Stderr != "" && msg != r.Stderr {
+		if msg != "" {
+			msg += ": "
+		}
+		msg += strings.TrimSpace(r.Stderr)
+	}
+	return msg
+}
@@ -0,0 +1,88 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventParser(t *testing.T) {
+	output := "PLAY [Prepare nodes] ***********************************************************\n" +
+		"\n" +
+		"TASK [bootstrap-os : Fetch /etc/os-release] ************************************\n" +
+		"\x1b[0;32mok: [master-0]\x1b[0m\n" +
+		"changed: [node-0 -> master-0]\n" +
+		"skipping: [node-1]\n" +
+		"\n" +
+		"TASK [kubernetes/preinstall : Stop if swap enabled] ****************************\n" +
+		"failed: [node-0] (item=/dev/sda2) => {\"ansible_loop_var\": \"item\", \"changed\": false, \"item\": \"/dev/sda2\", \"msg\": \"Swap is enabled\"}\n" +
+		"fatal: [node-1]: FAILED! => {\"changed\": true, \"cmd\": \"swapoff -a\", \"msg\": \"non-zero return code\", \"rc\": 1, \"stderr\": \"swapoff: permission denied\"}\n" +
+		"...ignoring\n" +
+		"fatal: [node-2]: UNREACHABLE! => {\"changed\": false, \"msg\": \"Failed to connect to the host via ssh\", \"unreachable\": true}\n" +
+		"\n" +
+		"RUNNING HANDLER [container-engine/containerd : Restart containerd] *************\n" +
+		"fatal: [master-0]: FAILED! => {\"msg\": [\"a\", \"b\"]}\n" +
+		"\n" +
+		"PLAY RECAP *********************************************************************\n" +
+		"master-0                   : ok=1    changed=0    unreachable=0    failed=1    skipped=0    rescued=0    ignored=0\n" +
+		"node-0                     : ok=0    changed=1    unreachable=0    failed=1    skipped=0    rescued=0    ignored=0\n" +
+		"\n" +
+		"Friday 18 October 2026  10:00:00 +0000 (0:00:00.050)       0:25:41.123 *******\n" +
+		"node-9 : ok=1"
+
+	var events []Event
+	p := &eventParser{emit: func(e Event) { events = append(events, e) }}
+	w := &lineWriter{fn: p.line}
+	// Output arrives in arbitrary chunks
+	for i := 0; i < len(output); i += 7 {
+		w.Write([]byte(output[i:min(i+7, len(output))]))
+	}
+	w.Flush()
+	p.flush()
+
+	play := "Prepare nodes"
+	fetch := "bootstrap-os : Fetch /etc/os-release"
+	swap := "kubernetes/preinstall : Stop if swap enabled"
+	restart := "container-engine/containerd : Restart containerd"
+	expected := []Event{
+		{Type: EventPlayStart, Play: play},
+		{Type: EventTaskStart, Play: play, Task: fetch},
+		{Type: EventHostResult, Play: play, Task: fetch, Host: "master-0", Status: StatusOK},
+		{Type: EventHostResult, Play: play, Task: fetch, Host: "node-0", Status: StatusChanged},
+		{Type: EventHostResult, Play: play, Task: fetch, Host: "node-1", Status: StatusSkipped},
+		{Type: EventTaskStart, Play: play, Task: swap},
+		{Type: EventHostResult, Play: play, Task: swap, Host: "node-0", Status: StatusFailed, Item: "/dev/sda2", Message: "Swap is enabled"},
+		{Type: EventHostResult, Play: play, Task: swap, Host: "node-1", Status: StatusIgnored, Message: "non-zero return code: swapoff: permission denied"},
+		{Type: EventHostResult, Play: play, Task: swap, Host: "node-2", Status: StatusUnreachable, Message: "Failed to connect to the host via ssh"},
+		{Type: EventTaskStart, Play: play, Task: restart, Handler: true},
+		{Type: EventHostResult, Play: play, Task: restart, Handler: true, Host: "master-0", Status: StatusFailed, Message: `["a","b"]`},
+		{Type: EventRecap, Play: play, Host: "master-0", Stats: HostStats{OK: 1, Failed: 1}},
+		{Type: EventRecap, Play: play, Host: "node-0", Stats: HostStats{Changed: 1, Failed: 1}},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(events[i], expected[i]) {
+			t.Errorf("Event %d:\n got %+v\nwant %+v", i, events[i], expected[i])
+		}
+	}
+}
+
+func TestEventFailed(t *testing.T) {
+	tests := []struct {
+		event    Event
+		expected bool
+	}{
+		{Event{Type: EventHostResult, Status: StatusFailed}, true},
+		{Event{Type: EventHostResult, Status: StatusUnreachable}, true},
+		{Event{Type: EventHostResult, Status: StatusIgnored}, false},
+		{Event{Type: EventHostResult, Status: StatusOK}, false},
+		{Event{Type: EventRecap, Stats: HostStats{Failed: 1}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.event.Failed(); got != tt.expected {
+			t.Errorf("Failed() of %+v = %v, want %v", tt.event, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vjranagit/kubespray/pkg/inventory"
+)
+
+// Kubespray playbooks of the cluster lifecycle operations
+const (
+	ScalePlaybook      = "scale.yml"
+	RemoveNodePlaybook = "remove-node.yml"
+	UpgradePlaybook    = "upgrade-cluster.yml"
+	ResetPlaybook      = "reset.yml"
+)
+
+// Scale adds worker nodes to the cluster with scale.yml. hosts are the names
+// or addresses of the new nodes, which must already be in the inventory; the
+// run is limited to them.
+func (r *Runner) Scale(ctx context.Context, hosts []string, opts Options) (*Result, error) {
+	opts.Playbook = ScalePlaybook
+	return r.run(ctx, opts, func(inv *inventory.AnsibleInventory, opts *Options) error {
+		names, err := lookupHosts(inv, hosts)
+		if err != nil {
+			return err
+		}
+		opts.Limit = names
+		return nil
+	})
+}
+
+// RemoveOptions control how nodes are removed
+type RemoveOptions struct {
+	// DrainTimeout and DrainGracePeriod bound the drain of every node. The
+	// Kubespray defaults apply when they are zero.
+	DrainTimeout     time.Duration
+	DrainGracePeriod time.Duration
+	// Unreachable removes nodes that can no longer be reached: they are
+	// deleted from the cluster without being drained or reset
+	Unreachable bool
+}
+
+// RemoveNodes drains the nodes named by hosts, removes them from the cluster
+// and resets them with remove-node.yml. The hosts stay in the inventory
+// during the run; the caller removes them once it succeeds. Kubespray cannot
+// remove the first control plane node.
+func (r *Runner) RemoveNodes(ctx context.Context, hosts []string, remove RemoveOptions, opts Options) (*Result, error) {
+	opts.Playbook = RemoveNodePlaybook
+	return r.run(ctx, opts, func(inv *inventory.AnsibleInventory, opts *Options) error {
+		names, err := lookupHosts(inv, hosts)
+		if err != nil {
+			return err
+		}
+		if cp := inv.Members(inventory.RoleControlPlane, "kube-master"); len(cp) > 0 {
+			for _, name := range names {
+				if name == cp[0] {
+					return fmt.Errorf("%s is the first control plane node, which Kubespray cannot remove; move it down the inventory first", name)
+				}
+			}
+		}
+
+		// The confirmation prompt of the playbook is skipped, as it cannot
+		// be answered; callers confirm the removal themselves
+		vars := map[string]interface{}{
+			"node":              strings.Join(names, ","),
+			"skip_confirmation": true,
+		}
+		if remove.DrainTimeout > 0 {
+			vars["drain_timeout"] = fmt.Sprintf("%ds", int(remove.DrainTimeout.Seconds()))
+		}
+		if remove.DrainGracePeriod > 0 {
+			vars["drain_grace_period"] = int(remove.DrainGracePeriod.Seconds())
+		}
+		if remove.Unreachable {
+			vars["reset_nodes"] = false
+			vars["allow_ungraceful_removal"] = true
+		}
+		opts.ExtraVars = withVars(vars, opts.ExtraVars)
+		return nil
+	})
+}
+
+// Upgrade upgrades the cluster to Kubernetes version with
+// upgrade-cluster.yml, serial nodes at a time. The configuration is checked
+// with ValidateUpgrade first.
+func (r *Runner) Upgrade(ctx context.Context, version string, serial int, opts Options) (*Result, error) {
+	if err := ValidateUpgrade(r.config.Kubernetes.Version, version); err != nil {
+		return nil, err
+	}
+	if serial < 1 {
+		return nil, fmt.Errorf("invalid serial %d: at least one node must be upgraded at a time", serial)
+	}
+	opts.Playbook = UpgradePlaybook
+	opts.ExtraVars = withVars(map[string]interface{}{"kube_version": version, "serial": serial}, opts.ExtraVars)
+	return r.run(ctx, opts, nil)
+}
+
+// Reset removes Kubernetes, etcd and their data from the hosts of the
+// cluster with reset.yml. It cannot be undone; callers confirm it first.
+func (r *Runner) Reset(ctx context.Context, opts Options) (*Result, error) {
+	opts.Playbook = ResetPlaybook
+	opts.ExtraVars = withVars(map[string]interface{}{"reset_confirmation": "yes", "skip_confirmation": true}, opts.ExtraVars)
+	return r.run(ctx, opts, nil)
+}
+
+// releaseVersion matches Kubernetes release versions such as v1.29.0
+var releaseVersion = regexp.MustCompile(`^v(\d+)\.(\d+)\.(\d+)$`)
+
+// ValidateUpgrade checks that a cluster running Kubernetes from can be
+// upgraded to to: both are release versions and to is newer, of the same
+// major version and at most one minor version ahead, as Kubernetes does not support skipping minor
+// versions
+func ValidateUpgrade(from, to string) error {
+	target, ok := parseVersion(to)
+	if !ok {
+		return fmt.Errorf("invalid Kubernetes version %q: expected a release such as v1.29.3", to)
+	}
+	current, ok := parseVersion(from)
+	if !ok {
+		return fmt.Errorf("invalid current Kubernetes version %q in kubernetes.version", from)
+	}
+
+	switch {
+	case target == current:
+		return fmt.Errorf("cluster is already at %s", from)
+	case target[0] != current[0]:
+		return fmt.Errorf("cannot upgrade from %s to %s: changing the major version is not supported", from, to)
+	case target[1] < current[1] || target[1] == current[1] && target[2] < current[2]:
+		return fmt.Errorf("cannot upgrade from %s to %s: downgrades are not supported", from, to)
+	case target[1] > current[1]+1:
+		return fmt.Errorf("cannot upgrade from %s to %s: upgrade one minor version at a time, to v%d.%d first", from, to, current[0], current[1]+1)
+	}
+	return nil
+}
+
+// parseVersion returns the major, minor and patch numbers of a release
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	m := releaseVersion.FindStringSubmatch(v)
+	if m == nil {
+		return parts, false
+	}
+	for i := range parts {
+		parts[i], _ = strconv.Atoi(m[i+1])
+	}
+	return parts, true
+}
+
+// lookupHosts returns the inventory names of hosts given by name or address
+func lookupHosts(inv *inventory.AnsibleInventory, hosts []string) ([]string, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts given")
+	}
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		name, ok := inv.Lookup(host)
+		if !ok {
+			return nil, fmt.Errorf("host %s not found in inventory", host)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// withVars returns the variables of an operation with the extra vars given
+// by the user layered over them
+func withVars(vars, extra map[string]interface{}) map[string]interface{} {
+	for k, v := range extra {
+		vars[k] = v
+	}
+	return vars
+}
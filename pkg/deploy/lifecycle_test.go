@@ -0,0 +1,147 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateUpgrade(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		// err is part of the expected error, or empty when none is expected
+		err string
+	}{
+		{"v1.29.0", "v1.29.5", ""},
+		{"v1.29.3", "v1.30.0", ""},
+		{"v1.29.3", "v1.31.0", "one minor version at a time"},
+		{"v1.29.3", "v1.29.3", "already at v1.29.3"},
+		{"v1.29.3", "v1.29.1", "downgrades are not supported"},
+		{"v1.29.3", "v1.28.9", "downgrades are not supported"},
+		{"v1.29.3", "v2.0.0", "changing the major version is not supported"},
+		{"v2.0.0", "v1.29.3", "changing the major version is not supported"},
+		{"v1.29.3", "1.30.0", "invalid Kubernetes version"},
+		{"latest", "v1.30.0", "invalid current Kubernetes version"},
+	}
+
+	for _, tt := range tests {
+		err := ValidateUpgrade(tt.from, tt.to)
+		if tt.err == "" && err != nil {
+			t.Errorf("ValidateUpgrade(%s, %s): unexpected error: %v", tt.from, tt.to, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("ValidateUpgrade(%s, %s): expected error containing %q, got %v", tt.from, tt.to, tt.err, err)
+		}
+	}
+}
+
+// recordedRun returns the arguments and extra vars the fake playbook ran with
+func recordedRun(t *testing.T, record string) ([]string, map[string]interface{}) {
+	t.Helper()
+	data, _ := os.ReadFile(filepath.Join(record, "args"))
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	vars := map[string]interface{}{}
+	if data, err := os.ReadFile(filepath.Join(record, "vars")); err == nil {
+		if err := json.Unmarshal(data, &vars); err != nil {
+			t.Fatalf("Invalid extra vars %q: %v", data, err)
+		}
+	}
+	os.Remove(filepath.Join(record, "vars"))
+	return args, vars
+}
+
+func TestLifecycle(t *testing.T) {
+	upstream := newUpstream(t, "v2.25.0")
+	r, cfg, record, out := newTestRunner(t, "0", "")
+	ctx := context.Background()
+	opts := Options{Repository: upstream, Version: "v2.25.0", ExtraVars: map[string]interface{}{"serial": 2}}
+
+	tests := []struct {
+		name     string
+		run      func() (*Result, error)
+		playbook string
+		limit    string
+		vars     map[string]interface{}
+	}{
+		{
+			name:     "scale",
+			run:      func() (*Result, error) { return r.Scale(ctx, []string{"10.0.0.3"}, Options{Repository: upstream}) },
+			playbook: ScalePlaybook,
+			limit:    "node-1",
+			vars:     map[string]interface{}{},
+		},
+		{
+			name: "remove node",
+			run: func() (*Result, error) {
+				return r.RemoveNodes(ctx, []string{"node-0", "10.0.0.3"}, RemoveOptions{DrainTimeout: 2 * time.Minute, Unreachable: true}, Options{Repository: upstream})
+			},
+			playbook: RemoveNodePlaybook,
+			vars: map[string]interface{}{
+				"node": "node-0,node-1", "skip_confirmation": true, "drain_timeout": "120s",
+				"reset_nodes": false, "allow_ungraceful_removal": true,
+			},
+		},
+		{
+			name:     "upgrade",
+			run:      func() (*Result, error) { return r.Upgrade(ctx, "v1.30.1", 1, opts) },
+			playbook: UpgradePlaybook,
+			vars:     map[string]interface{}{"kube_version": "v1.30.1", "serial": float64(2)},
+		},
+		{
+			name:     "reset",
+			run:      func() (*Result, error) { return r.Reset(ctx, Options{Repository: upstream}) },
+			playbook: ResetPlaybook,
+			vars:     map[string]interface{}{"reset_confirmation": "yes", "skip_confirmation": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.run()
+			if err != nil {
+				t.Fatalf("Run failed: %v\n%s", err, out)
+			}
+			if result.Playbook != tt.playbook {
+				t.Errorf("Expected playbook %s, got %s", tt.playbook, result.Playbook)
+			}
+			args, vars := recordedRun(t, record)
+			if args[len(args)-1] != tt.playbook {
+				t.Errorf("Expected %s to run, got %q", tt.playbook, args)
+			}
+			limit := ""
+			for i, arg := range args {
+				if arg == "--limit" {
+					limit = args[i+1]
+				}
+			}
+			if limit != tt.limit {
+				t.Errorf("Expected limit %q, got %q", tt.limit, limit)
+			}
+			if !reflect.DeepEqual(vars, tt.vars) {
+				t.Errorf("Expected extra vars %v, got %v", tt.vars, vars)
+			}
+		})
+	}
+
+	if _, err := r.Scale(ctx, []string{"10.0.0.9"}, Options{Repository: upstream}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected unknown host error, got %v", err)
+	}
+	if _, err := r.RemoveNodes(ctx, []string{"master-0"}, RemoveOptions{}, Options{Repository: upstream}); err == nil || !strings.Contains(err.Error(), "first control plane") {
+		t.Errorf("Expected first control plane error, got %v", err)
+	}
+	if _, err := r.Upgrade(ctx, "v1.31.0", 1, Options{Repository: upstream}); err == nil {
+		t.Error("Expected error upgrading two minor versions")
+	}
+	if _, err := r.Upgrade(ctx, "v1.30.0", 0, Options{Repository: upstream}); err == nil {
+		t.Error("Expected error with serial 0")
+	}
+	if cfg.Kubernetes.Version != "v1.29.0" {
+		t.Errorf("Expected the config version to stay v1.29.0, got %s", cfg.Kubernetes.Version)
+	}
+}
@@ -0,0 +1,275 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/vjranagit/kubespray/pkg/inventory"
+)
+
+// Plan describes what a run would do. Making it changes no host and leaves
+// the Kubespray checkout and the inventory of the last run as they are.
+type Plan struct {
+	Playbook string
+	// Checkout describes what the run does to the Kubespray checkout
+	Checkout string
+	// Inventory is the inventory the playbook would run against. An
+	// inventory rendered from the configuration lives in a temporary
+	// directory until Close.
+	Inventory string
+	// HostsFile is the content of the hosts file of the inventory
+	HostsFile []byte
+	// Hosts are the hosts the run covers, in inventory order
+	Hosts []string
+	// Nodes is the number of Kubernetes nodes in the inventory
+	Nodes       int
+	Limit       []string
+	Tags        []string
+	StartAtTask string
+	ExtraVars   map[string]interface{}
+	// Args are the ansible-playbook arguments of the run, without the extra
+	// vars file
+	Args []string
+	// Changes are the rendered inventory files that differ from those of
+	// the last run, in path order
+	Changes []FileChange
+
+	tmp string
+}
+
+// FileChange is a rendered inventory file that differs from the last run
+type FileChange struct {
+	// Path is relative to the inventory directory
+	Path string
+	// Diff is the change in unified diff format
+	Diff string
+}
+
+// Close removes the inventory rendered for the plan
+func (p *Plan) Close() error {
+	if p.tmp == "" {
+		return nil
+	}
+	return os.RemoveAll(p.tmp)
+}
+
+// Plan returns what Run would do with opts. The inventory is rendered into a
+// temporary directory, starting from a copy of the inventory of the last
+// run, and compared with it. The plan inventory can be passed to a run in
+// check mode as opts.InventoryPath.
+func (r *Runner) Plan(ctx context.Context, opts Options) (*Plan, error) {
+	if err := r.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	dir := CheckoutDir(r.config)
+	checkout, ready, err := r.describeCheckout(ctx, dir, opts.Repository, opts.Version)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{
+		Playbook:    opts.Playbook,
+		Checkout:    checkout,
+		Inventory:   opts.InventoryPath,
+		Limit:       opts.Limit,
+		Tags:        opts.Tags,
+		StartAtTask: opts.StartAtTask,
+		ExtraVars:   opts.ExtraVars,
+	}
+	if plan.Playbook == "" {
+		plan.Playbook = DefaultPlaybook
+	}
+	// A checkout still to be fetched is checked by the run
+	if ready && !fileExists(filepath.Join(dir, plan.Playbook)) {
+		return nil, fmt.Errorf("playbook %s not found in %s", plan.Playbook, dir)
+	}
+
+	target := plan.Inventory
+	if target == "" {
+		target = r.inventoryDir()
+		if err := r.planInventory(plan); err != nil {
+			plan.Close()
+			return nil, err
+		}
+	}
+
+	parsed, err := r.checkInventory(plan.Inventory)
+	if err == nil {
+		plan.Hosts, err = limitHosts(parsed, opts.Limit)
+		plan.Nodes = len(parsed.Members("k8s_cluster", "all"))
+	}
+	if err == nil {
+		plan.HostsFile, err = readHostsFile(plan.Inventory)
+	}
+	if err != nil {
+		plan.Close()
+		return nil, err
+	}
+
+	plan.Args = r.playbookArgs(target, plan.Playbook, "", opts)
+	return plan, nil
+}
+
+// planInventory renders the inventory of the cluster over a temporary copy
+// of the inventory of the last run and records the files that changed
+func (r *Runner) planInventory(plan *Plan) error {
+	tmp, err := os.MkdirTemp("", "kubespray-plan-*")
+	if err != nil {
+		return fmt.Errorf("failed to create plan directory: %w", err)
+	}
+	plan.tmp = tmp
+	plan.Inventory = filepath.Join(tmp, r.config.Inventory.ClusterName)
+
+	last := r.inventoryDir()
+	if err := copyDir(last, plan.Inventory); err != nil {
+		return err
+	}
+	inv := r.inventory
+	if inv == nil {
+		inv = inventory.FromConfig(r.config)
+	}
+	if err := r.newGenerator().GenerateDir(inv, plan.Inventory, inventory.FormatYAML); err != nil {
+		return fmt.Errorf("failed to render inventory: %w", err)
+	}
+
+	return filepath.WalkDir(plan.Inventory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(plan.Inventory, path)
+		if err != nil {
+			return err
+		}
+		rendered, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read inventory: %w", err)
+		}
+		from := filepath.Join(last, rel)
+		previous, err := os.ReadFile(from)
+		if os.IsNotExist(err) {
+			from = "/dev/null"
+		} else if err != nil {
+			return fmt.Errorf("failed to read inventory: %w", err)
+		}
+		if diff := inventory.UnifiedDiff(from, filepath.Join(last, rel), previous, rendered); diff != "" {
+			plan.Changes = append(plan.Changes, FileChange{Path: rel, Diff: diff})
+		}
+		return nil
+	})
+}
+
+// describeCheckout describes what prepareCheckout would do with dir, without
+// doing it. ready reports whether the checkout is used as it is.
+func (r *Runner) describeCheckout(ctx context.Context, dir, repository, version string) (desc string, ready bool, err error) {
+	_, gitErr := os.Stat(filepath.Join(dir, ".git"))
+	if version == "" {
+		if gitErr == nil {
+			head, err := r.git(ctx, dir, "rev-parse", "--short", "HEAD")
+			if err != nil {
+				return "", false, err
+			}
+			return fmt.Sprintf("use the checkout in %s at %s", dir, head), true, nil
+		}
+		if fileExists(filepath.Join(dir, "cluster.yml")) {
+			return fmt.Sprintf("use the Kubespray tree in %s", dir), true, nil
+		}
+		version = DefaultVersion
+	}
+	if repository == "" {
+		repository = DefaultRepository
+	}
+
+	if gitErr != nil {
+		if fileExists(filepath.Join(dir, "cluster.yml")) {
+			return "", false, fmt.Errorf("%s holds a Kubespray tree that is not a git checkout; remove it or deploy without a version", dir)
+		}
+		return fmt.Sprintf("fetch Kubespray %s from %s into %s", version, repository, dir), false, nil
+	}
+
+	head, err := r.git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	if commit, err := r.git(ctx, dir, "rev-parse", "--verify", "--quiet", version+"^{commit}"); err == nil {
+		if commit == head {
+			return fmt.Sprintf("use the checkout in %s at %s", dir, version), true, nil
+		}
+		return fmt.Sprintf("check out Kubespray %s in %s", version, dir), false, nil
+	}
+	return fmt.Sprintf("fetch Kubespray %s from %s into %s", version, repository, dir), false, nil
+}
+
+// limitHosts returns the hosts of inv a run limited to the given hosts and
+// groups covers, in inventory order
+func limitHosts(inv *inventory.AnsibleInventory, limit []string) ([]string, error) {
+	if len(limit) == 0 {
+		return inv.HostOrder, nil
+	}
+	selected := map[string]bool{}
+	for _, pattern := range limit {
+		if _, ok := inv.Groups[pattern]; ok {
+			for _, h := range inv.Members(pattern) {
+				selected[h] = true
+			}
+			continue
+		}
+		name, ok := inv.Lookup(pattern)
+		if !ok {
+			return nil, fmt.Errorf("limit %s matches no host or group of the inventory", pattern)
+		}
+		selected[name] = true
+	}
+
+	hosts := []string{}
+	for _, name := range inv.HostOrder {
+		if selected[name] {
+			hosts = append(hosts, name)
+		}
+	}
+	return hosts, nil
+}
+
+// readHostsFile returns the content of the hosts file of an inventory
+func readHostsFile(path string) ([]byte, error) {
+	hostsFile, err := HostsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	return data, nil
+}
+
+// copyDir copies the files under src to dst. A missing src copies nothing.
+func copyDir(src, dst string) error {
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to copy inventory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	upstream := newUpstream(t, "v2.25.0")
+	r, cfg, record, out := newTestRunner(t, "0", "")
+	ctx := context.Background()
+	invDir := filepath.Join(cfg.KubesprayPath, "inventory", "prod")
+
+	// Nothing is fetched or rendered before the first run
+	plan, err := r.Plan(ctx, Options{Repository: upstream, Limit: []string{"kube_node"}, Tags: []string{"network"}})
+	if err != nil {
+		t.Fatalf("Plan failed: %v\n%s", err, out)
+	}
+	if !strings.HasPrefix(plan.Checkout, "fetch Kubespray "+DefaultVersion) {
+		t.Errorf("Expected a fetch of %s, got %q", DefaultVersion, plan.Checkout)
+	}
+	if _, err := os.Stat(CheckoutDir(cfg)); !os.IsNotExist(err) {
+		t.Errorf("Expected no checkout, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(record, "args")); !os.IsNotExist(err) {
+		t.Error("Expected the playbook not to run")
+	}
+	if !reflect.DeepEqual(plan.Hosts, []string{"node-0", "node-1"}) {
+		t.Errorf("Expected hosts node-0 and node-1, got %v", plan.Hosts)
+	}
+	if !strings.Contains(string(plan.HostsFile), "node-1") {
+		t.Errorf("Expected rendered hosts file, got:\n%s", plan.HostsFile)
+	}
+	if len(plan.Changes) == 0 || !strings.HasPrefix(plan.Changes[0].Diff, "--- /dev/null\n") {
+		t.Errorf("Expected new inventory files, got %+v", plan.Changes)
+	}
+	expected := []string{"-i", invDir, "--become", "--user", "deploy", "--private-key", "/keys/deploy", "--limit", "kube_node", "--tags", "network", "cluster.yml"}
+	if !reflect.DeepEqual(plan.Args, expected) {
+		t.Errorf("Unexpected arguments:\n got %q\nwant %q", plan.Args, expected)
+	}
+	if err := plan.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plan.Inventory); !os.IsNotExist(err) {
+		t.Errorf("Expected plan inventory to be removed, got %v", err)
+	}
+
+	if _, err := r.Plan(ctx, Options{Limit: []string{"node-9"}}); err == nil {
+		t.Error("Expected an error for a limit matching no host")
+	}
+
+	if _, err := r.Run(ctx, Options{Repository: upstream, Version: "v2.25.0"}); err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out)
+	}
+	rendered, _ := os.ReadFile(filepath.Join(invDir, "group_vars", "k8s_cluster", "k8s-cluster.yml"))
+
+	// Only the files the config changes differ from the last run
+	cfg.Kubernetes.NetworkPlugin = "cilium"
+	plan, err = r.Plan(ctx, Options{})
+	if err != nil {
+		t.Fatalf("Plan failed: %v\n%s", err, out)
+	}
+	defer plan.Close()
+	if !strings.HasPrefix(plan.Checkout, "use the checkout in "+CheckoutDir(cfg)+" at ") {
+		t.Errorf("Unexpected checkout %q", plan.Checkout)
+	}
+	if len(plan.Hosts) != 3 {
+		t.Errorf("Expected all three hosts, got %v", plan.Hosts)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Path != filepath.Join("group_vars", "k8s_cluster", "k8s-cluster.yml") ||
+		!strings.Contains(plan.Changes[0].Diff, "+kube_network_plugin: cilium") {
+		t.Errorf("Expected the network plugin change only, got %+v", plan.Changes)
+	}
+	if data, _ := os.ReadFile(filepath.Join(invDir, "group_vars", "k8s_cluster", "k8s-cluster.yml")); string(data) != string(rendered) {
+		t.Error("Expected the inventory of the last run to be left as it is")
+	}
+
+	// A check run uses the plan inventory and cannot be resumed
+	result, err := r.Run(ctx, Options{InventoryPath: plan.Inventory, Check: true})
+	if err != nil {
+		t.Fatalf("Check run failed: %v\n%s", err, out)
+	}
+	args, _ := recordedRun(t, record)
+	if !result.Check || !reflect.DeepEqual(args[len(args)-3:], []string{"--check", "--diff", "cluster.yml"}) {
+		t.Errorf("Expected a check run, got %v", args)
+	}
+	state, err := LoadRunState(cfg, result.RunID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Check {
+		t.Error("Expected the run state to record check mode")
+	}
+	if _, err := state.ResumeOptions(nil); err == nil {
+		t.Error("Expected a check run not to be resumable")
+	}
+}
@@ -0,0 +1,503 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/inventory"
+)
+
+// DefaultPlaybook is the Kubespray playbook that deploys a cluster
+const DefaultPlaybook = "cluster.yml"
+
+// Runner drives Kubespray playbooks: it prepares the Kubespray checkout
+// under the configured kubespray_path, renders the inventory and group_vars
+// from the configuration and runs ansible-playbook, streaming its output.
+type Runner struct {
+	config      *config.Config
+	inventory   *inventory.Inventory
+	resolver    inventory.HostnameResolver
+	out         io.Writer
+	log         io.Writer
+	events      func(Event)
+	playbookCmd string
+	gitCmd      string
+}
+
+// NewRunner creates a runner for the cluster described by cfg
+func NewRunner(cfg *config.Config) *Runner {
+	return &Runner{
+		config:      cfg,
+		out:         os.Stdout,
+		playbookCmd: "ansible-playbook",
+		gitCmd:      "git",
+	}
+}
+
+// WithInventory sets the hosts to deploy. Without it the inventory section
+// of the configuration is used.
+func (r *Runner) WithInventory(inv *inventory.Inventory) *Runner {
+	r.inventory = inv
+	return r
+}
+
+// WithResolver sets the resolver used to name hosts from DNS or SSH
+func (r *Runner) WithResolver(res inventory.HostnameResolver) *Runner {
+	r.resolver = res
+	return r
+}
+
+// WithOutput sets where progress and playbook output are written
+func (r *Runner) WithOutput(w io.Writer) *Runner {
+	r.out = w
+	return r
+}
+
+// WithLog sets where the playbook output is written, instead of the output
+// set with WithOutput
+func (r *Runner) WithLog(w io.Writer) *Runner {
+	r.log = w
+	return r
+}
+
+// WithEvents sets a function called with every event parsed from the
+// playbook output, as the playbook runs
+func (r *Runner) WithEvents(fn func(Event)) *Runner {
+	r.events = fn
+	return r
+}
+
+// WithPlaybookCommand sets the ansible-playbook binary to run
+func (r *Runner) WithPlaybookCommand(path string) *Runner {
+	r.playbookCmd = path
+	return r
+}
+
+// Options select what a run does
+type Options struct {
+	// Playbook is the Kubespray playbook to run, DefaultPlaybook if empty
+	Playbook string
+	// InventoryPath is an existing inventory file or directory to use
+	// instead of rendering one from the configuration
+	InventoryPath string
+	// Repository and Version select the Kubespray source. With no Version an
+	// existing checkout is used as it is, and DefaultVersion is fetched when
+	// there is none.
+	Repository string
+	Version    string
+	// Limit restricts the run to these hosts or groups
+	Limit []string
+	// Tags restricts the run to tasks with these tags
+	Tags []string
+	// ExtraVars are passed to the playbook with the highest precedence
+	ExtraVars map[string]interface{}
+	// Verbosity adds -v flags, up to 4
+	Verbosity int
+	// RunID identifies the run and its saved state, NewRunID if empty
+	RunID string
+	// StartAtTask starts the playbook at the task with this name
+	StartAtTask string
+	// ResumedFrom is the run this one resumes, see RunState.ResumeOptions
+	ResumedFrom string
+	// Check runs the playbook in check mode, reporting the changes it would
+	// make without making them
+	Check bool
+}
+
+// HostStats are the task counts of a host from the PLAY RECAP
+type HostStats struct {
+	OK          int
+	Changed     int
+	Unreachable int
+	Failed      int
+	Skipped     int
+	Rescued     int
+	Ignored     int
+}
+
+// Result describes a playbook run
+type Result struct {
+	// RunID identifies the saved state of the run
+	RunID    string
+	Playbook string
+	// Inventory is the inventory the playbook ran against
+	Inventory string
+	// Args are the ansible-playbook arguments
+	Args     []string
+	Started  time.Time
+	Finished time.Time
+	ExitCode int
+	// Hosts holds the PLAY RECAP of every host
+	Hosts map[string]HostStats
+	// Failures are the failed and unreachable host results, in the order
+	// they happened
+	Failures []Event
+	// Check reports whether the playbook ran in check mode
+	Check bool
+}
+
+// Duration returns how long the playbook ran
+func (r *Result) Duration() time.Duration {
+	return r.Finished.Sub(r.Started)
+}
+
+// FailedHosts returns the hosts with failed tasks or that were unreachable,
+// in name order
+func (r *Result) FailedHosts() []string {
+	failed := []string{}
+	for name, s := range r.Hosts {
+		if s.Failed > 0 || s.Unreachable > 0 {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// Run deploys the cluster with opts. The configuration and inventory are
+// validated first. A Result is returned whenever the playbook ran, together
+// with an error when it failed.
+func (r *Runner) Run(ctx context.Context, opts Options) (*Result, error) {
+	return r.run(ctx, opts, nil)
+}
+
+// run runs a playbook with opts. prepare, when set, completes the options
+// once the inventory the playbook runs against is known.
+func (r *Runner) run(ctx context.Context, opts Options, prepare func(inv *inventory.AnsibleInventory, opts *Options) error) (*Result, error) {
+	if err := r.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if _, err := exec.LookPath(r.playbookCmd); err != nil {
+		return nil, fmt.Errorf("%s not found; install Ansible and the Kubespray requirements (pip install -r requirements.txt): %w", r.playbookCmd, err)
+	}
+
+	dir := CheckoutDir(r.config)
+	if err := r.prepareCheckout(ctx, dir, opts.Repository, opts.Version); err != nil {
+		return nil, err
+	}
+	playbook := opts.Playbook
+	if playbook == "" {
+		playbook = DefaultPlaybook
+	}
+	if !fileExists(filepath.Join(dir, playbook)) {
+		return nil, fmt.Errorf("playbook %s not found in %s", playbook, dir)
+	}
+
+	inv, parsed, err := r.prepareInventory(opts.InventoryPath)
+	if err != nil {
+		return nil, err
+	}
+	if prepare != nil {
+		if err := prepare(parsed, &opts); err != nil {
+			return nil, err
+		}
+	}
+
+	varsFile, err := r.writeExtraVars(opts.ExtraVars)
+	if err != nil {
+		return nil, err
+	}
+	if varsFile != "" {
+		defer os.Remove(varsFile)
+	}
+
+	state := &RunState{
+		ID:          opts.RunID,
+		Cluster:     r.config.Inventory.ClusterName,
+		Status:      RunRunning,
+		ResumedFrom: opts.ResumedFrom,
+		Playbook:    playbook,
+		Inventory:   inv,
+		Limit:       opts.Limit,
+		Tags:        opts.Tags,
+		Check:       opts.Check,
+		Hosts:       map[string]*HostState{},
+	}
+	// Secrets stay out of the saved state, as they stay out of the process list
+	state.ExtraVars, state.SecretVars = splitSecretVars(opts.ExtraVars)
+	if state.ID == "" {
+		state.ID = NewRunID(state.Cluster, time.Now())
+	}
+	// A tree that is not a git checkout has no commit to pin a resume to
+	if commit, err := r.git(ctx, dir, "rev-parse", "HEAD"); err == nil {
+		state.Commit = commit
+	}
+
+	args := r.playbookArgs(inv, playbook, varsFile, opts)
+	return r.runPlaybook(ctx, dir, args, state)
+}
+
+// prepareInventory renders the inventory directory of the cluster, or
+// checks the given one, and validates it. It returns the inventory path and
+// its parsed hosts file.
+func (r *Runner) prepareInventory(path string) (string, *inventory.AnsibleInventory, error) {
+	if path == "" {
+		path = r.inventoryDir()
+		inv := r.inventory
+		if inv == nil {
+			inv = inventory.FromConfig(r.config)
+		}
+		if err := r.newGenerator().GenerateDir(inv, path, inventory.FormatYAML); err != nil {
+			return "", nil, fmt.Errorf("failed to render inventory: %w", err)
+		}
+		fmt.Fprintf(r.out, "Inventory rendered to %s\n", path)
+	}
+
+	parsed, err := r.checkInventory(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, parsed, nil
+}
+
+// inventoryDir returns the directory the inventory of the cluster is
+// rendered into, <kubespray_path>/inventory/<cluster_name>
+func (r *Runner) inventoryDir() string {
+	return filepath.Join(r.config.KubesprayPath, "inventory", r.config.Inventory.ClusterName)
+}
+
+// checkInventory parses the hosts file of the inventory at path and
+// validates it, printing the findings
+func (r *Runner) checkInventory(path string) (*inventory.AnsibleInventory, error) {
+	hostsFile, err := HostsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := inventory.ParseFile(hostsFile)
+	if err != nil {
+		return nil, err
+	}
+	findings := inventory.NewValidator(r.config).ValidateInventory(parsed)
+	for _, f := range findings {
+		fmt.Fprintln(r.out, f)
+	}
+	if inventory.HasErrors(findings) {
+		return nil, fmt.Errorf("inventory %s is invalid", hostsFile)
+	}
+	return parsed, nil
+}
+
+// newGenerator returns the inventory generator of the runner
+func (r *Runner) newGenerator() *inventory.Generator {
+	gen := inventory.NewGenerator(r.config)
+	if r.resolver != nil {
+		gen.WithResolver(r.resolver)
+	}
+	return gen
+}
+
+// HostsFile returns the hosts file of an inventory file or directory
+func HostsFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open inventory: %w", err)
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	for _, name := range []string{"hosts.yaml", "hosts.yml", "hosts.ini", "inventory.ini"} {
+		if p := filepath.Join(path, name); fileExists(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no hosts file found in inventory %s", path)
+}
+
+// writeExtraVars writes the extra vars, and the become password when one is
+// configured, to a private file passed with -e @file so they stay out of the
+// process list. It returns an empty path when there are none.
+func (r *Runner) writeExtraVars(vars map[string]interface{}) (string, error) {
+	all := map[string]interface{}{}
+	for k, v := range vars {
+		all[k] = v
+	}
+	if r.config.SSH.BecomePassword != "" {
+		all["ansible_become_password"] = r.config.SSH.BecomePassword
+	}
+	if len(all) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode extra vars: %w", err)
+	}
+	f, err := os.CreateTemp("", "kubespray-vars-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to write extra vars: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write extra vars: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write extra vars: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// playbookArgs returns the ansible-playbook arguments of a run. Connection
+// settings come from the ssh section of the configuration; host variables in
+// the inventory take precedence over them.
+func (r *Runner) playbookArgs(inv, playbook, varsFile string, opts Options) []string {
+	args := []string{"-i", inv, "--become", "--user", r.config.SSH.User, "--private-key", r.config.SSH.KeyPath}
+	if len(opts.Limit) > 0 {
+		args = append(args, "--limit", strings.Join(opts.Limit, ","))
+	}
+	if len(opts.Tags) > 0 {
+		args = append(args, "--tags", strings.Join(opts.Tags, ","))
+	}
+	if varsFile != "" {
+		args = append(args, "--extra-vars", "@"+varsFile)
+	}
+	if opts.StartAtTask != "" {
+		args = append(args, "--start-at-task", opts.StartAtTask)
+	}
+	if opts.Check {
+		args = append(args, "--check", "--diff")
+	}
+	if opts.Verbosity > 0 {
+		args = append(args, "-"+strings.Repeat("v", min(opts.Verbosity, 4)))
+	}
+	return append(args, playbook)
+}
+
+// runPlaybook runs ansible-playbook in the Kubespray checkout, so its
+// ansible.cfg applies, and collects the result. The run state is saved
+// before the playbook starts and as it progresses.
+func (r *Runner) runPlaybook(ctx context.Context, dir string, args []string, state *RunState) (*Result, error) {
+	result := &Result{
+		RunID:     state.ID,
+		Playbook:  state.Playbook,
+		Inventory: state.Inventory,
+		Args:      args,
+		Check:     state.Check,
+		Hosts:     map[string]HostStats{},
+	}
+
+	state.Started = time.Now()
+	state.Updated = state.Started
+	if err := state.Save(r.config); err != nil {
+		return nil, err
+	}
+	// A checkpoint that cannot be saved is reported once; the run goes on
+	var saveErr error
+	checkpoint := func() {
+		state.Updated = time.Now()
+		if err := state.Save(r.config); err != nil && saveErr == nil {
+			saveErr = err
+			fmt.Fprintf(r.out, "warning: %v\n", err)
+		}
+	}
+
+	parser := &eventParser{emit: func(e Event) {
+		switch {
+		case e.Type == EventRecap:
+			result.Hosts[e.Host] = e.Stats
+		case e.Failed():
+			result.Failures = append(result.Failures, e)
+		}
+		if state.record(e) {
+			checkpoint()
+		}
+		if r.events != nil {
+			r.events(e)
+		}
+	}}
+	lines := &lineWriter{fn: parser.line}
+	log := r.log
+	if log == nil {
+		log = r.out
+	}
+	// stdout and stderr are copied concurrently
+	out := &syncWriter{w: log}
+	cmd := exec.CommandContext(ctx, r.playbookCmd, args...)
+	cmd.Dir = dir
+	// The events are parsed from the output of the default callback
+	cmd.Env = append(os.Environ(),
+		"ANSIBLE_REMOTE_PORT="+strconv.Itoa(r.config.SSH.Port),
+		"ANSIBLE_STDOUT_CALLBACK=default",
+	)
+	cmd.Stdout = io.MultiWriter(out, lines)
+	cmd.Stderr = out
+
+	fmt.Fprintf(r.out, "Running %s %s\n", r.playbookCmd, strings.Join(args, " "))
+	result.Started = state.Started
+	err := cmd.Run()
+	result.Finished = time.Now()
+	lines.Flush()
+	parser.flush()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		state.Status = RunSucceeded
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		state.Status = RunFailed
+		err = fmt.Errorf("%s failed with exit status %d", state.Playbook, result.ExitCode)
+		if ctx.Err() != nil {
+			state.Status = RunInterrupted
+			err = fmt.Errorf("%s interrupted: %w", state.Playbook, ctx.Err())
+		}
+	default:
+		state.Status = RunFailed
+		checkpoint()
+		return nil, fmt.Errorf("failed to run %s: %w", r.playbookCmd, err)
+	}
+	checkpoint()
+	return result, err
+}
+
+// syncWriter serializes writes to w
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// lineWriter calls fn for every complete line written to it
+type lineWriter struct {
+	buf bytes.Buffer
+	fn  func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf.Next(i + 1))
+		w.fn(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush passes on a last line without a newline
+func (w *lineWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.fn(w.buf.String())
+		w.buf.Reset()
+	}
+}
@@ -0,0 +1,312 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// fakePlaybook is an ansible-playbook stand-in. It records its arguments,
+// working directory, SSH port and extra vars under $FAKE_RECORD, prints
+// $FAKE_OUTPUT and exits with $FAKE_EXIT.
+const fakePlaybook = `#!/bin/sh
+record="$FAKE_RECORD"
+printf '%s\n' "$@" > "$record/args"
+pwd > "$record/dir"
+echo "$ANSIBLE_REMOTE_PORT" > "$record/port"
+for arg in "$@"; do
+	case "$arg" in
+	@*) cp "${arg#@}" "$record/vars" ;;
+	esac
+done
+printf '%s' "$FAKE_OUTPUT"
+echo "error output" >&2
+exit "${FAKE_EXIT:-0}"
+`
+
+const recapOutput = `PLAY [k8s_cluster] *************************************************************
+
+TASK [kubernetes/preinstall : Check hostname] **********************************
+ok: [master-0]
+fatal: [node-0]: FAILED! => {"changed": false, "msg": "Hostname is not valid"}
+fatal: [node-1]: UNREACHABLE! => {"changed": false, "msg": "Failed to connect to the host via ssh", "unreachable": true}
+
+PLAY RECAP *********************************************************************
+master-0                   : ok=512  changed=103  unreachable=0    failed=0    skipped=640  rescued=0    ignored=1
+node-0                     : ok=350  changed=64   unreachable=0    failed=1    skipped=420  rescued=0    ignored=0
+node-1                     : ok=0    changed=0    unreachable=1    failed=0    skipped=0    rescued=0    ignored=0
+
+Friday 18 October 2026  10:00:00 +0000 (0:00:00.050)       0:25:41.123 *******
+`
+
+// run runs a command in dir, failing the test on error
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newUpstream creates a git repository standing in for Kubespray with the
+// given tags, each adding a release file
+func newUpstream(t *testing.T, tags ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	run(t, dir, "git", "init", "--quiet")
+	for _, tag := range tags {
+		for name, content := range map[string]string{"cluster.yml": "- hosts: all\n", "scale.yml": "- hosts: all\n", "remove-node.yml": "- hosts: all\n", "upgrade-cluster.yml": "- hosts: all\n", "reset.yml": "- hosts: all\n", "release": tag + "\n"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		run(t, dir, "git", "add", "-A")
+		run(t, dir, "git", "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", tag)
+		run(t, dir, "git", "tag", tag)
+	}
+	return dir
+}
+
+// newTestRunner returns a runner with the fake ansible-playbook and a config
+// deploying one master and two nodes into a temporary kubespray_path
+func newTestRunner(t *testing.T, exit string, output string) (*Runner, *config.Config, string, *bytes.Buffer) {
+	t.Helper()
+	tmp := t.TempDir()
+	bin := filepath.Join(tmp, "ansible-playbook")
+	if err := os.WriteFile(bin, []byte(fakePlaybook), 0755); err != nil {
+		t.Fatal(err)
+	}
+	record := filepath.Join(tmp, "record")
+	if err := os.Mkdir(record, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_RECORD", record)
+	t.Setenv("FAKE_EXIT", exit)
+	t.Setenv("FAKE_OUTPUT", output)
+
+	cfg := config.NewConfig()
+	cfg.KubesprayPath = filepath.Join(tmp, "kubespray")
+	cfg.SSH.User = "deploy"
+	cfg.SSH.KeyPath = "/keys/deploy"
+	cfg.SSH.Port = 2222
+	cfg.Inventory.ClusterName = "prod"
+	cfg.Inventory.Masters = []string{"10.0.0.1"}
+	cfg.Inventory.Nodes = []string{"10.0.0.2", "10.0.0.3"}
+
+	var out bytes.Buffer
+	return NewRunner(cfg).WithPlaybookCommand(bin).WithOutput(&out), cfg, record, &out
+}
+
+func TestRun(t *testing.T) {
+	upstream := newUpstream(t, "v2.25.0")
+	r, cfg, record, out := newTestRunner(t, "0", recapOutput)
+	cfg.SSH.BecomePassword = "sudo-secret"
+
+	// Cluster profiles live inside the default kubespray_path
+	profiles := filepath.Join(cfg.KubesprayPath, "clusters")
+	if err := os.MkdirAll(profiles, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(profiles, "prod.yaml"), []byte("log_level: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Run(context.Background(), Options{
+		Repository: upstream,
+		Version:    "v2.25.0",
+		Limit:      []string{"node-0", "node-1"},
+		Tags:       []string{"network"},
+		ExtraVars:  map[string]interface{}{"kube_proxy_mode": "ipvs"},
+		Verbosity:  2,
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v\n%s", err, out)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(CheckoutDir(cfg), "release")); err != nil || string(data) != "v2.25.0\n" {
+		t.Errorf("Expected v2.25.0 checkout, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(profiles, "prod.yaml")); err != nil {
+		t.Errorf("Expected cluster profiles to survive the checkout: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.KubesprayPath, ".git")); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkout to stay out of kubespray_path itself, got %v", err)
+	}
+
+	invDir := filepath.Join(cfg.KubesprayPath, "inventory", "prod")
+	for _, name := range []string{"hosts.yaml", "group_vars/all/all.yml", "group_vars/k8s_cluster/k8s-cluster.yml"} {
+		if _, err := os.Stat(filepath.Join(invDir, name)); err != nil {
+			t.Errorf("Expected rendered %s: %v", name, err)
+		}
+	}
+
+	args, _ := os.ReadFile(filepath.Join(record, "args"))
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	varsArg := lines[len(lines)-3]
+	expected := []string{
+		"-i", invDir, "--become", "--user", "deploy", "--private-key", "/keys/deploy",
+		"--limit", "node-0,node-1", "--tags", "network", "--extra-vars", varsArg, "-vv", "cluster.yml",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Unexpected arguments:\n got %q\nwant %q", lines, expected)
+	}
+	if !reflect.DeepEqual(result.Args, expected) {
+		t.Errorf("Expected result args %q, got %q", expected, result.Args)
+	}
+	if _, err := os.Stat(strings.TrimPrefix(varsArg, "@")); !os.IsNotExist(err) {
+		t.Errorf("Expected extra vars file to be removed, got %v", err)
+	}
+
+	var vars map[string]interface{}
+	data, _ := os.ReadFile(filepath.Join(record, "vars"))
+	if err := json.Unmarshal(data, &vars); err != nil {
+		t.Fatalf("Invalid extra vars %q: %v", data, err)
+	}
+	if vars["ansible_become_password"] != "sudo-secret" || vars["kube_proxy_mode"] != "ipvs" {
+		t.Errorf("Unexpected extra vars %v", vars)
+	}
+	if strings.Contains(out.String(), "sudo-secret") {
+		t.Error("Become password leaked into the output")
+	}
+
+	if dir, _ := os.ReadFile(filepath.Join(record, "dir")); strings.TrimSpace(string(dir)) != CheckoutDir(cfg) {
+		t.Errorf("Expected playbook to run in %s, got %s", CheckoutDir(cfg), dir)
+	}
+	if port, _ := os.ReadFile(filepath.Join(record, "port")); strings.TrimSpace(string(port)) != "2222" {
+		t.Errorf("Expected ANSIBLE_REMOTE_PORT 2222, got %s", port)
+	}
+
+	if !strings.Contains(out.String(), "TASK [kubernetes/preinstall : Check hostname]") || !strings.Contains(out.String(), "error output") {
+		t.Errorf("Expected playbook output to be streamed, got:\n%s", out)
+	}
+	if result.ExitCode != 0 || result.Playbook != "cluster.yml" || result.Inventory != invDir {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if got := result.Hosts["master-0"]; got != (HostStats{OK: 512, Changed: 103, Skipped: 640, Ignored: 1}) {
+		t.Errorf("Unexpected master-0 stats %+v", got)
+	}
+	if got := result.FailedHosts(); !reflect.DeepEqual(got, []string{"node-0", "node-1"}) {
+		t.Errorf("Expected failed hosts node-0 and node-1, got %v", got)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	upstream := newUpstream(t, "v2.25.0")
+	r, _, _, out := newTestRunner(t, "2", recapOutput)
+	var log bytes.Buffer
+	var events []EventType
+	r.WithLog(&log).WithEvents(func(e Event) { events = append(events, e.Type) })
+
+	result, err := r.Run(context.Background(), Options{Repository: upstream, Version: "v2.25.0"})
+	if err == nil {
+		t.Fatal("Expected error but got nil")
+	}
+	if result == nil || result.ExitCode != 2 || len(result.Hosts) != 3 {
+		t.Fatalf("Expected a result with exit status 2 and the recap, got %+v", result)
+	}
+
+	var failed []string
+	for _, f := range result.Failures {
+		failed = append(failed, f.Host+" "+f.Status+": "+f.Message)
+	}
+	expected := []string{"node-0 failed: Hostname is not valid", "node-1 unreachable: Failed to connect to the host via ssh"}
+	if !reflect.DeepEqual(failed, expected) {
+		t.Errorf("Expected failures %q, got %q", expected, failed)
+	}
+	if len(events) != 8 || events[0] != EventPlayStart || events[7] != EventRecap {
+		t.Errorf("Unexpected events %v", events)
+	}
+	if !strings.Contains(log.String(), "PLAY RECAP") || strings.Contains(out.String(), "PLAY RECAP") {
+		t.Errorf("Expected playbook output in the log only, got log:\n%s\noutput:\n%s", log.String(), out)
+	}
+}
+
+func TestRunChecksBeforePlaybook(t *testing.T) {
+	upstream := newUpstream(t, "v2.25.0")
+
+	tests := []struct {
+		name   string
+		modify func(*config.Config, *Options)
+	}{
+		{"Invalid config", func(c *config.Config, o *Options) { c.Kubernetes.NetworkPlugin = "canal" }},
+		{"No control plane", func(c *config.Config, o *Options) { c.Inventory.Masters = nil }},
+		{"Missing playbook", func(c *config.Config, o *Options) { o.Playbook = "missing.yml" }},
+		{"Missing inventory", func(c *config.Config, o *Options) { o.InventoryPath = filepath.Join(c.KubesprayPath, "nowhere") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, cfg, record, _ := newTestRunner(t, "0", "")
+			opts := Options{Repository: upstream, Version: "v2.25.0"}
+			tt.modify(cfg, &opts)
+
+			if _, err := r.Run(context.Background(), opts); err == nil {
+				t.Fatal("Expected error but got nil")
+			}
+			if _, err := os.Stat(filepath.Join(record, "args")); !os.IsNotExist(err) {
+				t.Error("Expected ansible-playbook not to run")
+			}
+		})
+	}
+}
+
+func TestPrepareCheckout(t *testing.T) {
+	upstream := newUpstream(t, "v2.24.0", "v2.25.0")
+	ctx := context.Background()
+	var out bytes.Buffer
+	r := NewRunner(config.NewConfig()).WithOutput(&out)
+
+	release := func(dir string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, "release"))
+		return strings.TrimSpace(string(data))
+	}
+
+	dir := filepath.Join(t.TempDir(), "kubespray")
+	if err := r.prepareCheckout(ctx, dir, upstream, "v2.24.0"); err != nil {
+		t.Fatalf("Initial checkout failed: %v", err)
+	}
+	if got := release(dir); got != "v2.24.0" {
+		t.Errorf("Expected v2.24.0, got %s", got)
+	}
+
+	// Without a version the existing checkout is kept
+	if err := r.prepareCheckout(ctx, dir, upstream, ""); err != nil || release(dir) != "v2.24.0" {
+		t.Errorf("Expected existing checkout to be used, got %s, %v", release(dir), err)
+	}
+
+	if err := r.prepareCheckout(ctx, dir, upstream, "v2.25.0"); err != nil || release(dir) != "v2.25.0" {
+		t.Errorf("Expected upgrade to v2.25.0, got %s, %v", release(dir), err)
+	}
+
+	// Commits fetched before are checked out without the repository
+	commit := run(t, upstream, "git", "rev-parse", "v2.24.0")
+	if err := r.prepareCheckout(ctx, dir, filepath.Join(t.TempDir(), "gone"), commit); err != nil || release(dir) != "v2.24.0" {
+		t.Errorf("Expected local checkout of %s, got %s, %v", commit, release(dir), err)
+	}
+
+	if err := r.prepareCheckout(ctx, dir, upstream, "v9.9.9"); err == nil {
+		t.Error("Expected error for an unknown version")
+	}
+
+	plain := t.TempDir()
+	if err := os.WriteFile(filepath.Join(plain, "cluster.yml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.prepareCheckout(ctx, plain, upstream, ""); err != nil {
+		t.Errorf("Expected unversioned tree to be used as is, got %v", err)
+	}
+	if err := r.prepareCheckout(ctx, plain, upstream, "v2.25.0"); err == nil {
+		t.Error("Expected error checking out a version over a tree that is not a git checkout")
+	}
+}
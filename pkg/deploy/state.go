@@ -0,0 +1,261 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// Run statuses
+const (
+	RunRunning     = "running"
+	RunSucceeded   = "succeeded"
+	RunFailed      = "failed"
+	RunInterrupted = "interrupted"
+)
+
+// RunState is the checkpoint of a playbook run. It is saved under
+// <kubespray_path>/runs as the playbook progresses, so that a run that
+// failed can be resumed from the task that failed.
+type RunState struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster"`
+	Status  string `json:"status"`
+	// ResumedFrom is the run this one resumes
+	ResumedFrom string    `json:"resumed_from,omitempty"`
+	Started     time.Time `json:"started"`
+	Updated     time.Time `json:"updated"`
+
+	// What ran: the playbook and its options, the inventory it ran against
+	// and the Kubespray commit
+	Playbook  string                 `json:"playbook"`
+	Inventory string                 `json:"inventory"`
+	Commit    string                 `json:"commit,omitempty"`
+	Limit     []string               `json:"limit,omitempty"`
+	Tags      []string               `json:"tags,omitempty"`
+	ExtraVars map[string]interface{} `json:"extra_vars,omitempty"`
+	// SecretVars are the names of extra vars that look like secrets. Their
+	// values are not saved and have to be given again to resume the run.
+	SecretVars []string `json:"secret_vars,omitempty"`
+	// Check is set for runs in check mode, which change no host
+	Check bool `json:"check,omitempty"`
+
+	// Phase is the play that ran last and Task the task that started last.
+	// FailedTask is the first task that failed on a host.
+	Phase      string `json:"phase,omitempty"`
+	Task       string `json:"task,omitempty"`
+	FailedTask string `json:"failed_task,omitempty"`
+	// Hosts holds the progress of every host that reported a result
+	Hosts map[string]*HostState `json:"hosts"`
+}
+
+// HostState is the progress of a host in a run
+type HostState struct {
+	// Status is StatusOK, StatusFailed or StatusUnreachable
+	Status string `json:"status"`
+	// LastTask is the last task that succeeded on the host
+	LastTask   string `json:"last_task,omitempty"`
+	FailedTask string `json:"failed_task,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// NewRunID returns the id of a run of cluster started at t
+func NewRunID(cluster string, t time.Time) string {
+	return fmt.Sprintf("%s-%s", cluster, t.Format("20060102-150405"))
+}
+
+// RunsDir returns the directory the run states of cfg are saved in
+func RunsDir(cfg *config.Config) string {
+	return filepath.Join(cfg.KubesprayPath, "runs")
+}
+
+// LoadRunState reads the state of run id
+func LoadRunState(cfg *config.Config, id string) (*RunState, error) {
+	data, err := os.ReadFile(filepath.Join(RunsDir(cfg), id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("run %s not found in %s", id, RunsDir(cfg))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", id, err)
+	}
+	var s RunState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse run %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// Save writes the state to the runs directory of cfg. The state holds the
+// extra vars of the run, so it is only readable by the owner.
+func (s *RunState) Save(cfg *config.Config) error {
+	dir := RunsDir(cfg)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run %s: %w", s.ID, err)
+	}
+
+	// Written to a temporary file first so a crash never leaves a torn state
+	tmp, err := os.CreateTemp(dir, "."+s.ID+"-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save run %s: %w", s.ID, err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save run %s: %w", s.ID, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save run %s: %w", s.ID, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, s.ID+".json")); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save run %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// FailedHosts returns the hosts that failed or were unreachable, in name
+// order
+func (s *RunState) FailedHosts() []string {
+	failed := []string{}
+	for name, h := range s.Hosts {
+		if h.Status != StatusOK {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// ResumeOptions returns the options that resume the run: the same playbook,
+// inventory, Kubespray commit, tags and extra vars, starting at the task
+// that failed and limited to the hosts that failed. A run that was stopped
+// without failures restarts at the task it was running. vars are added to
+// the saved extra vars and must include the secret vars of the run.
+func (s *RunState) ResumeOptions(vars map[string]interface{}) (Options, error) {
+	if s.Status == RunSucceeded {
+		return Options{}, fmt.Errorf("run %s succeeded; there is nothing to resume", s.ID)
+	}
+	if s.Check {
+		return Options{}, fmt.Errorf("run %s ran in check mode and changed no host; deploy again instead", s.ID)
+	}
+	missing := []string{}
+	for _, name := range s.SecretVars {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return Options{}, fmt.Errorf("run %s used secret extra vars that are not saved; pass them again with -e: %s", s.ID, strings.Join(missing, ", "))
+	}
+
+	extraVars := map[string]interface{}{}
+	for k, v := range s.ExtraVars {
+		extraVars[k] = v
+	}
+	for k, v := range vars {
+		extraVars[k] = v
+	}
+	opts := Options{
+		Playbook:      s.Playbook,
+		InventoryPath: s.Inventory,
+		Version:       s.Commit,
+		Limit:         s.Limit,
+		Tags:          s.Tags,
+		ExtraVars:     extraVars,
+		StartAtTask:   s.FailedTask,
+		ResumedFrom:   s.ID,
+	}
+	if opts.StartAtTask == "" {
+		opts.StartAtTask = s.Task
+	}
+	if failed := s.FailedHosts(); len(failed) > 0 {
+		opts.Limit = failed
+	}
+	return opts, nil
+}
+
+// secretVar matches the names of extra vars whose values are not saved
+var secretVar = regexp.MustCompile(`(?i)pass|secret|token|credential|private_key|api_key|access_key`)
+
+// splitSecretVars returns the extra vars that can be saved and the names of
+// those that look like secrets, in name order
+func splitSecretVars(vars map[string]interface{}) (map[string]interface{}, []string) {
+	saved := map[string]interface{}{}
+	secrets := []string{}
+	for k, v := range vars {
+		if secretVar.MatchString(k) {
+			secrets = append(secrets, k)
+			continue
+		}
+		saved[k] = v
+	}
+	sort.Strings(secrets)
+	if len(saved) == 0 {
+		saved = nil
+	}
+	if len(secrets) == 0 {
+		secrets = nil
+	}
+	return saved, secrets
+}
+
+// record updates the state with an event of the run. It reports whether the
+// state changed in a way worth saving.
+func (s *RunState) record(e Event) bool {
+	switch e.Type {
+	case EventPlayStart:
+		s.Phase = e.Play
+	case EventTaskStart:
+		s.Task = e.Task
+	case EventHostResult:
+		h := s.host(e.Host)
+		if !e.Failed() {
+			if h.Status == StatusOK && e.Status != StatusIgnored {
+				h.LastTask = e.Task
+			}
+			return false
+		}
+		if h.Status == StatusOK {
+			h.Status, h.FailedTask, h.Message = e.Status, e.Task, e.Message
+		}
+		if s.FailedTask == "" {
+			s.FailedTask = e.Task
+		}
+	case EventRecap:
+		h := s.host(e.Host)
+		if h.Status == StatusOK {
+			switch {
+			case e.Stats.Unreachable > 0:
+				h.Status = StatusUnreachable
+			case e.Stats.Failed > 0:
+				h.Status = StatusFailed
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// host returns the state of a host, adding it when it has none yet
+func (s *RunState) host(name string) *HostState {
+	h := s.Hosts[name]
+	if h == nil {
+		h = &HostState{Status: StatusOK}
+		s.Hosts[name] = h
+	}
+	return h
+}
@@ -0,0 +1,238 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+func TestRunStateRecord(t *testing.T) {
+	s := &RunState{Hosts: map[string]*HostState{}}
+	preinstall := "kubernetes/preinstall : Check hostname"
+	etcd := "etcd : Configure etcd"
+	events := []Event{
+		{Type: EventPlayStart, Play: "Prepare nodes"},
+		{Type: EventTaskStart, Task: preinstall},
+		{Type: EventHostResult, Task: preinstall, Host: "master-0", Status: StatusOK},
+		{Type: EventHostResult, Task: preinstall, Host: "node-0", Status: StatusChanged},
+		{Type: EventHostResult, Task: preinstall, Host: "node-1", Status: StatusUnreachable, Message: "ssh timeout"},
+		{Type: EventPlayStart, Play: "Install etcd"},
+		{Type: EventTaskStart, Task: etcd},
+		{Type: EventHostResult, Task: etcd, Host: "master-0", Status: StatusIgnored},
+		{Type: EventHostResult, Task: etcd, Host: "node-0", Status: StatusFailed, Message: "boom"},
+		{Type: EventRecap, Host: "master-0", Stats: HostStats{OK: 1}},
+		{Type: EventRecap, Host: "node-2", Stats: HostStats{Failed: 1}},
+	}
+	for _, e := range events {
+		s.record(e)
+	}
+
+	if s.Phase != "Install etcd" || s.Task != etcd || s.FailedTask != preinstall {
+		t.Errorf("Unexpected progress: phase %q, task %q, failed task %q", s.Phase, s.Task, s.FailedTask)
+	}
+	expected := map[string]*HostState{
+		"master-0": {Status: StatusOK, LastTask: preinstall},
+		"node-0":   {Status: StatusFailed, LastTask: preinstall, FailedTask: etcd, Message: "boom"},
+		"node-1":   {Status: StatusUnreachable, FailedTask: preinstall, Message: "ssh timeout"},
+		"node-2":   {Status: StatusFailed},
+	}
+	if !reflect.DeepEqual(s.Hosts, expected) {
+		for name, h := range s.Hosts {
+			t.Logf("%s: %+v", name, *h)
+		}
+		t.Error("Unexpected host states")
+	}
+	if got := s.FailedHosts(); !reflect.DeepEqual(got, []string{"node-0", "node-1", "node-2"}) {
+		t.Errorf("Unexpected failed hosts %v", got)
+	}
+}
+
+func TestRunStateResumeOptions(t *testing.T) {
+	base := RunState{
+		ID:        "prod-20261018-100000",
+		Playbook:  "cluster.yml",
+		Inventory: "/k/inventory/prod",
+		Commit:    "abc123",
+		Limit:     []string{"k8s_cluster"},
+		Tags:      []string{"network"},
+		Task:      "network_plugin/calico : Start calico",
+	}
+
+	tests := []struct {
+		name        string
+		status      string
+		failedTask  string
+		hosts       map[string]*HostState
+		expectErr   bool
+		startAtTask string
+		limit       []string
+	}{
+		{
+			name:        "failed hosts",
+			status:      RunFailed,
+			failedTask:  "etcd : Configure etcd",
+			hosts:       map[string]*HostState{"node-1": {Status: StatusFailed}, "node-0": {Status: StatusUnreachable}, "master-0": {Status: StatusOK}},
+			startAtTask: "etcd : Configure etcd",
+			limit:       []string{"node-0", "node-1"},
+		},
+		{
+			name:        "interrupted",
+			status:      RunInterrupted,
+			hosts:       map[string]*HostState{"master-0": {Status: StatusOK}},
+			startAtTask: "network_plugin/calico : Start calico",
+			limit:       []string{"k8s_cluster"},
+		},
+		{
+			name:      "succeeded",
+			status:    RunSucceeded,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := base
+			s.Status, s.FailedTask, s.Hosts = tt.status, tt.failedTask, tt.hosts
+			opts, err := s.ResumeOptions(nil)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if opts.StartAtTask != tt.startAtTask || !reflect.DeepEqual(opts.Limit, tt.limit) {
+				t.Errorf("Expected start at %q on %v, got %q on %v", tt.startAtTask, tt.limit, opts.StartAtTask, opts.Limit)
+			}
+			if opts.Version != "abc123" || opts.InventoryPath != base.Inventory || opts.ResumedFrom != base.ID || !reflect.DeepEqual(opts.Tags, base.Tags) {
+				t.Errorf("Unexpected options %+v", opts)
+			}
+		})
+	}
+}
+
+func TestRunStateSave(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.KubesprayPath = t.TempDir()
+	s := &RunState{
+		ID:        NewRunID("prod", time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)),
+		Status:    RunFailed,
+		ExtraVars: map[string]interface{}{"token": "secret"},
+		Hosts:     map[string]*HostState{"node-0": {Status: StatusFailed, FailedTask: "etcd : Configure etcd"}},
+	}
+	if s.ID != "prod-20261018-100000" {
+		t.Errorf("Unexpected run id %s", s.ID)
+	}
+	if err := s.Save(cfg); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(RunsDir(cfg), s.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected run state mode 0600, got %v", info.Mode().Perm())
+	}
+	loaded, err := LoadRunState(cfg, s.ID)
+	if err != nil {
+		t.Fatalf("LoadRunState failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("Expected %+v, got %+v", s, loaded)
+	}
+	if _, err := LoadRunState(cfg, "prod-missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	upstream := newUpstream(t, "v2.25.0")
+	r, cfg, record, _ := newTestRunner(t, "2", recapOutput)
+
+	result, err := r.Run(context.Background(), Options{Repository: upstream, Version: "v2.25.0", RunID: "prod-1"})
+	if err == nil {
+		t.Fatal("Expected error but got nil")
+	}
+	if result.RunID != "prod-1" {
+		t.Errorf("Expected run id prod-1, got %s", result.RunID)
+	}
+	state, err := LoadRunState(cfg, "prod-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := run(t, upstream, "git", "rev-parse", "v2.25.0")
+	if state.Status != RunFailed || state.Commit != commit || state.Phase != "k8s_cluster" || state.FailedTask != "kubernetes/preinstall : Check hostname" {
+		t.Errorf("Unexpected state %+v", state)
+	}
+
+	opts, err := state.ResumeOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.RunID = "prod-2"
+	t.Setenv("FAKE_EXIT", "0")
+	if _, err := r.Run(context.Background(), opts); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	args, _ := os.ReadFile(filepath.Join(record, "args"))
+	expected := []string{
+		"-i", state.Inventory, "--become", "--user", "deploy", "--private-key", "/keys/deploy",
+		"--limit", "node-0,node-1", "--start-at-task", "kubernetes/preinstall : Check hostname", "cluster.yml",
+	}
+	if got := strings.Split(strings.TrimSpace(string(args)), "\n"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected arguments:\n got %q\nwant %q", got, expected)
+	}
+	resumed, err := LoadRunState(cfg, "prod-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Status != RunSucceeded || resumed.ResumedFrom != "prod-1" {
+		t.Errorf("Unexpected resumed state %+v", resumed)
+	}
+}
+
+func TestRunSecretVars(t *testing.T) {
+	upstream := newUpstream(t, "v2.25.0")
+	r, cfg, _, _ := newTestRunner(t, "2", recapOutput)
+
+	vars := map[string]interface{}{"kube_proxy_mode": "ipvs", "ansible_become_pass": "s3cret", "vault_token": "t0ken"}
+	if _, err := r.Run(context.Background(), Options{Repository: upstream, RunID: "prod-1", ExtraVars: vars}); err == nil {
+		t.Fatal("Expected error but got nil")
+	}
+
+	data, err := os.ReadFile(filepath.Join(RunsDir(cfg), "prod-1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), "t0ken") {
+		t.Errorf("Secret extra vars saved with the run:\n%s", data)
+	}
+	state, err := LoadRunState(cfg, "prod-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.SecretVars, []string{"ansible_become_pass", "vault_token"}) {
+		t.Errorf("Unexpected secret vars %v", state.SecretVars)
+	}
+
+	if _, err := state.ResumeOptions(map[string]interface{}{"vault_token": "t0ken"}); err == nil || !strings.Contains(err.Error(), "ansible_become_pass") {
+		t.Errorf("Expected an error naming the missing secret, got %v", err)
+	}
+	opts, err := state.ResumeOptions(map[string]interface{}{"ansible_become_pass": "s3cret", "vault_token": "t0ken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opts.ExtraVars, vars) {
+		t.Errorf("Expected extra vars %v, got %v", vars, opts.ExtraVars)
+	}
+}
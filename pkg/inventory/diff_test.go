@@ -0,0 +1,73 @@
+package inventory
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			name:     "Identical",
+			a:        "a\nb\n",
+			b:        "a\nb\n",
+			expected: "",
+		},
+		{
+			name: "Insertion",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "1\n2\n3\n4\nx\n5\n6\n7\n8\n",
+			expected: `--- old
++++ new
+@@ -2,6 +2,7 @@
+ 2
+ 3
+ 4
++x
+ 5
+ 6
+ 7
+`,
+		},
+		{
+			name: "Separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			expected: `--- old
++++ new
+@@ -1,4 +1,4 @@
+-1
++one
+ 2
+ 3
+ 4
+@@ -7,4 +7,3 @@
+ 7
+ 8
+ 9
+-10
+`,
+		},
+		{
+			name: "From empty",
+			a:    "",
+			b:    "a\n",
+			expected: `--- old
++++ new
+@@ -0,0 +1,1 @@
++a
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("old", "new", []byte(tt.a), []byte(tt.b))
+			if got != tt.expected {
+				t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
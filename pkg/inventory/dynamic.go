@@ -0,0 +1,127 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// FromConfig returns the inventory described by the inventory section of the
+// configuration
+func FromConfig(cfg *config.Config) *Inventory {
+	ic := cfg.Inventory
+	inv := &Inventory{
+		Masters: append([]string{}, ic.Masters...),
+		Nodes:   append([]string{}, ic.Nodes...),
+		Etcd:    append([]string{}, ic.Etcd...),
+	}
+
+	for _, hc := range ic.Hosts {
+		inv.Hosts = append(inv.Hosts, Host{
+			Name:     hc.Name,
+			Address:  hc.Address,
+			IP:       hc.IP,
+			AccessIP: hc.AccessIP,
+			User:     hc.User,
+			Roles:    append([]string{}, hc.Roles...),
+			Vars:     hc.Vars,
+			Labels:   hc.Labels,
+			Taints:   append([]string{}, hc.Taints...),
+		})
+	}
+
+	return inv
+}
+
+// List returns inv in the JSON format Ansible expects from a dynamic
+// inventory script called with --list: one entry per group plus the
+// variables of every host under _meta.hostvars
+func (g *Generator) List(inv *Inventory) ([]byte, error) {
+	l, err := g.resolve(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	hostvars := map[string]interface{}{}
+	for _, h := range l.hosts {
+		hostvars[h.Name] = jsonHostVars(h)
+	}
+
+	groupNames := []string{}
+	result := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostvars},
+	}
+	for _, grp := range l.groups {
+		entry := map[string]interface{}{}
+		if len(grp.children) > 0 {
+			entry["children"] = grp.children
+		} else {
+			entry["hosts"] = nonNil(grp.hosts)
+		}
+		if len(grp.vars) > 0 {
+			entry["vars"] = grp.vars
+		}
+		result[grp.name] = entry
+		groupNames = append(groupNames, grp.name)
+	}
+	result["all"] = map[string]interface{}{
+		"children": groupNames,
+		"vars":     l.vars,
+	}
+
+	return encodeJSON(result)
+}
+
+// HostVars returns the variables of one host as the JSON object Ansible
+// expects from a dynamic inventory script called with --host
+func (g *Generator) HostVars(inv *Inventory, name string) ([]byte, error) {
+	l, err := g.resolve(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range l.hosts {
+		if h.Name == name {
+			return encodeJSON(jsonHostVars(h))
+		}
+	}
+	return nil, fmt.Errorf("host %s not found in inventory", name)
+}
+
+// resolve names, validates and lays out the hosts of inv
+func (g *Generator) resolve(inv *Inventory) (*layout, error) {
+	hosts, err := g.hosts(inv)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.validate(hosts); err != nil {
+		return nil, err
+	}
+	return g.layout(hosts, inv.GroupVars)
+}
+
+// jsonHostVars returns the variables of a host as a map
+func jsonHostVars(h Host) map[string]interface{} {
+	vars := map[string]interface{}{}
+	for _, v := range h.vars() {
+		vars[v.key] = v.value
+	}
+	return vars
+}
+
+// nonNil returns an empty list instead of nil so it encodes as []
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func encodeJSON(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inventory: %w", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,121 @@
+package inventory
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+func TestFromConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Inventory.Masters = []string{"10.0.0.1"}
+	cfg.Inventory.Nodes = []string{"10.0.0.11"}
+	cfg.Inventory.Hosts = []config.HostConfig{{
+		Name:    "gpu-0",
+		Address: "10.0.0.21",
+		Roles:   []string{RoleNode},
+		Labels:  map[string]string{"gpu": "true"},
+		Taints:  []string{"gpu=true:NoSchedule"},
+	}}
+
+	inv := FromConfig(cfg)
+	if !reflect.DeepEqual(inv.Masters, []string{"10.0.0.1"}) || !reflect.DeepEqual(inv.Nodes, []string{"10.0.0.11"}) {
+		t.Errorf("Unexpected role addresses: %+v", inv)
+	}
+	if len(inv.Hosts) != 1 || inv.Hosts[0].Name != "gpu-0" || inv.Hosts[0].Labels["gpu"] != "true" {
+		t.Errorf("Unexpected hosts: %+v", inv.Hosts)
+	}
+}
+
+func TestDynamicList(t *testing.T) {
+	gen := NewGenerator(config.NewConfig())
+	inv := &Inventory{
+		Masters: []string{"10.0.0.1"},
+		Nodes:   []string{"10.0.0.11"},
+		Hosts: []Host{{
+			Name:    "gpu-0",
+			Address: "10.0.0.21",
+			Roles:   []string{RoleNode},
+			Labels:  map[string]string{"gpu": "true"},
+		}},
+	}
+
+	data, err := gen.List(inv)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	var out struct {
+		Meta struct {
+			Hostvars map[string]map[string]interface{} `json:"hostvars"`
+		} `json:"_meta"`
+		All struct {
+			Children []string               `json:"children"`
+			Vars     map[string]interface{} `json:"vars"`
+		} `json:"all"`
+		ControlPlane struct {
+			Hosts []string `json:"hosts"`
+		} `json:"kube_control_plane"`
+		Node struct {
+			Hosts []string `json:"hosts"`
+		} `json:"kube_node"`
+		CalicoRR struct {
+			Hosts []string `json:"hosts"`
+		} `json:"calico_rr"`
+		Cluster struct {
+			Children []string `json:"children"`
+		} `json:"k8s_cluster"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("List output is not valid JSON: %v\n%s", err, data)
+	}
+
+	if !reflect.DeepEqual(out.ControlPlane.Hosts, []string{"master-0"}) {
+		t.Errorf("Unexpected kube_control_plane hosts: %v", out.ControlPlane.Hosts)
+	}
+	if !reflect.DeepEqual(out.Node.Hosts, []string{"node-0", "gpu-0"}) {
+		t.Errorf("Unexpected kube_node hosts: %v", out.Node.Hosts)
+	}
+	if out.CalicoRR.Hosts == nil || len(out.CalicoRR.Hosts) != 0 {
+		t.Errorf("Expected empty calico_rr hosts list, got %v", out.CalicoRR.Hosts)
+	}
+	if !reflect.DeepEqual(out.Cluster.Children, []string{RoleControlPlane, RoleNode, RoleCalicoRR}) {
+		t.Errorf("Unexpected k8s_cluster children: %v", out.Cluster.Children)
+	}
+	if out.All.Vars["loadbalancer_apiserver_localhost"] != true {
+		t.Errorf("Expected typed cluster vars, got %v", out.All.Vars)
+	}
+
+	gpu := out.Meta.Hostvars["gpu-0"]
+	if gpu["ansible_host"] != "10.0.0.21" || !reflect.DeepEqual(gpu["node_labels"], map[string]interface{}{"gpu": "true"}) {
+		t.Errorf("Unexpected hostvars for gpu-0: %v", gpu)
+	}
+}
+
+func TestDynamicHostVars(t *testing.T) {
+	gen := NewGenerator(config.NewConfig())
+	inv := &Inventory{
+		Masters: []string{"10.0.0.1"},
+		Nodes:   []string{"10.0.0.11"},
+	}
+
+	data, err := gen.HostVars(inv, "node-0")
+	if err != nil {
+		t.Fatalf("HostVars failed: %v", err)
+	}
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		t.Fatalf("HostVars output is not valid JSON: %v", err)
+	}
+	expected := map[string]interface{}{"ansible_host": "10.0.0.11", "ip": "10.0.0.11", "access_ip": "10.0.0.11"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Expected %v, got %v", expected, vars)
+	}
+
+	if _, err := gen.HostVars(inv, "node-9"); err == nil {
+		t.Error("Expected error for unknown host")
+	}
+}
@@ -0,0 +1,305 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// roleGroups lists the group names a role may use in an existing inventory,
+// preferred name first
+var roleGroups = map[string][]string{
+	RoleControlPlane: {RoleControlPlane, "kube-master"},
+	RoleNode:         {RoleNode, "kube-node"},
+	RoleEtcd:         {RoleEtcd},
+	RoleCalicoRR:     {RoleCalicoRR},
+}
+
+// AddHost adds h to the contents of an existing inventory in the given
+// format. The host is appended to all and to the group of each of its roles;
+// comments, variables and the order of existing entries are kept. A host
+// without a name is named from the configured hostname source.
+func (g *Generator) AddHost(data []byte, format string, h Host) ([]byte, error) {
+	inv, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Roles = append([]string{}, h.Roles...)
+	if len(h.Roles) == 0 {
+		return nil, fmt.Errorf("host %s: at least one role is required", h.Address)
+	}
+
+	names := map[string]string{}
+	for _, name := range inv.HostOrder {
+		names[name] = inv.Address(name)
+	}
+	if h.Name == "" {
+		name, err := g.freeHostname(h.Roles[0], h.Address, names)
+		if err != nil {
+			return nil, err
+		}
+		h.Name = name
+	}
+	if _, ok := names[h.Name]; ok {
+		return nil, fmt.Errorf("host %s already exists", h.Name)
+	}
+
+	h = h.withDefaults()
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range inv.HostOrder {
+		used := append(hostAddresses(inv, name), inv.Address(name))
+		for _, addr := range []string{h.Address, h.IP, h.AccessIP} {
+			if containsAddress(used, addr) {
+				return nil, fmt.Errorf("address %s is already used by host %s", addr, name)
+			}
+		}
+	}
+
+	if format == FormatYAML {
+		return addYAMLHost(data, h)
+	}
+	return addINIHost(data, h), nil
+}
+
+// RemoveHost removes a host, given by name or address, from the contents of
+// an existing inventory in the given format. Everything else is kept as is.
+func RemoveHost(data []byte, format, host string) ([]byte, error) {
+	inv, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	name, ok := inv.Lookup(host)
+	if !ok {
+		return nil, fmt.Errorf("host %s not found in inventory", host)
+	}
+
+	if format == FormatYAML {
+		return removeYAMLHost(data, name)
+	}
+	return removeINIHost(data, name), nil
+}
+
+// iniSection is a section of an INI inventory
+type iniSection struct {
+	name string
+	kind string
+	// last is the index of the last entry of the section, or of its header
+	// when it has none. Comments are not entries, so a comment above the
+	// next header stays with it.
+	last int
+}
+
+// iniSections returns the sections of an INI inventory in order
+func iniSections(lines []string) []iniSection {
+	sections := []iniSection{}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			name, kind, _ := strings.Cut(trimmed[1:len(trimmed)-1], ":")
+			sections = append(sections, iniSection{name: name, kind: kind, last: i})
+			continue
+		}
+		if trimmed != "" && !isINIComment(trimmed) && len(sections) > 0 {
+			sections[len(sections)-1].last = i
+		}
+	}
+	return sections
+}
+
+// isINIComment reports whether a trimmed INI line is a comment
+func isINIComment(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";")
+}
+
+// findINISection returns the host section with one of the given names
+func findINISection(lines []string, names []string) (iniSection, bool) {
+	sections := iniSections(lines)
+	for _, name := range names {
+		for _, sec := range sections {
+			if sec.name == name && sec.kind == "" {
+				return sec, true
+			}
+		}
+	}
+	return iniSection{}, false
+}
+
+// addINIHost appends the host line to [all] and the host name to the group
+// of each role, creating groups that do not exist at the end of the file
+func addINIHost(data []byte, h Host) []byte {
+	lines := splitLines(data)
+
+	if sec, ok := findINISection(lines, []string{"all"}); ok {
+		lines = insertLines(lines, sec.last+1, iniHostLine(h))
+	} else {
+		lines = insertLines(lines, 0, "[all]", iniHostLine(h), "")
+	}
+
+	for _, role := range h.Roles {
+		if sec, ok := findINISection(lines, roleGroups[role]); ok {
+			lines = insertLines(lines, sec.last+1, h.Name)
+			continue
+		}
+		lines = append(lines, "", "["+role+"]", h.Name)
+	}
+
+	return joinLines(lines)
+}
+
+// removeINIHost removes the lines naming the host from every host section
+func removeINIHost(data []byte, name string) []byte {
+	lines := splitLines(data)
+	kept := make([]string, 0, len(lines))
+
+	kind := ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			_, kind, _ = strings.Cut(trimmed[1:len(trimmed)-1], ":")
+		} else if kind == "" {
+			if fields, err := splitINIFields(trimmed); err == nil && len(fields) > 0 && fields[0] == name {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	return joinLines(kept)
+}
+
+// addYAMLHost adds the host with its variables to all.hosts and to the
+// group of each role under all.children
+func addYAMLHost(data []byte, h Host) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid YAML inventory: %w", err)
+	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{mappingNode()}}
+	}
+
+	vars, err := yamlHostVars(h)
+	if err != nil {
+		return nil, err
+	}
+
+	all := mappingValue(root.Content[0], "all")
+	addPair(mappingValue(all, "hosts"), h.Name, vars)
+
+	children := mappingValue(all, "children")
+	for _, role := range h.Roles {
+		groupName := role
+		for _, name := range roleGroups[role] {
+			if lookupKey(children, name) != nil {
+				groupName = name
+				break
+			}
+		}
+		grp := mappingValue(children, groupName)
+		addPair(mappingValue(grp, "hosts"), h.Name, nullNode())
+	}
+
+	return encodeYAML(&root)
+}
+
+// removeYAMLHost removes the host from every hosts mapping in the document
+func removeYAMLHost(data []byte, name string) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid YAML inventory: %w", err)
+	}
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind != yaml.MappingNode {
+			for _, c := range n.Content {
+				walk(c)
+			}
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			value := n.Content[i+1]
+			if n.Content[i].Value == "hosts" && value.Kind == yaml.MappingNode {
+				removeKey(value, name)
+			}
+			walk(value)
+		}
+	}
+	walk(&root)
+
+	return encodeYAML(&root)
+}
+
+// mappingValue returns the mapping stored under key in m, creating it, or
+// replacing an empty value, as needed. Empty flow mappings such as {} are
+// switched to block style so added entries are written one per line.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	value := lookupKey(m, key)
+	if value == nil {
+		value = mappingNode()
+		addPair(m, key, value)
+		return value
+	}
+	if value.Kind != yaml.MappingNode {
+		*value = yaml.Node{Kind: yaml.MappingNode, LineComment: value.LineComment}
+	}
+	if len(value.Content) == 0 {
+		value.Style = 0
+	}
+	return value
+}
+
+// lookupKey returns the value stored under key in a mapping node
+func lookupKey(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// removeKey deletes key from a mapping node
+func removeKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// insertLines inserts lines before index i
+func insertLines(lines []string, i int, added ...string) []string {
+	result := make([]string, 0, len(lines)+len(added))
+	result = append(result, lines[:i]...)
+	result = append(result, added...)
+	return append(result, lines[i:]...)
+}
+
+// joinLines joins lines into file contents ending in a newline
+func joinLines(lines []string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// containsAddress reports whether addr is in addrs, comparing IP addresses
+// by value
+func containsAddress(addrs []string, addr string) bool {
+	ip := net.ParseIP(addr)
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+		if other := net.ParseIP(a); ip != nil && other != nil && ip.Equal(other) {
+			return true
+		}
+	}
+	return false
+}
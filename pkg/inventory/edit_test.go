@@ -0,0 +1,207 @@
+package inventory
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+const editINI = `# Production cluster
+[all]
+master-0 ansible_host=10.0.0.1 ip=10.0.0.1
+node-0 ansible_host=10.0.0.11 ip=10.0.0.11 # rack 1
+
+[kube_control_plane]
+master-0
+
+[etcd]
+master-0
+
+[kube_node]
+node-0
+
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+
+[kube_node:vars]
+node_labels={"tier": "app"}
+`
+
+const editYAML = `# Production cluster
+all:
+  hosts:
+    master-0:
+      ansible_host: 10.0.0.1
+    node-0:
+      ansible_host: 10.0.0.11 # rack 1
+  children:
+    kube_control_plane:
+      hosts:
+        master-0:
+    etcd:
+      hosts:
+        master-0:
+    kube_node:
+      hosts:
+        node-0:
+    calico_rr:
+      hosts: {}
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+  vars:
+    ansible_user: ubuntu # default user
+`
+
+func TestAddHostINI(t *testing.T) {
+	gen := NewGenerator(config.NewConfig())
+
+	out, err := gen.AddHost([]byte(editINI), FormatINI, Host{
+		Address: "10.0.0.12",
+		User:    "admin",
+		Roles:   []string{RoleNode},
+	})
+	if err != nil {
+		t.Fatalf("AddHost failed: %v", err)
+	}
+
+	expected := strings.Replace(editINI,
+		"node-0 ansible_host=10.0.0.11 ip=10.0.0.11 # rack 1\n",
+		"node-0 ansible_host=10.0.0.11 ip=10.0.0.11 # rack 1\nnode-1 ansible_host=10.0.0.12 ip=10.0.0.12 access_ip=10.0.0.12 ansible_user=admin\n", 1)
+	expected = strings.Replace(expected, "[kube_node]\nnode-0\n", "[kube_node]\nnode-0\nnode-1\n", 1)
+	if string(out) != expected {
+		t.Errorf("Unexpected inventory:\n%s\nwant:\n%s", out, expected)
+	}
+}
+
+func TestAddHostINIComments(t *testing.T) {
+	gen := NewGenerator(config.NewConfig())
+	data := `[all]
+master-0 ansible_host=10.0.0.1 ip=10.0.0.1
+
+# control plane nodes
+[kube_control_plane]
+master-0
+; workers
+[kube_node]
+# none yet
+`
+
+	out, err := gen.AddHost([]byte(data), FormatINI, Host{Address: "10.0.0.12", Roles: []string{RoleNode}})
+	if err != nil {
+		t.Fatalf("AddHost failed: %v", err)
+	}
+
+	expected := `[all]
+master-0 ansible_host=10.0.0.1 ip=10.0.0.1
+node-0 ansible_host=10.0.0.12 ip=10.0.0.12 access_ip=10.0.0.12
+
+# control plane nodes
+[kube_control_plane]
+master-0
+; workers
+[kube_node]
+node-0
+# none yet
+`
+	if string(out) != expected {
+		t.Errorf("Unexpected inventory:\n%s\nwant:\n%s", out, expected)
+	}
+}
+
+func TestAddHostYAML(t *testing.T) {
+	gen := NewGenerator(config.NewConfig())
+
+	out, err := gen.AddHost([]byte(editYAML), FormatYAML, Host{
+		Name:    "rr-0",
+		Address: "10.0.0.21",
+		Roles:   []string{RoleCalicoRR},
+	})
+	if err != nil {
+		t.Fatalf("AddHost failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"# Production cluster",
+		"ansible_host: 10.0.0.11 # rack 1",
+		"ansible_user: ubuntu # default user",
+		"    rr-0:\n      ansible_host: 10.0.0.21\n      ip: 10.0.0.21\n      access_ip: 10.0.0.21\n",
+		"    calico_rr:\n      hosts:\n        rr-0:\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected %q in inventory:\n%s", want, out)
+		}
+	}
+
+	inv, err := ParseYAML(out)
+	if err != nil {
+		t.Fatalf("Edited inventory does not parse: %v", err)
+	}
+	if members := inv.Members("calico_rr"); len(members) != 1 || members[0] != "rr-0" {
+		t.Errorf("Expected rr-0 in calico_rr, got %v", members)
+	}
+}
+
+func TestAddHostErrors(t *testing.T) {
+	gen := NewGenerator(config.NewConfig())
+
+	tests := []struct {
+		name string
+		host Host
+	}{
+		{"Duplicate name", Host{Name: "node-0", Address: "10.0.0.30", Roles: []string{RoleNode}}},
+		{"Duplicate address", Host{Address: "10.0.0.11", Roles: []string{RoleNode}}},
+		{"Duplicate internal IP", Host{Address: "203.0.113.5", IP: "10.0.0.1", Roles: []string{RoleNode}}},
+		{"No roles", Host{Address: "10.0.0.30"}},
+		{"Invalid taint", Host{Address: "10.0.0.30", Roles: []string{RoleNode}, Taints: []string{"gpu"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := gen.AddHost([]byte(editINI), FormatINI, tt.host); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestRemoveHostINI(t *testing.T) {
+	out, err := RemoveHost([]byte(editINI), FormatINI, "10.0.0.11")
+	if err != nil {
+		t.Fatalf("RemoveHost failed: %v", err)
+	}
+
+	expected := strings.Replace(editINI, "node-0 ansible_host=10.0.0.11 ip=10.0.0.11 # rack 1\n", "", 1)
+	expected = strings.Replace(expected, "[kube_node]\nnode-0\n", "[kube_node]\n", 1)
+	if string(out) != expected {
+		t.Errorf("Unexpected inventory:\n%s\nwant:\n%s", out, expected)
+	}
+
+	if _, err := RemoveHost([]byte(editINI), FormatINI, "node-9"); err == nil {
+		t.Error("Expected error for unknown host")
+	}
+}
+
+func TestRemoveHostYAML(t *testing.T) {
+	out, err := RemoveHost([]byte(editYAML), FormatYAML, "master-0")
+	if err != nil {
+		t.Fatalf("RemoveHost failed: %v", err)
+	}
+	if strings.Contains(string(out), "master-0") {
+		t.Errorf("Expected master-0 to be removed:\n%s", out)
+	}
+	if !strings.Contains(string(out), "# Production cluster") || !strings.Contains(string(out), "# default user") {
+		t.Errorf("Expected comments to be kept:\n%s", out)
+	}
+
+	inv, err := ParseYAML(out)
+	if err != nil {
+		t.Fatalf("Edited inventory does not parse: %v", err)
+	}
+	if len(inv.Members("kube_control_plane")) != 0 || len(inv.HostOrder) != 1 {
+		t.Errorf("Unexpected inventory after removal: %+v", inv)
+	}
+}
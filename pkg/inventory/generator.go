@@ -0,0 +1,526 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/network"
+)
+
+// Inventory describes the hosts of a cluster. Masters, Nodes and Etcd list
+// plain addresses by role; Hosts carries hosts with their own names, addresses
+// and variables.
+type Inventory struct {
+	Masters []string
+	Nodes   []string
+	Etcd    []string
+	Hosts   []Host
+	// GroupVars holds variables by group name. Variables of the all group
+	// override those derived from the configuration.
+	GroupVars map[string]map[string]interface{}
+}
+
+// Generator creates Ansible inventory files for Kubespray
+type Generator struct {
+	config   *config.Config
+	resolver HostnameResolver
+}
+
+// NewGenerator creates a new inventory generator
+func NewGenerator(cfg *config.Config) *Generator {
+	return &Generator{config: cfg}
+}
+
+// WithResolver sets the resolver used by the dns and ssh hostname sources.
+// Without one, the dns source uses reverse DNS lookups.
+func (g *Generator) WithResolver(r HostnameResolver) *Generator {
+	g.resolver = r
+	return g
+}
+
+// Inventory file formats
+const (
+	FormatINI  = "ini"
+	FormatYAML = "yaml"
+)
+
+// group is an inventory group listing either hosts or child groups
+type group struct {
+	name     string
+	hosts    []string
+	children []string
+	vars     map[string]interface{}
+}
+
+// layout is the structure of an inventory shared by the INI and YAML writers
+type layout struct {
+	hosts  []Host
+	groups []group
+	vars   map[string]interface{}
+}
+
+// DetectFormat returns the inventory format implied by a file extension:
+// YAML for .yaml and .yml, INI otherwise
+func DetectFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	default:
+		return FormatINI
+	}
+}
+
+// Hosts returns the hosts of inv as they are rendered: named from the
+// configured hostname source, with their roles and defaults
+func (g *Generator) Hosts(inv *Inventory) ([]Host, error) {
+	hosts, err := g.hosts(inv)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.validate(hosts); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// Generate writes an inventory for inv to path, as YAML when the path ends
+// in .yaml or .yml and as INI otherwise
+func (g *Generator) Generate(inv *Inventory, path string) error {
+	return g.GenerateFormat(inv, path, DetectFormat(path))
+}
+
+// GenerateFormat writes an inventory for inv to path in the given format
+func (g *Generator) GenerateFormat(inv *Inventory, path, format string) error {
+	l, err := g.resolve(inv)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch format {
+	case FormatINI:
+		data = renderINI(l)
+	case FormatYAML:
+		data, err = renderYAML(l)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported inventory format %q", format)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create inventory directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	return nil
+}
+
+// layout arranges hosts into Kubespray's groups. Hosts are listed control
+// plane first, then etcd, then workers.
+func (g *Generator) layout(hosts []Host, groupVars map[string]map[string]interface{}) (*layout, error) {
+	l := &layout{}
+	seen := map[string]bool{}
+	for _, role := range []string{RoleControlPlane, RoleEtcd, RoleNode, RoleCalicoRR} {
+		for _, h := range withRole(hosts, role) {
+			if seen[h.Name] {
+				continue
+			}
+			seen[h.Name] = true
+			l.hosts = append(l.hosts, h)
+		}
+	}
+
+	l.groups = []group{
+		{name: RoleControlPlane, hosts: hostNames(withRole(hosts, RoleControlPlane))},
+		{name: RoleEtcd, hosts: hostNames(withRole(hosts, RoleEtcd))},
+		{name: RoleNode, hosts: hostNames(withRole(hosts, RoleNode))},
+		{name: RoleCalicoRR, hosts: hostNames(withRole(hosts, RoleCalicoRR))},
+		{name: "k8s_cluster", children: []string{RoleControlPlane, RoleNode, RoleCalicoRR}},
+	}
+
+	vars, err := g.clusterVars(hosts)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range groupVars["all"] {
+		vars[k] = v
+	}
+	l.vars = vars
+
+	// Attach group vars, adding groups that only carry variables
+	names := make([]string, 0, len(groupVars))
+	for name := range groupVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "all" || len(groupVars[name]) == 0 {
+			continue
+		}
+		found := false
+		for i := range l.groups {
+			if l.groups[i].name == name {
+				l.groups[i].vars = groupVars[name]
+				found = true
+			}
+		}
+		if !found {
+			l.groups = append(l.groups, group{name: name, vars: groupVars[name]})
+		}
+	}
+
+	return l, nil
+}
+
+// renderINI renders a layout as an INI inventory
+func renderINI(l *layout) []byte {
+	var b strings.Builder
+
+	b.WriteString("[all]\n")
+	for _, h := range l.hosts {
+		b.WriteString(iniHostLine(h) + "\n")
+	}
+
+	for _, grp := range l.groups {
+		if len(grp.children) > 0 {
+			fmt.Fprintf(&b, "\n[%s:children]\n", grp.name)
+			for _, child := range grp.children {
+				b.WriteString(child + "\n")
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "\n[%s]\n", grp.name)
+		for _, name := range grp.hosts {
+			b.WriteString(name + "\n")
+		}
+	}
+
+	writeVars(&b, "all", l.vars)
+	for _, grp := range l.groups {
+		writeVars(&b, grp.name, grp.vars)
+	}
+
+	return []byte(b.String())
+}
+
+// iniHostLine renders a host and its variables as an INI host line
+func iniHostLine(h Host) string {
+	var b strings.Builder
+	b.WriteString(h.Name)
+	for _, v := range h.vars() {
+		fmt.Fprintf(&b, " %s=%s", v.key, quoteINIValue(formatINIValue(v.value)))
+	}
+	return b.String()
+}
+
+// hostNames returns the names of hosts in order
+func hostNames(hosts []Host) []string {
+	names := make([]string, len(hosts))
+	for i, h := range hosts {
+		names[i] = h.Name
+	}
+	return names
+}
+
+// withRole returns the hosts that have role, in order
+func withRole(hosts []Host, role string) []Host {
+	matched := []Host{}
+	for _, h := range hosts {
+		if h.HasRole(role) {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
+
+// validate checks the hosts include the required roles and that the API
+// endpoint fits the control plane
+func (g *Generator) validate(hosts []Host) error {
+	masters := withRole(hosts, RoleControlPlane)
+	if len(masters) == 0 {
+		return fmt.Errorf("at least one master node is required")
+	}
+	if len(withRole(hosts, RoleNode)) == 0 {
+		return fmt.Errorf("at least one worker node is required")
+	}
+
+	ips := make([]string, len(masters))
+	for i, h := range masters {
+		ips[i] = h.IP
+	}
+	if err := network.NewCalculator().ValidateAPIEndpoint(g.config.APIEndpoint, ips); err != nil {
+		return fmt.Errorf("invalid API endpoint: %w", err)
+	}
+
+	return nil
+}
+
+// clusterVars returns the variables derived from configuration: the API
+// endpoint settings and, when enabled, the allocated MetalLB pools
+func (g *Generator) clusterVars(hosts []Host) (map[string]interface{}, error) {
+	vars := network.APIEndpointVars(g.config.APIEndpoint)
+
+	if g.config.MetalLB.Enabled {
+		nodeIPs := []string{}
+		for _, h := range hosts {
+			nodeIPs = appendUnique(nodeIPs, h.IP)
+			nodeIPs = appendUnique(nodeIPs, h.AccessIP)
+		}
+		pools, err := network.NewCalculator().AllocatePools(g.config, nodeIPs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate MetalLB pools: %w", err)
+		}
+		for k, v := range network.MetalLBVars(pools, g.config.MetalLB.Peers) {
+			vars[k] = v
+		}
+	}
+
+	return vars, nil
+}
+
+// hosts merges the addresses listed by role with the explicit hosts of inv.
+// Masters, workers and etcd members are named from the configured hostname
+// source in the order they are listed, so regenerating from the same input
+// gives the same names. An address listed in more than one role is a single
+// host with several roles. When no host has the etcd role, the masters run
+// etcd. Explicit hosts without a name are named after their first role.
+func (g *Generator) hosts(inv *Inventory) ([]Host, error) {
+	if inv == nil {
+		return nil, fmt.Errorf("inventory is nil")
+	}
+
+	for _, group := range [][]string{inv.Masters, inv.Nodes, inv.Etcd} {
+		for _, ip := range group {
+			if net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", ip)
+			}
+		}
+	}
+
+	hosts := []Host{}
+	byIP := map[string]int{}
+	for _, role := range []struct {
+		name  string
+		addrs []string
+	}{
+		{RoleControlPlane, inv.Masters},
+		{RoleNode, inv.Nodes},
+		{RoleEtcd, inv.Etcd},
+	} {
+		for i, ip := range role.addrs {
+			if idx, ok := byIP[ip]; ok {
+				hosts[idx].Roles = appendUnique(hosts[idx].Roles, role.name)
+				continue
+			}
+			name, err := g.hostname(role.name, i, ip)
+			if err != nil {
+				return nil, err
+			}
+			byIP[ip] = len(hosts)
+			hosts = append(hosts, Host{Name: name, Address: ip, Roles: []string{role.name}})
+		}
+	}
+
+	names := map[string]string{}
+	for _, h := range hosts {
+		if other, ok := names[h.Name]; ok {
+			return nil, fmt.Errorf("duplicate host name %s for %s and %s", h.Name, other, h.Address)
+		}
+		names[h.Name] = h.Address
+	}
+
+	for _, h := range inv.Hosts {
+		h.Roles = append([]string{}, h.Roles...)
+		if h.Name == "" {
+			if len(h.Roles) == 0 {
+				return nil, fmt.Errorf("host %s: at least one role is required", h.Address)
+			}
+			name, err := g.freeHostname(h.Roles[0], h.Address, names)
+			if err != nil {
+				return nil, err
+			}
+			h.Name = name
+		}
+		if other, ok := names[h.Name]; ok {
+			return nil, fmt.Errorf("duplicate host name %s for %s and %s", h.Name, other, h.Address)
+		}
+		names[h.Name] = h.Address
+		hosts = append(hosts, h)
+	}
+
+	if len(withRole(hosts, RoleEtcd)) == 0 {
+		for i := range hosts {
+			if hosts[i].HasRole(RoleControlPlane) {
+				hosts[i].Roles = append(hosts[i].Roles, RoleEtcd)
+			}
+		}
+	}
+
+	for i := range hosts {
+		hosts[i] = hosts[i].withDefaults()
+		if err := hosts[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return hosts, nil
+}
+
+// hostname names the index-th host of a role from the configured source
+func (g *Generator) hostname(role string, index int, address string) (string, error) {
+	switch source := g.config.Inventory.Hostnames.Source; source {
+	case "", HostnameTemplate:
+		return renderHostname(g.hostnameTemplate(role), g.config.Inventory.ClusterName, index, address)
+
+	case HostnameDNS, HostnameSSH:
+		resolver := g.resolver
+		if resolver == nil {
+			if source == HostnameSSH {
+				return "", fmt.Errorf("hostname source ssh requires an SSH resolver")
+			}
+			resolver = DNSResolver{}
+		}
+		name, err := resolver.Hostname(address)
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve hostname of %s: %w", address, err)
+		}
+		name = normalizeHostname(name)
+		if err := validateHostname(name); err != nil {
+			return "", fmt.Errorf("hostname of %s: %w", address, err)
+		}
+		return name, nil
+
+	default:
+		return "", fmt.Errorf("unknown hostname source %q", source)
+	}
+}
+
+// freeHostname names an explicit host, taking the first index whose
+// templated name is not already used
+func (g *Generator) freeHostname(role, address string, names map[string]string) (string, error) {
+	source := g.config.Inventory.Hostnames.Source
+	if source != "" && source != HostnameTemplate {
+		return g.hostname(role, 0, address)
+	}
+
+	template := g.hostnameTemplate(role)
+	for i := 0; ; i++ {
+		name, err := g.hostname(role, i, address)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := names[name]; !ok || !usesIndex(template) {
+			return name, nil
+		}
+	}
+}
+
+// hostnameTemplate returns the configured template for a role, falling back
+// to role-N
+func (g *Generator) hostnameTemplate(role string) string {
+	naming := g.config.Inventory.Hostnames
+	switch {
+	case role == RoleControlPlane && naming.ControlPlane != "":
+		return naming.ControlPlane
+	case role == RoleControlPlane:
+		return "master-{{index}}"
+	case role == RoleNode && naming.Node != "":
+		return naming.Node
+	case role == RoleNode:
+		return "node-{{index}}"
+	case role == RoleEtcd && naming.Etcd != "":
+		return naming.Etcd
+	default:
+		return strings.ReplaceAll(role, "_", "-") + "-{{index}}"
+	}
+}
+
+// writeVars writes an INI group vars section with keys in sorted order
+func writeVars(b *strings.Builder, group string, vars map[string]interface{}) {
+	if len(vars) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(b, "\n[%s:vars]\n", group)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s=%s\n", k, formatINIValue(vars[k]))
+	}
+}
+
+// quoteINIValue quotes a host variable value that contains whitespace so
+// Ansible keeps it as a single value
+func quoteINIValue(s string) string {
+	if !strings.ContainsAny(s, " \t") {
+		return s
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// formatINIValue renders a value so Ansible's INI parser, which evaluates
+// values as Python literals, reads back the same type
+func formatINIValue(v interface{}) string {
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return pythonLiteral(v)
+}
+
+// pythonLiteral renders booleans, numbers, strings, lists and maps as a
+// Python literal with map keys in sorted order
+func pythonLiteral(v interface{}) string {
+	switch val := v.(type) {
+	case bool:
+		if val {
+			return "True"
+		}
+		return "False"
+	case string:
+		data, _ := json.Marshal(val)
+		return string(data)
+	case []string:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = pythonLiteral(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []interface{}:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = pythonLiteral(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = pythonLiteral(k) + ": " + pythonLiteral(val[k])
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
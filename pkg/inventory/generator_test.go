@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	
+	"gopkg.in/yaml.v3"
+
 	"github.com/vjranagit/kubespray/pkg/config"
 )
 
@@ -139,3 +141,146 @@ func TestInventoryHostnameGeneration(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateAPIEndpointVars(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.APIEndpoint.Mode = "kube-vip"
+	cfg.APIEndpoint.Address = "192.168.1.100"
+	cfg.APIEndpoint.Subnet = "192.168.1.0/24"
+	gen := NewGenerator(cfg)
+
+	inv := &Inventory{
+		Masters: []string{"192.168.1.10", "192.168.1.11", "192.168.1.12"},
+		Nodes:   []string{"192.168.1.20"},
+	}
+
+	tmpFile := filepath.Join(t.TempDir(), "hosts.ini")
+	if err := gen.Generate(inv, tmpFile); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(tmpFile)
+	contentStr := string(content)
+
+	expected := []string{
+		"[all:vars]",
+		"kube_vip_enabled=True",
+		"kube_vip_address=192.168.1.100",
+		`loadbalancer_apiserver={"address": "192.168.1.100", "port": 6443}`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(contentStr, line) {
+			t.Errorf("Expected %q in inventory", line)
+		}
+	}
+
+	cfg.APIEndpoint.Address = "192.168.1.11"
+	if err := gen.Generate(inv, tmpFile); err == nil {
+		t.Error("Expected error when VIP clashes with a master")
+	}
+}
+
+func TestGenerateMetalLBVars(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.MetalLB.Enabled = true
+	cfg.MetalLB.Range = "192.168.1.200-192.168.1.250"
+	cfg.MetalLB.Pools = []config.MetalLBPool{{Name: "primary", Size: 10}}
+	gen := NewGenerator(cfg)
+
+	inv := &Inventory{
+		Masters: []string{"192.168.1.200"},
+		Nodes:   []string{"192.168.1.201"},
+	}
+
+	tmpFile := filepath.Join(t.TempDir(), "hosts.ini")
+	if err := gen.Generate(inv, tmpFile); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(tmpFile)
+	expected := `metallb_config={"address_pools": {"primary": {"auto_assign": True, "ip_range": ["192.168.1.202-192.168.1.211"]}}, "layer2": ["primary"]}`
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("Expected %q in inventory, got:\n%s", expected, content)
+	}
+}
+
+func TestGenerateYAMLInventory(t *testing.T) {
+	cfg := config.NewConfig()
+	gen := NewGenerator(cfg)
+
+	inv := &Inventory{
+		Masters: []string{"192.168.1.10", "192.168.1.11"},
+		Nodes:   []string{"192.168.1.20"},
+		Etcd:    []string{"192.168.1.10", "192.168.1.11", "192.168.1.12"},
+	}
+
+	tmpFile := filepath.Join(t.TempDir(), "hosts.yaml")
+	if err := gen.Generate(inv, tmpFile); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	content, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to read inventory file: %v", err)
+	}
+
+	var doc struct {
+		All struct {
+			Hosts    map[string]map[string]string `yaml:"hosts"`
+			Children map[string]struct {
+				Hosts    map[string]interface{} `yaml:"hosts"`
+				Children map[string]interface{} `yaml:"children"`
+			} `yaml:"children"`
+			Vars map[string]interface{} `yaml:"vars"`
+		} `yaml:"all"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("Generated inventory is not valid YAML: %v\n%s", err, content)
+	}
+
+	etcd2 := doc.All.Hosts["etcd-2"]
+	if etcd2["ansible_host"] != "192.168.1.12" || etcd2["ip"] != "192.168.1.12" || etcd2["access_ip"] != "192.168.1.12" {
+		t.Errorf("Unexpected host vars for etcd-2: %v", etcd2)
+	}
+	if len(doc.All.Hosts) != 4 {
+		t.Errorf("Expected 4 hosts, got %d", len(doc.All.Hosts))
+	}
+
+	expectedMembers := map[string][]string{
+		"kube_control_plane": {"master-0", "master-1"},
+		"etcd":               {"master-0", "master-1", "etcd-2"},
+		"kube_node":          {"node-0"},
+	}
+	for grp, members := range expectedMembers {
+		for _, name := range members {
+			if _, ok := doc.All.Children[grp].Hosts[name]; !ok {
+				t.Errorf("Expected %s in group %s", name, grp)
+			}
+		}
+	}
+
+	for _, child := range []string{"kube_control_plane", "kube_node", "calico_rr"} {
+		if _, ok := doc.All.Children["k8s_cluster"].Children[child]; !ok {
+			t.Errorf("Expected %s in k8s_cluster children", child)
+		}
+	}
+
+	if doc.All.Vars["loadbalancer_apiserver_localhost"] != true {
+		t.Errorf("Expected API endpoint vars, got %v", doc.All.Vars)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"hosts.yaml":          FormatYAML,
+		"inventory/hosts.YML": FormatYAML,
+		"hosts.ini":           FormatINI,
+		"inventory/mycluster": FormatINI,
+	}
+
+	for path, expected := range tests {
+		if got := DetectFormat(path); got != expected {
+			t.Errorf("DetectFormat(%s): expected %s, got %s", path, expected, got)
+		}
+	}
+}
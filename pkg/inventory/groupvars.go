@@ -0,0 +1,280 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Group vars files written by GenerateDir, relative to the inventory
+// directory, in the layout of the upstream Kubespray sample inventory
+const (
+	allVarsFile     = "group_vars/all/all.yml"
+	clusterVarsFile = "group_vars/k8s_cluster/k8s-cluster.yml"
+	addonsVarsFile  = "group_vars/k8s_cluster/addons.yml"
+)
+
+// managedComment marks variables written from the configuration. Marked
+// variables are rewritten on every render; a variable without the marker is
+// a user override and is left alone.
+const managedComment = "# managed by kubespray"
+
+// varsFileHeader opens group vars files created by GenerateDir
+const varsFileHeader = `# Generated from the kubespray CLI configuration. Variables marked
+# "managed by kubespray" are rewritten on every render; remove the marker
+# to keep an edited value, or add variables of your own.`
+
+// GenerateDir writes a complete Kubespray inventory directory for inv: the
+// hosts file (hosts.yaml or hosts.ini depending on format) and group_vars
+// for all, k8s_cluster and its addons rendered from the configuration, plus
+// a file for every other group with variables in inv.GroupVars.
+//
+// The hosts file is rewritten. Group vars files are updated in place: marked
+// variables are replaced, variables the user added or edited (and unmarked)
+// are kept along with their comments.
+func (g *Generator) GenerateDir(inv *Inventory, dir, format string) error {
+	hosts, err := g.hosts(inv)
+	if err != nil {
+		return err
+	}
+	if err := g.validate(hosts); err != nil {
+		return err
+	}
+
+	// Group vars live in their own files, so the hosts file only lists hosts
+	l, err := g.layout(hosts, nil)
+	if err != nil {
+		return err
+	}
+	l.vars = nil
+
+	var data []byte
+	hostsFile := "hosts.ini"
+	switch format {
+	case FormatINI:
+		data = renderINI(l)
+	case FormatYAML:
+		hostsFile = "hosts.yaml"
+		data, err = renderYAML(l)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported inventory format %q", format)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create inventory directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, hostsFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	files, err := g.groupVarsFiles(hosts, inv.GroupVars)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(files) {
+		if err := writeVarsFile(filepath.Join(dir, name), files[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// groupVarsFiles returns the variables of each group vars file by path.
+// Variables set in groupVars override those derived from the configuration.
+func (g *Generator) groupVarsFiles(hosts []Host, groupVars map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
+	k8s := g.config.Kubernetes
+	cluster := map[string]interface{}{
+		"kube_version":           k8s.Version,
+		"kube_network_plugin":    k8s.NetworkPlugin,
+		"kube_service_addresses": k8s.ServiceSubnet,
+		"kube_pods_subnet":       k8s.PodSubnet,
+	}
+	if k8s.NodePrefix > 0 {
+		cluster["kube_network_node_prefix"] = k8s.NodePrefix
+	}
+	if k8s.MaxPods > 0 {
+		cluster["kubelet_max_pods"] = k8s.MaxPods
+	}
+
+	addons := g.addonVars()
+	vars, err := g.clusterVars(hosts)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]interface{}{}
+	for k, v := range vars {
+		// MetalLB settings belong with the other addons
+		if _, ok := addons[k]; ok || g.config.MetalLB.Enabled && isMetalLBVar(k) {
+			addons[k] = v
+			continue
+		}
+		all[k] = v
+	}
+
+	files := map[string]map[string]interface{}{
+		allVarsFile:     all,
+		clusterVarsFile: cluster,
+		addonsVarsFile:  addons,
+	}
+
+	for _, name := range sortedKeys(groupVars) {
+		if len(groupVars[name]) == 0 {
+			continue
+		}
+
+		// Drop generated values the group's variables override from files
+		// Ansible would otherwise give precedence to
+		path := filepath.ToSlash(filepath.Join("group_vars", name+".yml"))
+		var shadowed []string
+		switch name {
+		case "all":
+			path = allVarsFile
+			shadowed = []string{clusterVarsFile, addonsVarsFile}
+		case "k8s_cluster":
+			path = clusterVarsFile
+			shadowed = []string{addonsVarsFile}
+		}
+		for _, file := range shadowed {
+			for k := range groupVars[name] {
+				delete(files[file], k)
+			}
+		}
+
+		if files[path] == nil {
+			files[path] = map[string]interface{}{}
+		}
+		for k, v := range groupVars[name] {
+			files[path][k] = v
+		}
+	}
+
+	return files, nil
+}
+
+// addonVars returns the addon switches of the configuration
+func (g *Generator) addonVars() map[string]interface{} {
+	a := g.config.Addons
+	return map[string]interface{}{
+		"helm_enabled":                   a.Helm,
+		"metrics_server_enabled":         a.MetricsServer,
+		"ingress_nginx_enabled":          a.IngressNginx,
+		"cert_manager_enabled":           a.CertManager,
+		"local_path_provisioner_enabled": a.LocalPathProvisioner,
+		"dashboard_enabled":              a.Dashboard,
+		"metallb_enabled":                g.config.MetalLB.Enabled,
+	}
+}
+
+// isMetalLBVar reports whether a variable comes from the MetalLB settings
+func isMetalLBVar(key string) bool {
+	switch key {
+	case "metallb_speaker_enabled", "metallb_namespace", "metallb_config", "kube_proxy_strict_arp":
+		return true
+	}
+	return false
+}
+
+// writeVarsFile updates the group vars file at path with vars, creating it
+// when missing
+func writeVarsFile(path string, vars map[string]interface{}) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	data, err := mergeVars(existing, vars)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// mergeVars renders vars into the contents of an existing group vars file.
+// Marked variables are replaced with their new value, or removed when no
+// longer generated; unmarked variables are kept, so a value the user edited
+// after removing the marker overrides the generated one. New variables are
+// appended in sorted order.
+func mergeVars(existing []byte, vars map[string]interface{}) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(existing, &root); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{mappingNode()}}
+		root.HeadComment = varsFileHeader
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("group vars must be a mapping")
+	}
+
+	pending := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		pending[k] = v
+	}
+
+	content := make([]*yaml.Node, 0, len(doc.Content))
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		v, generated := pending[key.Value]
+		delete(pending, key.Value)
+
+		if key.LineComment != managedComment && value.LineComment != managedComment {
+			content = append(content, key, value)
+			continue
+		}
+		if !generated {
+			continue
+		}
+		node, err := managedValue(key, v)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, node...)
+	}
+
+	for _, k := range sortedKeys(pending) {
+		node, err := managedValue(scalarNode(k), pending[k])
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, node...)
+	}
+	doc.Content = content
+
+	return encodeYAML(&root)
+}
+
+// managedValue returns a key and value pair carrying the managed marker
+func managedValue(key *yaml.Node, v interface{}) ([]*yaml.Node, error) {
+	value := &yaml.Node{}
+	if err := value.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", key.Value, err)
+	}
+	key.LineComment = managedComment
+	return []*yaml.Node{key, value}, nil
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
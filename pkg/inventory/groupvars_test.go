@@ -0,0 +1,198 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// readVars decodes a group vars file written by a test
+func readVars(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return vars
+}
+
+func TestGenerateDir(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Kubernetes.NetworkPlugin = "cilium"
+	cfg.Addons.Helm = true
+	gen := NewGenerator(cfg)
+
+	inv := &Inventory{
+		Masters: []string{"10.0.0.1"},
+		Nodes:   []string{"10.0.0.11"},
+		GroupVars: map[string]map[string]interface{}{
+			"k8s_cluster": {"helm_enabled": false, "kube_proxy_mode": "ipvs"},
+			"etcd":        {"etcd_deployment_type": "host"},
+		},
+	}
+
+	dir := t.TempDir()
+	if err := gen.GenerateDir(inv, dir, FormatYAML); err != nil {
+		t.Fatalf("GenerateDir failed: %v", err)
+	}
+
+	parsed, err := ParseFile(filepath.Join(dir, "hosts.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to parse hosts file: %v", err)
+	}
+	if !reflect.DeepEqual(parsed.Members("kube_control_plane"), []string{"master-0"}) {
+		t.Errorf("Unexpected control plane: %v", parsed.Members("kube_control_plane"))
+	}
+	if len(parsed.Groups["all"].Vars) != 0 {
+		t.Errorf("Expected no vars in hosts file, got %v", parsed.Groups["all"].Vars)
+	}
+
+	tests := []struct {
+		file     string
+		expected map[string]interface{}
+	}{
+		{"group_vars/all/all.yml", map[string]interface{}{
+			"loadbalancer_apiserver_localhost": true,
+			"loadbalancer_apiserver_type":      "nginx",
+			"loadbalancer_apiserver_port":      6443,
+		}},
+		{"group_vars/k8s_cluster/k8s-cluster.yml", map[string]interface{}{
+			"kube_version":             "v1.29.0",
+			"kube_network_plugin":      "cilium",
+			"kube_service_addresses":   "10.233.0.0/18",
+			"kube_pods_subnet":         "10.233.64.0/18",
+			"kube_network_node_prefix": 24,
+			"kubelet_max_pods":         110,
+			"helm_enabled":             false,
+			"kube_proxy_mode":          "ipvs",
+		}},
+		{"group_vars/k8s_cluster/addons.yml", map[string]interface{}{
+			"metrics_server_enabled":         false,
+			"ingress_nginx_enabled":          false,
+			"cert_manager_enabled":           false,
+			"local_path_provisioner_enabled": false,
+			"dashboard_enabled":              false,
+			"metallb_enabled":                false,
+		}},
+		{"group_vars/etcd.yml", map[string]interface{}{
+			"etcd_deployment_type": "host",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			vars := readVars(t, filepath.Join(dir, tt.file))
+			if !reflect.DeepEqual(vars, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, vars)
+			}
+		})
+	}
+}
+
+func TestGenerateDirPreservesOverrides(t *testing.T) {
+	cfg := config.NewConfig()
+	gen := NewGenerator(cfg)
+	inv := &Inventory{Masters: []string{"10.0.0.1"}, Nodes: []string{"10.0.0.11"}}
+
+	dir := t.TempDir()
+	if err := gen.GenerateDir(inv, dir, FormatINI); err != nil {
+		t.Fatalf("GenerateDir failed: %v", err)
+	}
+
+	// Take over kube_version by dropping its marker and add a variable
+	path := filepath.Join(dir, "group_vars/k8s_cluster/k8s-cluster.yml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(data), "kube_version: v1.29.0 "+managedComment, "kube_version: v1.28.6", 1)
+	if edited == string(data) {
+		t.Fatalf("kube_version not marked as managed:\n%s", data)
+	}
+	edited += "# Pinned for the storage driver\ncontainer_manager: crio\n"
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Kubernetes.Version = "v1.30.0"
+	cfg.Kubernetes.NetworkPlugin = "flannel"
+	if err := gen.GenerateDir(inv, dir, FormatINI); err != nil {
+		t.Fatalf("GenerateDir failed: %v", err)
+	}
+
+	vars := readVars(t, path)
+	if vars["kube_version"] != "v1.28.6" {
+		t.Errorf("Expected user kube_version to be kept, got %v", vars["kube_version"])
+	}
+	if vars["kube_network_plugin"] != "flannel" {
+		t.Errorf("Expected managed kube_network_plugin to be updated, got %v", vars["kube_network_plugin"])
+	}
+	if vars["container_manager"] != "crio" {
+		t.Errorf("Expected user variable to be kept, got %v", vars["container_manager"])
+	}
+
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# Pinned for the storage driver") {
+		t.Errorf("Expected user comment to be kept:\n%s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hosts.ini")); err != nil {
+		t.Errorf("Expected hosts.ini: %v", err)
+	}
+}
+
+func TestMergeVars(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		vars     map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "New file",
+			existing: "",
+			vars:     map[string]interface{}{"b": 2, "a": []string{"x"}},
+			expected: varsFileHeader + "\n\na: " + managedComment + "\n  - x\nb: 2 " + managedComment + "\n",
+		},
+		{
+			name:     "Stale managed variable removed",
+			existing: "a: 1 " + managedComment + "\nb: 2 " + managedComment + "\n",
+			vars:     map[string]interface{}{"b": 3},
+			expected: "b: 3 " + managedComment + "\n",
+		},
+		{
+			name:     "Unmarked variable kept",
+			existing: "a: 1 # mine\n",
+			vars:     map[string]interface{}{"a": 2},
+			expected: "a: 1 # mine\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeVars([]byte(tt.existing), tt.vars)
+			if err != nil {
+				t.Fatalf("mergeVars failed: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := mergeVars([]byte("- a\n"), nil); err == nil {
+		t.Error("Expected error for a non-mapping file")
+	}
+}
@@ -0,0 +1,170 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Host roles, named after the Kubespray groups they place a host in
+const (
+	RoleControlPlane = "kube_control_plane"
+	RoleNode         = "kube_node"
+	RoleEtcd         = "etcd"
+	RoleCalicoRR     = "calico_rr"
+)
+
+// Taint effects accepted by Kubernetes
+var taintEffects = map[string]bool{
+	"NoSchedule":       true,
+	"PreferNoSchedule": true,
+	"NoExecute":        true,
+}
+
+// Host is a single machine in an inventory
+type Host struct {
+	// Name is the inventory hostname; one is generated when empty
+	Name string
+	// Address is the address Ansible connects to (ansible_host)
+	Address string
+	// IP is the address Kubernetes services bind to; defaults to Address
+	IP string
+	// AccessIP is the address other nodes use to reach the host; defaults to IP
+	AccessIP string
+	// User overrides the SSH user (ansible_user)
+	User string
+	// Roles lists the Kubespray groups the host belongs to
+	Roles []string
+	// Vars are extra host variables
+	Vars map[string]interface{}
+	// Labels are applied to the Kubernetes node (node_labels)
+	Labels map[string]string
+	// Taints are applied to the Kubernetes node (node_taints), written as
+	// key[=value]:Effect
+	Taints []string
+}
+
+// HasRole reports whether the host has the given role
+func (h Host) HasRole(role string) bool {
+	for _, r := range h.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// hostVar is a single host variable
+type hostVar struct {
+	key   string
+	value interface{}
+}
+
+// vars returns the host variables in the order they are written: addresses
+// and user first, then extra vars by name, then node labels and taints
+func (h Host) vars() []hostVar {
+	vars := []hostVar{
+		{"ansible_host", h.Address},
+		{"ip", h.IP},
+		{"access_ip", h.AccessIP},
+	}
+	if h.User != "" {
+		vars = append(vars, hostVar{"ansible_user", h.User})
+	}
+
+	keys := make([]string, 0, len(h.Vars))
+	for k := range h.Vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vars = append(vars, hostVar{k, h.Vars[k]})
+	}
+
+	if len(h.Labels) > 0 {
+		labels := make(map[string]interface{}, len(h.Labels))
+		for k, v := range h.Labels {
+			labels[k] = v
+		}
+		vars = append(vars, hostVar{"node_labels", labels})
+	}
+	if len(h.Taints) > 0 {
+		vars = append(vars, hostVar{"node_taints", h.Taints})
+	}
+
+	return vars
+}
+
+// withDefaults fills in IP and AccessIP from the connection address
+func (h Host) withDefaults() Host {
+	if h.IP == "" {
+		h.IP = h.Address
+	}
+	if h.AccessIP == "" {
+		h.AccessIP = h.IP
+	}
+	return h
+}
+
+// validate checks a host after defaults have been applied
+func (h Host) validate() error {
+	if err := validateHostname(h.Name); err != nil {
+		return err
+	}
+	if h.Address == "" {
+		return fmt.Errorf("host %s: address is required", h.Name)
+	}
+	if net.ParseIP(h.IP) == nil {
+		return fmt.Errorf("host %s: invalid IP address: %s", h.Name, h.IP)
+	}
+	if net.ParseIP(h.AccessIP) == nil {
+		return fmt.Errorf("host %s: invalid access IP address: %s", h.Name, h.AccessIP)
+	}
+
+	if len(h.Roles) == 0 {
+		return fmt.Errorf("host %s: at least one role is required", h.Name)
+	}
+	for _, role := range h.Roles {
+		switch role {
+		case RoleControlPlane, RoleNode, RoleEtcd, RoleCalicoRR:
+		default:
+			return fmt.Errorf("host %s: unknown role %q", h.Name, role)
+		}
+	}
+
+	for _, key := range []string{"ansible_host", "ip", "access_ip", "ansible_user", "node_labels", "node_taints"} {
+		if _, ok := h.Vars[key]; ok {
+			return fmt.Errorf("host %s: %s must be set through its host field, not vars", h.Name, key)
+		}
+	}
+
+	for k := range h.Labels {
+		if k == "" {
+			return fmt.Errorf("host %s: label key is empty", h.Name)
+		}
+	}
+	for _, taint := range h.Taints {
+		if err := validateTaint(taint); err != nil {
+			return fmt.Errorf("host %s: %w", h.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateTaint checks a taint is written as key[=value]:Effect
+func validateTaint(taint string) error {
+	kv, effect, ok := strings.Cut(taint, ":")
+	if !ok {
+		return fmt.Errorf("taint %q must be key[=value]:Effect", taint)
+	}
+	key, _, _ := strings.Cut(kv, "=")
+	if key == "" {
+		return fmt.Errorf("taint %q has an empty key", taint)
+	}
+	if !taintEffects[effect] {
+		return fmt.Errorf("taint %q has unknown effect %q", taint, effect)
+	}
+	return nil
+}
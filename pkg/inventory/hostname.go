@@ -0,0 +1,93 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Hostname sources
+const (
+	HostnameTemplate = "template"
+	HostnameDNS      = "dns"
+	HostnameSSH      = "ssh"
+)
+
+// HostnameResolver looks up the hostname of a host from its address
+type HostnameResolver interface {
+	Hostname(address string) (string, error)
+}
+
+// DNSResolver resolves hostnames with reverse DNS lookups
+type DNSResolver struct{}
+
+// Hostname returns the first name the address resolves to
+func (DNSResolver) Hostname(address string) (string, error) {
+	names, err := net.LookupAddr(address)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no PTR record for %s", address)
+	}
+	return names[0], nil
+}
+
+// hostnamePattern matches a DNS-1123 subdomain, which Kubernetes requires
+// for node names
+var hostnamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// placeholderPattern matches a {{name}} template placeholder
+var placeholderPattern = regexp.MustCompile(`{{\s*([a-z]+)\s*}}`)
+
+// renderHostname expands a hostname template. index is the position of the
+// host in its role, counted from 0 for {{index}} and from 1 for {{number}}.
+func renderHostname(template, cluster string, index int, ip string) (string, error) {
+	var unknown string
+	name := placeholderPattern.ReplaceAllStringFunc(template, func(m string) string {
+		switch key := placeholderPattern.FindStringSubmatch(m)[1]; key {
+		case "cluster":
+			return cluster
+		case "index":
+			return strconv.Itoa(index)
+		case "number":
+			return strconv.Itoa(index + 1)
+		case "ip":
+			return strings.NewReplacer(".", "-", ":", "-").Replace(ip)
+		default:
+			unknown = key
+			return m
+		}
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("hostname template %q: unknown placeholder {{%s}}", template, unknown)
+	}
+	return name, validateHostname(name)
+}
+
+// normalizeHostname lowercases a resolved hostname and strips the trailing
+// dot of a fully qualified name
+func normalizeHostname(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// validateHostname checks a name is usable as a Kubernetes node name
+func validateHostname(name string) error {
+	if len(name) > 253 || !hostnamePattern.MatchString(name) {
+		return fmt.Errorf("invalid hostname %q: must be lowercase letters, digits, '-' and '.'", name)
+	}
+	return nil
+}
+
+// usesIndex reports whether a template varies with the host index, so a
+// taken name can be skipped by trying the next index
+func usesIndex(template string) bool {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if m[1] == "index" || m[1] == "number" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// fakeResolver resolves hostnames from a fixed table
+type fakeResolver map[string]string
+
+func (f fakeResolver) Hostname(address string) (string, error) {
+	name, ok := f[address]
+	if !ok {
+		return "", fmt.Errorf("no hostname for %s", address)
+	}
+	return name, nil
+}
+
+func TestRenderHostname(t *testing.T) {
+	tests := []struct {
+		template  string
+		expected  string
+		shouldErr bool
+	}{
+		{"{{cluster}}-cp-{{index}}", "prod-cp-2", false},
+		{"{{ cluster }}-cp-{{ number }}", "prod-cp-3", false},
+		{"node-{{ip}}", "node-10-0-0-5", false},
+		{"{{cluster}}.example.com", "prod.example.com", false},
+		{"{{rack}}-{{index}}", "", true},
+		{"Node_{{index}}", "", true},
+		{"-{{index}}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			name, err := renderHostname(tt.template, "prod", 2, "10.0.0.5")
+			if tt.shouldErr {
+				if err == nil {
+					t.Errorf("Expected error, got %s", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestGenerateHostnameTemplates(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Inventory.ClusterName = "prod"
+	cfg.Inventory.Hostnames.ControlPlane = "{{cluster}}-cp-{{number}}"
+	cfg.Inventory.Hostnames.Node = "{{cluster}}-worker-{{number}}"
+	gen := NewGenerator(cfg)
+
+	inv := &Inventory{
+		Masters: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		Nodes:   []string{"10.0.0.11", "10.0.0.12"},
+		Hosts:   []Host{{Address: "10.0.0.13", Roles: []string{RoleNode}}},
+	}
+
+	hosts, err := gen.hosts(inv)
+	if err != nil {
+		t.Fatalf("hosts failed: %v", err)
+	}
+
+	expected := []string{"prod-cp-1", "prod-cp-2", "prod-cp-3", "prod-worker-1", "prod-worker-2", "prod-worker-3"}
+	if names := hostNames(hosts); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	// Regenerating from the same input gives the same file
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.ini"), filepath.Join(dir, "second.ini")
+	if err := gen.Generate(inv, first); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if err := gen.Generate(inv, second); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !sameFile(t, first, second) {
+		t.Error("Expected regenerated inventory to be identical")
+	}
+}
+
+func TestGenerateHostnameUniqueness(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Inventory.Hostnames.Node = "worker"
+	gen := NewGenerator(cfg)
+
+	inv := &Inventory{
+		Masters: []string{"10.0.0.1"},
+		Nodes:   []string{"10.0.0.11", "10.0.0.12"},
+	}
+	if _, err := gen.hosts(inv); err == nil {
+		t.Error("Expected error for duplicate generated hostnames")
+	}
+}
+
+func TestGenerateResolvedHostnames(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Inventory.Hostnames.Source = HostnameDNS
+	resolver := fakeResolver{
+		"10.0.0.1":  "CP1.example.com.",
+		"10.0.0.11": "worker1.example.com.",
+	}
+	gen := NewGenerator(cfg).WithResolver(resolver)
+
+	inv := &Inventory{
+		Masters: []string{"10.0.0.1"},
+		Nodes:   []string{"10.0.0.11"},
+	}
+	hosts, err := gen.hosts(inv)
+	if err != nil {
+		t.Fatalf("hosts failed: %v", err)
+	}
+	expected := []string{"cp1.example.com", "worker1.example.com"}
+	if names := hostNames(hosts); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	inv.Nodes = append(inv.Nodes, "10.0.0.12")
+	if _, err := gen.hosts(inv); err == nil {
+		t.Error("Expected error for unresolvable host")
+	}
+
+	resolver["10.0.0.12"] = "worker1.example.com"
+	if _, err := gen.hosts(inv); err == nil {
+		t.Error("Expected error for hosts resolving to the same name")
+	}
+
+	cfg.Inventory.Hostnames.Source = HostnameSSH
+	if _, err := NewGenerator(cfg).hosts(inv); err == nil {
+		t.Error("Expected error for ssh source without a resolver")
+	}
+}
+
+func sameFile(t *testing.T, a, b string) bool {
+	t.Helper()
+	dataA, errA := os.ReadFile(a)
+	dataB, errB := os.ReadFile(b)
+	if errA != nil || errB != nil {
+		t.Fatalf("Failed to read inventories: %v %v", errA, errB)
+	}
+	return string(dataA) == string(dataB)
+}
@@ -0,0 +1,480 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Import sources
+const (
+	SourceCSV       = "csv"
+	SourceTerraform = "terraform"
+	SourceKubespray = "kubespray"
+)
+
+// DetectSource guesses the import source of a path: a directory is a
+// Kubespray inventory, a .csv file a CSV export and anything else Terraform
+// JSON
+func DetectSource(p string) string {
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return SourceKubespray
+	}
+	if strings.EqualFold(filepath.Ext(p), ".csv") {
+		return SourceCSV
+	}
+	return SourceTerraform
+}
+
+// Import builds an inventory from the file or directory at p using the given
+// source
+func Import(source, p string, rules []RoleRule) (*Inventory, error) {
+	switch source {
+	case SourceKubespray:
+		return ImportKubespray(p, rules)
+	case SourceCSV:
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", p, err)
+		}
+		defer f.Close()
+		return ImportCSV(f, rules)
+	case SourceTerraform:
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		return ImportTerraform(data, rules)
+	default:
+		return nil, fmt.Errorf("unknown import source %q (want csv, terraform or kubespray)", source)
+	}
+}
+
+// RoleRule assigns roles to imported hosts. A host matches when one of the
+// values of Field matches Pattern, a case-insensitive shell glob. The first
+// matching rule wins; a rule without roles leaves matching hosts out.
+type RoleRule struct {
+	Field   string
+	Pattern string
+	Roles   []string
+}
+
+// ParseRoleRule parses a rule written as field=pattern:role1,role2
+func ParseRoleRule(s string) (RoleRule, error) {
+	field, rest, ok := strings.Cut(s, "=")
+	if !ok || field == "" {
+		return RoleRule{}, fmt.Errorf("invalid role rule %q: expected field=pattern:roles", s)
+	}
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return RoleRule{}, fmt.Errorf("invalid role rule %q: expected field=pattern:roles", s)
+	}
+
+	rule := RoleRule{Field: strings.ToLower(field), Pattern: rest[:i]}
+	if _, err := path.Match(rule.Pattern, ""); err != nil {
+		return RoleRule{}, fmt.Errorf("invalid role rule %q: %w", s, err)
+	}
+	if roles := rest[i+1:]; roles != "" {
+		parsed, err := parseRoles(roles)
+		if err != nil {
+			return RoleRule{}, fmt.Errorf("invalid role rule %q: %w", s, err)
+		}
+		rule.Roles = parsed
+	}
+	return rule, nil
+}
+
+// roleAliases maps the role names found in CMDB exports, tags and output
+// names to Kubespray roles
+var roleAliases = map[string]string{
+	"kube_control_plane": RoleControlPlane,
+	"kube-master":        RoleControlPlane,
+	"control-plane":      RoleControlPlane,
+	"control_plane":      RoleControlPlane,
+	"controlplane":       RoleControlPlane,
+	"master":             RoleControlPlane,
+	"masters":            RoleControlPlane,
+	"cp":                 RoleControlPlane,
+	"kube_node":          RoleNode,
+	"kube-node":          RoleNode,
+	"node":               RoleNode,
+	"nodes":              RoleNode,
+	"worker":             RoleNode,
+	"workers":            RoleNode,
+	"etcd":               RoleEtcd,
+	"calico_rr":          RoleCalicoRR,
+	"calico-rr":          RoleCalicoRR,
+}
+
+// parseRoles parses a list of role names separated by commas, semicolons,
+// pipes or spaces
+func parseRoles(s string) ([]string, error) {
+	roles := []string{}
+	for _, name := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == '|' || r == ' '
+	}) {
+		role, ok := roleAliases[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q", name)
+		}
+		roles = appendUnique(roles, role)
+	}
+	return roles, nil
+}
+
+// record is a host read from an import source with the fields rules match on
+type record struct {
+	host   Host
+	fields map[string][]string
+	// roles are the roles named by the source itself, if any
+	roles []string
+}
+
+// assignRoles applies rules to records and returns the hosts that have a
+// role. A record matched by no rule keeps the roles named by its source.
+func assignRoles(records []record, rules []RoleRule) ([]Host, error) {
+	hosts := []Host{}
+	for _, rec := range records {
+		roles, matched := matchRules(rec.fields, rules)
+		if !matched {
+			roles = rec.roles
+		} else if len(roles) == 0 {
+			continue
+		}
+
+		if len(roles) == 0 {
+			id := rec.host.Name
+			if id == "" {
+				id = rec.host.Address
+			}
+			return nil, fmt.Errorf("host %s has no role; add a role rule for it", id)
+		}
+
+		h := rec.host
+		h.Roles = append([]string{}, roles...)
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+// matchRules returns the roles of the first rule matching fields
+func matchRules(fields map[string][]string, rules []RoleRule) ([]string, bool) {
+	for _, rule := range rules {
+		pattern := strings.ToLower(rule.Pattern)
+		for _, value := range fields[rule.Field] {
+			if ok, _ := path.Match(pattern, strings.ToLower(value)); ok {
+				return rule.Roles, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// csvColumns maps CSV header names to host fields
+var csvColumns = map[string]string{
+	"name":         "name",
+	"hostname":     "name",
+	"host":         "name",
+	"fqdn":         "name",
+	"address":      "address",
+	"ansible_host": "address",
+	"ip_address":   "address",
+	"public_ip":    "address",
+	"ip":           "ip",
+	"private_ip":   "ip",
+	"internal_ip":  "ip",
+	"access_ip":    "access_ip",
+	"user":         "user",
+	"ssh_user":     "user",
+	"ansible_user": "user",
+	"role":         "roles",
+	"roles":        "roles",
+	"group":        "roles",
+	"groups":       "roles",
+	"labels":       "labels",
+	"taints":       "taints",
+}
+
+// ImportCSV builds an inventory from a CSV export with a header row. Known
+// columns are name, address, ip, access_ip, user, role, labels (k=v;k=v) and
+// taints (;-separated), with common aliases such as hostname or private_ip;
+// when only ip is present it is the connection address. Every column,
+// known or not, can be matched by role rules under its lowercased header
+// with spaces and dashes written as underscores.
+func ImportCSV(r io.Reader, rules []RoleRule) (*Inventory, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := make([]string, len(header))
+	hasAddress := false
+	for i, name := range header {
+		columns[i] = strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if csvColumns[columns[i]] == "address" {
+			hasAddress = true
+		}
+	}
+
+	records := []record{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		rec := record{fields: map[string][]string{}}
+		for i, value := range row {
+			if i >= len(columns) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			rec.fields[columns[i]] = []string{value}
+			if value == "" {
+				continue
+			}
+
+			field := csvColumns[columns[i]]
+			if field == "ip" && !hasAddress {
+				field = "address"
+			}
+			if err := setCSVField(&rec, field, value); err != nil {
+				return nil, fmt.Errorf("CSV line %d: %w", line, err)
+			}
+		}
+
+		if rec.host.Address == "" {
+			return nil, fmt.Errorf("CSV line %d: no address", line)
+		}
+		rec.fields["name"] = []string{rec.host.Name}
+		rec.fields["address"] = []string{rec.host.Address}
+		records = append(records, rec)
+	}
+
+	hosts, err := assignRoles(records, rules)
+	if err != nil {
+		return nil, err
+	}
+	return &Inventory{Hosts: hosts}, nil
+}
+
+// setCSVField stores a CSV value in the host field it maps to
+func setCSVField(rec *record, field, value string) error {
+	switch field {
+	case "name":
+		rec.host.Name = normalizeHostname(value)
+	case "address":
+		rec.host.Address = value
+	case "ip":
+		rec.host.IP = value
+	case "access_ip":
+		rec.host.AccessIP = value
+	case "user":
+		rec.host.User = value
+	case "roles":
+		roles, err := parseRoles(value)
+		if err != nil {
+			return err
+		}
+		rec.roles = roles
+	case "labels":
+		rec.host.Labels = map[string]string{}
+		for _, label := range strings.Split(value, ";") {
+			key, val, _ := strings.Cut(strings.TrimSpace(label), "=")
+			if key == "" {
+				return fmt.Errorf("invalid label %q", label)
+			}
+			rec.host.Labels[key] = val
+		}
+	case "taints":
+		for _, taint := range strings.Split(value, ";") {
+			rec.host.Taints = append(rec.host.Taints, strings.TrimSpace(taint))
+		}
+	}
+	return nil
+}
+
+// inventoryFiles are the inventory file names looked for in a Kubespray
+// inventory directory, in order
+var inventoryFiles = []string{"hosts.yaml", "hosts.yml", "inventory.ini", "hosts.ini"}
+
+// ImportKubespray builds an inventory from a Kubespray inventory directory:
+// its hosts file, group_vars and host_vars. Group memberships become roles
+// unless a role rule matches the host name, address or one of its groups.
+func ImportKubespray(dir string, rules []RoleRule) (*Inventory, error) {
+	var parsed *AnsibleInventory
+	for _, name := range inventoryFiles {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		inv, err := ParseFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		parsed = inv
+		break
+	}
+	if parsed == nil {
+		return nil, fmt.Errorf("no inventory file (%s) in %s", strings.Join(inventoryFiles, ", "), dir)
+	}
+
+	// Inventory() leaves out hosts outside the Kubespray groups; they are
+	// added back below so rules can still give them a role
+	converted := parsed.Inventory()
+	byName := map[string]Host{}
+	for _, h := range converted.Hosts {
+		byName[h.Name] = h
+	}
+
+	records := []record{}
+	for _, name := range parsed.HostOrder {
+		h, ok := byName[name]
+		if !ok {
+			h = Host{Name: name, Address: parsed.Address(name)}
+		}
+
+		groups := []string{}
+		for _, grp := range parsed.GroupOrder {
+			for _, member := range parsed.Members(grp) {
+				if member == name {
+					groups = append(groups, grp)
+				}
+			}
+		}
+
+		roles := h.Roles
+		h.Roles = nil
+		records = append(records, record{
+			host:  h,
+			roles: roles,
+			fields: map[string][]string{
+				"name":    {name},
+				"address": {h.Address},
+				"group":   groups,
+			},
+		})
+	}
+
+	hosts, err := assignRoles(records, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	groupVars, err := readVarsDir(filepath.Join(dir, "group_vars"))
+	if err != nil {
+		return nil, err
+	}
+	for name, vars := range converted.GroupVars {
+		if groupVars[name] == nil {
+			groupVars[name] = map[string]interface{}{}
+		}
+		for k, v := range vars {
+			groupVars[name][k] = v
+		}
+	}
+
+	hostVars, err := readVarsDir(filepath.Join(dir, "host_vars"))
+	if err != nil {
+		return nil, err
+	}
+	for i := range hosts {
+		for k, v := range hostVars[hosts[i].Name] {
+			if hosts[i].Vars == nil {
+				hosts[i].Vars = map[string]interface{}{}
+			}
+			hosts[i].Vars[k] = v
+		}
+	}
+
+	inv := &Inventory{Hosts: hosts}
+	if len(groupVars) > 0 {
+		inv.GroupVars = groupVars
+	}
+	return inv, nil
+}
+
+// readVarsDir reads an Ansible group_vars or host_vars directory. Each entry
+// is a YAML file named after the group or host, or a directory of YAML files
+// merged in name order.
+func readVarsDir(dir string) (map[string]map[string]interface{}, error) {
+	result := map[string]map[string]interface{}{}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		p := filepath.Join(dir, name)
+
+		files := []string{}
+		if entry.IsDir() {
+			sub, err := os.ReadDir(p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", p, err)
+			}
+			for _, f := range sub {
+				if !f.IsDir() && isYAMLFile(f.Name()) {
+					files = append(files, filepath.Join(p, f.Name()))
+				}
+			}
+			sort.Strings(files)
+		} else if isYAMLFile(name) {
+			files = append(files, p)
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		} else {
+			continue
+		}
+
+		for _, f := range files {
+			vars, err := readVarsFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if result[name] == nil {
+				result[name] = map[string]interface{}{}
+			}
+			for k, v := range vars {
+				result[name][k] = v
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// readVarsFile reads a YAML file of variables
+func readVarsFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	vars := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("invalid YAML in %s: %w", path, err)
+	}
+	return vars, nil
+}
+
+func isYAMLFile(name string) bool {
+	return DetectFormat(name) == FormatYAML
+}
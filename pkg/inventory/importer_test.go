@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRoleRule(t *testing.T) {
+	tests := []struct {
+		rule      string
+		expected  RoleRule
+		shouldErr bool
+	}{
+		{"name=*-cp-*:control-plane,etcd", RoleRule{Field: "name", Pattern: "*-cp-*", Roles: []string{RoleControlPlane, RoleEtcd}}, false},
+		{"Tag.Pool=gpu:worker", RoleRule{Field: "tag.pool", Pattern: "gpu", Roles: []string{RoleNode}}, false},
+		{"output=bastion:", RoleRule{Field: "output", Pattern: "bastion"}, false},
+		{"name=*", RoleRule{}, true},
+		{"*:worker", RoleRule{}, true},
+		{"name=[:worker", RoleRule{}, true},
+		{"name=*:database", RoleRule{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParseRoleRule(tt.rule)
+			if tt.shouldErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rule, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, rule)
+			}
+		})
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	data := `Hostname,IP Address,Private_IP,Role,Labels,Taints,Rack,Owner
+cp1.example.com,203.0.113.1,10.0.0.1,master;etcd,,,r1,platform
+worker1.example.com,203.0.113.11,10.0.0.11,worker,zone=a;disk=ssd,,r2,platform
+gpu1.example.com,203.0.113.21,10.0.0.21,,,nvidia.com/gpu=present:NoSchedule,r3,ml
+LB1.example.com,203.0.113.31,,,,,r1,network
+`
+	rules := []RoleRule{
+		{Field: "owner", Pattern: "network"},
+		{Field: "owner", Pattern: "ml", Roles: []string{RoleNode}},
+	}
+	inv, err := ImportCSV(strings.NewReader(data), rules)
+	if err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+
+	expected := []Host{
+		{Name: "cp1.example.com", Address: "203.0.113.1", IP: "10.0.0.1", Roles: []string{RoleControlPlane, RoleEtcd}},
+		{Name: "worker1.example.com", Address: "203.0.113.11", IP: "10.0.0.11", Roles: []string{RoleNode}, Labels: map[string]string{"zone": "a", "disk": "ssd"}},
+		{Name: "gpu1.example.com", Address: "203.0.113.21", IP: "10.0.0.21", Roles: []string{RoleNode}, Taints: []string{"nvidia.com/gpu=present:NoSchedule"}},
+	}
+	if !reflect.DeepEqual(inv.Hosts, expected) {
+		t.Errorf("Unexpected hosts:\n got %+v\nwant %+v", inv.Hosts, expected)
+	}
+}
+
+func TestImportCSVIPOnly(t *testing.T) {
+	inv, err := ImportCSV(strings.NewReader("name,ip,role\nnode1,10.0.0.5,node\n"), nil)
+	if err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+	if len(inv.Hosts) != 1 || inv.Hosts[0].Address != "10.0.0.5" || inv.Hosts[0].IP != "" {
+		t.Errorf("Expected ip column to be the address, got %+v", inv.Hosts)
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"Empty":        "",
+		"No address":   "name,role\nnode1,worker\n",
+		"Unknown role": "name,ip,role\nnode1,10.0.0.5,database\n",
+		"No role":      "name,ip\nnode1,10.0.0.5\n",
+		"Bad label":    "name,ip,role,labels\nnode1,10.0.0.5,worker,=a\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ImportCSV(strings.NewReader(data), nil); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestImportKubespray(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"hosts.yaml": `all:
+  hosts:
+    node1:
+      ansible_host: 10.0.0.1
+    node2:
+      ansible_host: 10.0.0.2
+    bastion:
+      ansible_host: 203.0.113.1
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    etcd:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node2:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+    bastion:
+      hosts:
+        bastion:
+`,
+		"group_vars/all/all.yml":                 "upstream_dns_servers:\n  - 8.8.8.8\n",
+		"group_vars/all/containerd.yml":          "containerd_version: 1.7.13\n",
+		"group_vars/k8s_cluster/k8s-cluster.yml": "kube_version: v1.29.0\nkube_network_plugin: cilium\n",
+		"group_vars/etcd.yml":                    "etcd_deployment_type: host\n",
+		"group_vars/README":                      "not vars\n",
+		"host_vars/node2.yml":                    "kubelet_max_pods: 50\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := ImportKubespray(dir, nil); err == nil {
+		t.Error("Expected error for bastion without a role")
+	}
+
+	inv, err := ImportKubespray(dir, []RoleRule{{Field: "group", Pattern: "bastion"}})
+	if err != nil {
+		t.Fatalf("ImportKubespray failed: %v", err)
+	}
+
+	expected := []Host{
+		{Name: "node1", Address: "10.0.0.1", Roles: []string{RoleControlPlane, RoleEtcd}},
+		{Name: "node2", Address: "10.0.0.2", Roles: []string{RoleNode}, Vars: map[string]interface{}{"kubelet_max_pods": 50}},
+	}
+	if !reflect.DeepEqual(inv.Hosts, expected) {
+		t.Errorf("Unexpected hosts:\n got %+v\nwant %+v", inv.Hosts, expected)
+	}
+
+	expectedVars := map[string]map[string]interface{}{
+		"all":         {"upstream_dns_servers": []interface{}{"8.8.8.8"}, "containerd_version": "1.7.13"},
+		"k8s_cluster": {"kube_version": "v1.29.0", "kube_network_plugin": "cilium"},
+		"etcd":        {"etcd_deployment_type": "host"},
+	}
+	if !reflect.DeepEqual(inv.GroupVars, expectedVars) {
+		t.Errorf("Unexpected group vars:\n got %v\nwant %v", inv.GroupVars, expectedVars)
+	}
+
+	if _, err := ImportKubespray(t.TempDir(), nil); err == nil {
+		t.Error("Expected error for directory without an inventory file")
+	}
+}
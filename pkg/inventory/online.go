@@ -0,0 +1,294 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HostProber reads facts from a live host. Implementations must be safe for
+// concurrent use; preflight.Checker probes hosts over SSH.
+type HostProber interface {
+	// Probe connects to a host and returns the hostname it reports and the
+	// IP addresses configured on its interfaces. Failures are *ProbeError.
+	Probe(conn Connection) (HostFacts, error)
+}
+
+// Connection is how Ansible connects to a host. User, Port and KeyPath are
+// only set when the inventory sets them; the prober's defaults apply
+// otherwise.
+type Connection struct {
+	Address string
+	User    string
+	Port    int
+	KeyPath string
+}
+
+// String formats the connection as [user@]address[:port]
+func (c Connection) String() string {
+	s := c.Address
+	if c.Port > 0 {
+		s = net.JoinHostPort(c.Address, strconv.Itoa(c.Port))
+	}
+	if c.User != "" {
+		s = c.User + "@" + s
+	}
+	return s
+}
+
+// HostFacts are what probing a live host returned
+type HostFacts struct {
+	Hostname  string
+	Addresses []string
+}
+
+// Stages a probe fails at
+const (
+	// ProbeConnect is a failure to open a connection to the host
+	ProbeConnect = "connect"
+	// ProbeLogin is a failure to log in once connected
+	ProbeLogin = "login"
+	// ProbeCommand is a failure to read the facts once logged in
+	ProbeCommand = "command"
+)
+
+// ProbeError is a failed probe and the stage it failed at
+type ProbeError struct {
+	Stage string
+	Err   error
+}
+
+func (e *ProbeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+// liveHost is what probing an inventory host returned
+type liveHost struct {
+	conn     Connection
+	hostname string
+	addrs    []string
+	err      error
+}
+
+// stage returns the stage the probe failed at, ProbeConnect for errors that
+// do not say
+func (h liveHost) stage() string {
+	var perr *ProbeError
+	if errors.As(h.err, &perr) {
+		return perr.Stage
+	}
+	return ProbeConnect
+}
+
+// WithProber sets the prober ValidateOnline uses to reach the hosts
+func (v *Validator) WithProber(p HostProber) *Validator {
+	v.prober = p
+	return v
+}
+
+// ValidateOnline connects to every host of a parsed inventory and runs the
+// enabled online rules: the connection address accepts SSH, the SSH user can
+// log in and read the host's facts, the declared ip is configured on one of
+// the host's interfaces and the host's own hostname matches its inventory
+// name. Hosts are reached with the ansible_user, ansible_port and
+// ansible_ssh_private_key_file the inventory sets for them. Hosts are probed
+// concurrently; findings are reported per host in inventory order.
+func (v *Validator) ValidateOnline(inv *AnsibleInventory) ([]Finding, error) {
+	if v.prober == nil {
+		return nil, fmt.Errorf("online validation needs a host prober")
+	}
+
+	live := make(map[string]liveHost, len(inv.HostOrder))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, name := range inv.HostOrder {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			h := v.probe(inv.Connection(name))
+			mu.Lock()
+			live[name] = h
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+
+	findings := []Finding{}
+	for _, r := range rules {
+		if v.disabled[r.ID] || !r.Online {
+			continue
+		}
+		findings = append(findings, r.probe(inv, live)...)
+	}
+
+	// Group the findings by host so each host's problems read together
+	byHost := make([]Finding, 0, len(findings))
+	for _, name := range inv.HostOrder {
+		for _, f := range findings {
+			if f.Host == name {
+				byHost = append(byHost, f)
+			}
+		}
+	}
+	return byHost, nil
+}
+
+// probe gathers the hostname and addresses of a host
+func (v *Validator) probe(conn Connection) liveHost {
+	facts, err := v.prober.Probe(conn)
+	return liveHost{conn: conn, hostname: facts.Hostname, addrs: facts.Addresses, err: err}
+}
+
+// Connection returns how Ansible connects to a host: its Address and the
+// ansible_user, ansible_port and ansible_ssh_private_key_file set for it
+func (inv *AnsibleInventory) Connection(name string) Connection {
+	conn := Connection{Address: inv.Address(name)}
+	if v, ok := inv.hostVar(name, "ansible_user"); ok {
+		conn.User = fmt.Sprint(v)
+	}
+	if v, ok := inv.hostVar(name, "ansible_port"); ok {
+		conn.Port, _ = strconv.Atoi(fmt.Sprint(v))
+	}
+	if v, ok := inv.hostVar(name, "ansible_ssh_private_key_file"); ok {
+		conn.KeyPath = fmt.Sprint(v)
+	}
+	return conn
+}
+
+// hostVar returns a variable of a host: its own, else that of the first
+// group in inventory order the host belongs to, else that of all
+func (inv *AnsibleInventory) hostVar(name, key string) (interface{}, bool) {
+	if v, ok := inv.Hosts[name][key]; ok {
+		return v, true
+	}
+	for _, group := range inv.GroupOrder {
+		if group == "all" {
+			continue
+		}
+		if v, ok := inv.Groups[group].Vars[key]; ok && slices.Contains(inv.Members(group), name) {
+			return v, true
+		}
+	}
+	if all, ok := inv.Groups["all"]; ok {
+		v, ok := all.Vars[key]
+		return v, ok
+	}
+	return nil, false
+}
+
+func checkReachable(inv *AnsibleInventory, live map[string]liveHost) []Finding {
+	findings := []Finding{}
+	for _, name := range inv.HostOrder {
+		h := live[name]
+		if h.err == nil || h.stage() != ProbeConnect {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:     FindingUnreachable,
+			Severity: SeverityError,
+			Host:     name,
+			Message:  fmt.Sprintf("host %s is not reachable at %s: %v", name, h.conn, h.err),
+		})
+	}
+	return findings
+}
+
+func checkLogin(inv *AnsibleInventory, live map[string]liveHost) []Finding {
+	findings := []Finding{}
+	for _, name := range inv.HostOrder {
+		h := live[name]
+		if h.err == nil || h.stage() != ProbeLogin {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:     FindingLoginFailed,
+			Severity: SeverityError,
+			Host:     name,
+			Message:  fmt.Sprintf("host %s accepts connections at %s but SSH login failed: %v", name, h.conn, h.err),
+		})
+	}
+	return findings
+}
+
+func checkProbe(inv *AnsibleInventory, live map[string]liveHost) []Finding {
+	findings := []Finding{}
+	for _, name := range inv.HostOrder {
+		h := live[name]
+		if h.err == nil || h.stage() != ProbeCommand {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:     FindingProbeFailed,
+			Severity: SeverityError,
+			Host:     name,
+			Message:  fmt.Sprintf("cannot read the hostname and addresses of host %s: %v", name, h.err),
+		})
+	}
+	return findings
+}
+
+func checkIPConfigured(inv *AnsibleInventory, live map[string]liveHost) []Finding {
+	findings := []Finding{}
+	for _, name := range inv.HostOrder {
+		h := live[name]
+		v, ok := inv.Hosts[name]["ip"]
+		if h.err != nil || !ok || fmt.Sprint(v) == "" {
+			continue
+		}
+		ip := fmt.Sprint(v)
+		if containsAddress(h.addrs, ip) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:     FindingIPNotConfigured,
+			Severity: SeverityError,
+			Host:     name,
+			Message:  fmt.Sprintf("host %s declares ip %s but it is not configured on any interface (found %s)", name, ip, strings.Join(h.addrs, ", ")),
+		})
+	}
+	return findings
+}
+
+// checkHostname reports hosts whose hostname differs from their inventory
+// name. Kubespray renames hosts to their inventory name unless
+// override_system_hostname is false, so a mismatch is only a warning.
+func checkHostname(inv *AnsibleInventory, live map[string]liveHost) []Finding {
+	findings := []Finding{}
+	for _, name := range inv.HostOrder {
+		h := live[name]
+		if h.err != nil || sameHostname(name, h.hostname) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Code:     FindingHostnameMismatch,
+			Severity: SeverityWarning,
+			Host:     name,
+			Message:  fmt.Sprintf("host %s reports hostname %s; Kubespray will rename it unless override_system_hostname is false", name, h.hostname),
+		})
+	}
+	return findings
+}
+
+// sameHostname compares hostnames case-insensitively, treating a short name
+// as matching the fully qualified name it starts
+func sameHostname(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return true
+	}
+	short := func(s string) string {
+		host, _, _ := strings.Cut(s, ".")
+		return host
+	}
+	return (!strings.Contains(a, ".") || !strings.Contains(b, ".")) && short(a) == short(b)
+}
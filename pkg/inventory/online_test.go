@@ -0,0 +1,191 @@
+package inventory
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// fakeHost is what a fakeProber reports for a connection. A probe of a
+// host with a stage fails at that stage.
+type fakeHost struct {
+	hostname string
+	addrs    []string
+	stage    string
+}
+
+// fakeProber answers for a fixed table of connections, keyed by
+// Connection.String; other connections are unreachable
+type fakeProber map[string]fakeHost
+
+func (f fakeProber) Probe(conn Connection) (HostFacts, error) {
+	h, ok := f[conn.String()]
+	if !ok {
+		return HostFacts{}, &ProbeError{Stage: ProbeConnect, Err: fmt.Errorf("connection refused")}
+	}
+	if h.stage != "" {
+		return HostFacts{}, &ProbeError{Stage: h.stage, Err: fmt.Errorf("%s failed", h.stage)}
+	}
+	return HostFacts{Hostname: h.hostname, Addresses: h.addrs}, nil
+}
+
+const onlineINI = `[all]
+node1 ansible_host=203.0.113.1 ip=10.0.0.1
+node2 ansible_host=203.0.113.2 ip=10.0.0.2
+node3 ansible_host=203.0.113.3 ip=10.0.0.3
+node4 ansible_host=203.0.113.4 ansible_port=2200
+node5 ansible_host=203.0.113.5 ansible_user=root
+node6 ansible_host=203.0.113.6
+
+[kube_control_plane]
+node1
+
+[etcd]
+node1
+
+[kube_node]
+node2
+node3
+node4
+node5
+node6
+
+[kube_node:vars]
+ansible_user=admin
+
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+`
+
+func TestValidateOnline(t *testing.T) {
+	inv, err := ParseINI([]byte(onlineINI))
+	if err != nil {
+		t.Fatalf("ParseINI failed: %v", err)
+	}
+
+	prober := fakeProber{
+		"203.0.113.1":            {hostname: "node1.example.com", addrs: []string{"127.0.0.1", "203.0.113.1", "10.0.0.1"}},
+		"admin@203.0.113.2":      {hostname: "ubuntu", addrs: []string{"127.0.0.1", "10.0.0.20"}},
+		"admin@203.0.113.4:2200": {hostname: "NODE4", addrs: []string{"203.0.113.4"}},
+		"root@203.0.113.5":       {stage: ProbeLogin},
+		"admin@203.0.113.6":      {stage: ProbeCommand},
+	}
+
+	tests := []struct {
+		name     string
+		disable  []string
+		expected []string
+	}{
+		{
+			name:     "All rules",
+			expected: []string{"node2/" + FindingIPNotConfigured, "node2/" + FindingHostnameMismatch, "node3/" + FindingUnreachable, "node5/" + FindingLoginFailed, "node6/" + FindingProbeFailed},
+		},
+		{
+			name:     "Hostname rule disabled",
+			disable:  []string{FindingHostnameMismatch},
+			expected: []string{"node2/" + FindingIPNotConfigured, "node3/" + FindingUnreachable, "node5/" + FindingLoginFailed, "node6/" + FindingProbeFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewValidator(config.NewConfig()).WithProber(prober)
+			if err := validator.Disable(tt.disable...); err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := validator.ValidateOnline(inv)
+			if err != nil {
+				t.Fatalf("ValidateOnline failed: %v", err)
+			}
+
+			got := []string{}
+			for _, f := range findings {
+				got = append(got, f.Host+"/"+f.Code)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateOnlineSeverity(t *testing.T) {
+	inv, err := ParseINI([]byte(onlineINI))
+	if err != nil {
+		t.Fatalf("ParseINI failed: %v", err)
+	}
+
+	prober := fakeProber{}
+	for i, name := range inv.HostOrder {
+		conn := inv.Connection(name)
+		prober[conn.String()] = fakeHost{hostname: "renamed", addrs: []string{conn.Address, fmt.Sprintf("10.0.0.%d", i+1)}}
+	}
+
+	findings, err := NewValidator(config.NewConfig()).WithProber(prober).ValidateOnline(inv)
+	if err != nil {
+		t.Fatalf("ValidateOnline failed: %v", err)
+	}
+	if len(findings) != 6 || HasErrors(findings) {
+		t.Errorf("Expected six hostname warnings, got %v", findings)
+	}
+
+	if _, err := NewValidator(config.NewConfig()).ValidateOnline(inv); err == nil {
+		t.Error("Expected error without a prober")
+	}
+}
+
+func TestValidateInventorySkipsOnlineRules(t *testing.T) {
+	inv, err := ParseINI([]byte(onlineINI))
+	if err != nil {
+		t.Fatalf("ParseINI failed: %v", err)
+	}
+	if findings := NewValidator(config.NewConfig()).ValidateInventory(inv); len(findings) != 0 {
+		t.Errorf("Expected no offline findings, got %v", findings)
+	}
+}
+
+func TestConnection(t *testing.T) {
+	inv, err := ParseINI([]byte(onlineINI))
+	if err != nil {
+		t.Fatalf("ParseINI failed: %v", err)
+	}
+
+	tests := []struct {
+		host     string
+		expected Connection
+	}{
+		{"node1", Connection{Address: "203.0.113.1"}},
+		{"node4", Connection{Address: "203.0.113.4", User: "admin", Port: 2200}},
+		{"node5", Connection{Address: "203.0.113.5", User: "root"}},
+	}
+
+	for _, tt := range tests {
+		if got := inv.Connection(tt.host); got != tt.expected {
+			t.Errorf("Connection(%s) = %+v, expected %+v", tt.host, got, tt.expected)
+		}
+	}
+}
+
+func TestSameHostname(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"node1", "node1", true},
+		{"node1", "NODE1", true},
+		{"node1", "node1.example.com", true},
+		{"node1.example.com", "node1", true},
+		{"node1.example.com", "node1.example.org", false},
+		{"node1", "node10", false},
+	}
+
+	for _, tt := range tests {
+		if got := sameHostname(tt.a, tt.b); got != tt.expected {
+			t.Errorf("sameHostname(%q, %q) = %v, expected %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
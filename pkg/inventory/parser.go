@@ -0,0 +1,444 @@
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AnsibleInventory is a parsed Ansible inventory file
+type AnsibleInventory struct {
+	// Hosts maps host names to their variables
+	Hosts map[string]map[string]interface{}
+	// HostOrder lists host names in order of first appearance
+	HostOrder []string
+	// Groups maps group names to their definitions
+	Groups map[string]*Group
+	// GroupOrder lists group names in order of first appearance
+	GroupOrder []string
+}
+
+// Group is an Ansible inventory group
+type Group struct {
+	Name     string
+	Hosts    []string
+	Children []string
+	Vars     map[string]interface{}
+}
+
+// NewAnsibleInventory creates an empty inventory
+func NewAnsibleInventory() *AnsibleInventory {
+	return &AnsibleInventory{
+		Hosts:  make(map[string]map[string]interface{}),
+		Groups: make(map[string]*Group),
+	}
+}
+
+// ParseFile parses an INI or YAML inventory, choosing the format from the
+// file extension
+func ParseFile(path string) (*AnsibleInventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory: %w", err)
+	}
+
+	return Parse(data, DetectFormat(path))
+}
+
+// Parse parses inventory contents in the given format
+func Parse(data []byte, format string) (*AnsibleInventory, error) {
+	switch format {
+	case FormatINI:
+		return ParseINI(data)
+	case FormatYAML:
+		return ParseYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported inventory format %q", format)
+	}
+}
+
+// ParseINI parses an INI inventory
+func ParseINI(data []byte) (*AnsibleInventory, error) {
+	inv := NewAnsibleInventory()
+
+	section, kind := "ungrouped", ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip comments and empty lines
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header %q", lineNo, line)
+			}
+			section, kind, _ = strings.Cut(line[1:len(line)-1], ":")
+			if kind != "" && kind != "vars" && kind != "children" {
+				return nil, fmt.Errorf("line %d: unknown section type %q", lineNo, kind)
+			}
+			inv.group(section)
+			continue
+		}
+
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key=value in [%s:vars]", lineNo, section)
+			}
+			inv.group(section).Vars[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+
+		case "children":
+			grp := inv.group(section)
+			grp.Children = appendUnique(grp.Children, strings.Fields(line)[0])
+
+		default:
+			fields, err := splitINIFields(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			vars := map[string]interface{}{}
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					return nil, fmt.Errorf("line %d: expected key=value, got %q", lineNo, field)
+				}
+				vars[key] = unquote(value)
+			}
+			inv.addHost(section, fields[0], vars)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading inventory: %w", err)
+	}
+
+	return inv, nil
+}
+
+// ParseYAML parses a YAML inventory
+func ParseYAML(data []byte) (*AnsibleInventory, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid YAML inventory: %w", err)
+	}
+
+	inv := NewAnsibleInventory()
+	if len(root.Content) == 0 {
+		return inv, nil
+	}
+
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid YAML inventory: top level must be a mapping of groups")
+	}
+
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if err := inv.addYAMLGroup(doc.Content[i].Value, doc.Content[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return inv, nil
+}
+
+// yamlGroup is a group in a YAML inventory. Hosts and children are kept as
+// nodes so their document order is preserved.
+type yamlGroup struct {
+	Hosts    yaml.Node              `yaml:"hosts"`
+	Children yaml.Node              `yaml:"children"`
+	Vars     map[string]interface{} `yaml:"vars"`
+}
+
+// addYAMLGroup adds a YAML group, its hosts and its child groups
+func (inv *AnsibleInventory) addYAMLGroup(name string, node *yaml.Node) error {
+	grp := inv.group(name)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var yg yamlGroup
+	if err := node.Decode(&yg); err != nil {
+		return fmt.Errorf("group %s (line %d): %w", name, node.Line, err)
+	}
+
+	for k, v := range yg.Vars {
+		grp.Vars[k] = v
+	}
+
+	for i := 0; i+1 < len(yg.Hosts.Content); i += 2 {
+		hostName := yg.Hosts.Content[i].Value
+		vars := map[string]interface{}{}
+		if yg.Hosts.Content[i+1].Kind == yaml.MappingNode {
+			if err := yg.Hosts.Content[i+1].Decode(&vars); err != nil {
+				return fmt.Errorf("host %s (line %d): %w", hostName, yg.Hosts.Content[i].Line, err)
+			}
+		}
+		inv.addHost(name, hostName, vars)
+	}
+
+	for i := 0; i+1 < len(yg.Children.Content); i += 2 {
+		child, body := yg.Children.Content[i].Value, yg.Children.Content[i+1]
+		grp.Children = appendUnique(grp.Children, child)
+
+		// An empty child of any group but all references a group defined
+		// elsewhere, such as kube_node under k8s_cluster
+		if body.Kind != yaml.MappingNode && name != "all" {
+			continue
+		}
+		if err := inv.addYAMLGroup(child, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Inventory converts the parsed file into an Inventory of hosts. Each host
+// keeps its name, addresses, user, labels, taints and remaining variables,
+// and gets a role for each Kubespray group it belongs to. Hosts outside the
+// Kubespray groups, such as a bastion, are left out. Group variables are
+// kept as GroupVars.
+func (inv *AnsibleInventory) Inventory() *Inventory {
+	roles := map[string][]string{}
+	for _, grp := range [][]string{
+		{RoleControlPlane, "kube-master"},
+		{RoleEtcd},
+		{RoleNode, "kube-node"},
+		{RoleCalicoRR},
+	} {
+		for _, name := range inv.Members(grp...) {
+			roles[name] = append(roles[name], grp[0])
+		}
+	}
+
+	result := &Inventory{}
+	for _, name := range inv.HostOrder {
+		if len(roles[name]) == 0 {
+			continue
+		}
+
+		h := Host{Name: name, Address: inv.Address(name), Roles: roles[name]}
+		for k, v := range inv.Hosts[name] {
+			switch k {
+			case "ansible_host":
+			case "ip":
+				h.IP = fmt.Sprint(v)
+			case "access_ip":
+				h.AccessIP = fmt.Sprint(v)
+			case "ansible_user":
+				h.User = fmt.Sprint(v)
+			case "node_labels":
+				h.Labels = stringMap(literalValue(v))
+			case "node_taints":
+				h.Taints = stringList(literalValue(v))
+			default:
+				if h.Vars == nil {
+					h.Vars = map[string]interface{}{}
+				}
+				h.Vars[k] = v
+			}
+		}
+		result.Hosts = append(result.Hosts, h)
+	}
+
+	for _, name := range inv.GroupOrder {
+		vars := inv.Groups[name].Vars
+		if len(vars) == 0 {
+			continue
+		}
+		if result.GroupVars == nil {
+			result.GroupVars = map[string]map[string]interface{}{}
+		}
+		result.GroupVars[name] = make(map[string]interface{}, len(vars))
+		for k, v := range vars {
+			result.GroupVars[name][k] = literalValue(v)
+		}
+	}
+
+	return result
+}
+
+// Members returns the hosts of the first defined group among names,
+// including hosts of its child groups
+func (inv *AnsibleInventory) Members(names ...string) []string {
+	for _, name := range names {
+		if _, ok := inv.Groups[name]; ok {
+			return inv.members(name, map[string]bool{})
+		}
+	}
+	return nil
+}
+
+func (inv *AnsibleInventory) members(name string, visited map[string]bool) []string {
+	grp, ok := inv.Groups[name]
+	if !ok || visited[name] {
+		return nil
+	}
+	visited[name] = true
+
+	hosts := append([]string{}, grp.Hosts...)
+	for _, child := range grp.Children {
+		for _, h := range inv.members(child, visited) {
+			hosts = appendUnique(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// Lookup finds a host by name, or by ansible_host, ip or access_ip address
+func (inv *AnsibleInventory) Lookup(host string) (string, bool) {
+	if _, ok := inv.Hosts[host]; ok {
+		return host, true
+	}
+	for _, name := range inv.HostOrder {
+		if containsAddress(append(hostAddresses(inv, name), inv.Address(name)), host) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Address returns the address Ansible connects to for a host: ansible_host,
+// then ip, then the host name itself
+func (inv *AnsibleInventory) Address(name string) string {
+	vars := inv.Hosts[name]
+	for _, key := range []string{"ansible_host", "ip"} {
+		if v, ok := vars[key]; ok && fmt.Sprint(v) != "" {
+			return fmt.Sprint(v)
+		}
+	}
+	return name
+}
+
+// literalValue decodes a list or dict written in an INI inventory. Values
+// that are not JSON-compatible Python literals are returned unchanged.
+func literalValue(v interface{}) interface{} {
+	str, ok := v.(string)
+	if !ok || !(strings.HasPrefix(str, "{") || strings.HasPrefix(str, "[")) {
+		return v
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(str), &decoded); err != nil {
+		return v
+	}
+	return decoded
+}
+
+// stringMap converts a decoded mapping to map[string]string
+func stringMap(v interface{}) map[string]string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	result := make(map[string]string, len(m))
+	for k, val := range m {
+		result[k] = fmt.Sprint(val)
+	}
+	return result
+}
+
+// stringList converts a decoded sequence to []string
+func stringList(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]string, len(list))
+	for i, val := range list {
+		result[i] = fmt.Sprint(val)
+	}
+	return result
+}
+
+// group returns the named group, creating it if needed
+func (inv *AnsibleInventory) group(name string) *Group {
+	grp, ok := inv.Groups[name]
+	if !ok {
+		grp = &Group{Name: name, Vars: make(map[string]interface{})}
+		inv.Groups[name] = grp
+		inv.GroupOrder = append(inv.GroupOrder, name)
+	}
+	return grp
+}
+
+// addHost adds a host to a group, merging its variables
+func (inv *AnsibleInventory) addHost(groupName, name string, vars map[string]interface{}) {
+	if _, ok := inv.Hosts[name]; !ok {
+		inv.Hosts[name] = make(map[string]interface{})
+		inv.HostOrder = append(inv.HostOrder, name)
+	}
+	for k, v := range vars {
+		inv.Hosts[name][k] = v
+	}
+
+	grp := inv.group(groupName)
+	grp.Hosts = appendUnique(grp.Hosts, name)
+}
+
+// splitINIFields splits a host line on whitespace, keeping quoted values
+// together
+func splitINIFields(line string) ([]string, error) {
+	fields := []string{}
+	var cur strings.Builder
+	quote := rune(0)
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			cur.WriteRune(r)
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		case r == '#' && cur.Len() == 0:
+			// Trailing comment
+			return fields, nil
+		default:
+			cur.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
+
+// unquote strips matching single or double quotes
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// appendUnique appends s unless it is already present
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,227 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+const sampleINI = `# Kubespray inventory
+[all]
+node1 ansible_host=95.54.0.12 ip=10.3.0.1 etcd_member_name=etcd1
+node2 ansible_host=95.54.0.13 ip=10.3.0.2 ansible_user="admin user"
+node3 ip=10.3.0.3 # trailing comment
+
+[kube_control_plane]
+node1
+
+[etcd]
+node1
+node2
+node3
+
+[kube_node]
+node2
+node3
+
+[calico_rr]
+
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+calico_rr
+
+[all:vars]
+ansible_become=true
+`
+
+const sampleYAML = `all:
+  hosts:
+    node1:
+      ansible_host: 95.54.0.12
+      ip: 10.3.0.1
+    node2:
+      ansible_host: 95.54.0.13
+      ip: 10.3.0.2
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node2:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+    calico_rr:
+      hosts: {}
+  vars:
+    ansible_become: true
+`
+
+func TestParseINI(t *testing.T) {
+	inv, err := ParseINI([]byte(sampleINI))
+	if err != nil {
+		t.Fatalf("ParseINI failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(inv.HostOrder, []string{"node1", "node2", "node3"}) {
+		t.Errorf("Unexpected host order: %v", inv.HostOrder)
+	}
+	if inv.Hosts["node2"]["ansible_user"] != "admin user" {
+		t.Errorf("Expected quoted value to be unquoted, got %v", inv.Hosts["node2"]["ansible_user"])
+	}
+	if inv.Hosts["node1"]["etcd_member_name"] != "etcd1" {
+		t.Errorf("Expected etcd_member_name etcd1, got %v", inv.Hosts["node1"]["etcd_member_name"])
+	}
+	if inv.Groups["all"].Vars["ansible_become"] != "true" {
+		t.Errorf("Expected all vars to be parsed, got %v", inv.Groups["all"].Vars)
+	}
+
+	members := inv.Members("k8s_cluster")
+	if !reflect.DeepEqual(members, []string{"node1", "node2", "node3"}) {
+		t.Errorf("Expected k8s_cluster to include children hosts, got %v", members)
+	}
+
+	converted := inv.Inventory()
+	expected := []Host{
+		{
+			Name:    "node1",
+			Address: "95.54.0.12",
+			IP:      "10.3.0.1",
+			Roles:   []string{RoleControlPlane, RoleEtcd},
+			Vars:    map[string]interface{}{"etcd_member_name": "etcd1"},
+		},
+		{
+			Name:    "node2",
+			Address: "95.54.0.13",
+			IP:      "10.3.0.2",
+			User:    "admin user",
+			Roles:   []string{RoleEtcd, RoleNode},
+		},
+		{
+			Name:    "node3",
+			Address: "10.3.0.3",
+			IP:      "10.3.0.3",
+			Roles:   []string{RoleEtcd, RoleNode},
+		},
+	}
+	if !reflect.DeepEqual(converted.Hosts, expected) {
+		t.Errorf("Unexpected hosts:\n got %+v\nwant %+v", converted.Hosts, expected)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	inv, err := ParseYAML([]byte(sampleYAML))
+	if err != nil {
+		t.Fatalf("ParseYAML failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(inv.HostOrder, []string{"node1", "node2"}) {
+		t.Errorf("Unexpected host order: %v", inv.HostOrder)
+	}
+	if !reflect.DeepEqual(inv.Groups["k8s_cluster"].Children, []string{"kube_control_plane", "kube_node"}) {
+		t.Errorf("Unexpected k8s_cluster children: %v", inv.Groups["k8s_cluster"].Children)
+	}
+	if _, ok := inv.Groups["calico_rr"]; !ok {
+		t.Error("Expected calico_rr group to be defined")
+	}
+	if inv.Groups["all"].Vars["ansible_become"] != true {
+		t.Errorf("Expected typed YAML vars, got %v", inv.Groups["all"].Vars)
+	}
+
+	converted := inv.Inventory()
+	if len(converted.Hosts) != 2 || !reflect.DeepEqual(converted.Hosts[0].Roles, []string{RoleControlPlane, RoleEtcd}) {
+		t.Errorf("Unexpected hosts: %+v", converted.Hosts)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		yaml bool
+	}{
+		{"Malformed section", "[all\nnode1\n", false},
+		{"Unknown section type", "[all:hosts]\nnode1\n", false},
+		{"Bad host variable", "[all]\nnode1 ansible_host\n", false},
+		{"Unterminated quote", "[all]\nnode1 ansible_user=\"admin\n", false},
+		{"Bad vars line", "[all:vars]\nansible_become\n", false},
+		{"YAML list at top level", "- all\n", true},
+		{"YAML invalid syntax", "all:\n  hosts: [\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.yaml {
+				_, err = ParseYAML([]byte(tt.data))
+			} else {
+				_, err = ParseINI([]byte(tt.data))
+			}
+			if err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestParseGeneratedInventory(t *testing.T) {
+	gen := NewGenerator(config.NewConfig())
+	inv := &Inventory{
+		Masters: []string{"192.168.1.10"},
+		Nodes:   []string{"192.168.1.20"},
+		Etcd:    []string{"192.168.1.10", "192.168.1.11", "192.168.1.12"},
+		Hosts: []Host{
+			{
+				Name:     "gpu-0",
+				Address:  "203.0.113.30",
+				IP:       "192.168.1.30",
+				AccessIP: "192.168.1.30",
+				User:     "ubuntu",
+				Roles:    []string{RoleNode},
+				Vars:     map[string]interface{}{"kubelet_max_pods": "50"},
+				Labels:   map[string]string{"node-role.kubernetes.io/gpu": "", "zone": "a"},
+				Taints:   []string{"nvidia.com/gpu=present:NoSchedule"},
+			},
+		},
+	}
+
+	for _, name := range []string{"hosts.ini", "hosts.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, name)
+			if err := gen.Generate(inv, path); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+
+			parsed, err := ParseFile(path)
+			if err != nil {
+				t.Fatalf("ParseFile failed: %v", err)
+			}
+
+			converted := parsed.Inventory()
+			gpu := converted.Hosts[len(converted.Hosts)-1]
+			if !reflect.DeepEqual(gpu, inv.Hosts[0]) {
+				t.Errorf("Host mismatch:\n got %+v\nwant %+v", gpu, inv.Hosts[0])
+			}
+
+			regenerated := filepath.Join(dir, "regenerated"+filepath.Ext(name))
+			if err := gen.Generate(converted, regenerated); err != nil {
+				t.Fatalf("Generate from parsed inventory failed: %v", err)
+			}
+			want, _ := os.ReadFile(path)
+			got, _ := os.ReadFile(regenerated)
+			if string(got) != string(want) {
+				t.Errorf("Round trip mismatch:\n got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
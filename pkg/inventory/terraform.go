@@ -0,0 +1,364 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tfInstanceType lists, for a Terraform compute resource type, the
+// attributes holding the connection address and the internal IP in order
+// of preference
+type tfInstanceType struct {
+	address []string
+	ip      []string
+}
+
+// terraformInstances are the compute resource types imported from state
+var terraformInstances = map[string]tfInstanceType{
+	"aws_instance": {
+		address: []string{"public_ip", "private_ip"},
+		ip:      []string{"private_ip"},
+	},
+	"google_compute_instance": {
+		address: []string{"network_interface.0.access_config.0.nat_ip", "network_interface.0.network_ip"},
+		ip:      []string{"network_interface.0.network_ip"},
+	},
+	"openstack_compute_instance_v2": {
+		address: []string{"access_ip_v4", "network.0.fixed_ip_v4"},
+		ip:      []string{"network.0.fixed_ip_v4"},
+	},
+	"azurerm_linux_virtual_machine": {
+		address: []string{"public_ip_address", "private_ip_address"},
+		ip:      []string{"private_ip_address"},
+	},
+	"digitalocean_droplet": {
+		address: []string{"ipv4_address"},
+		ip:      []string{"ipv4_address_private"},
+	},
+	"hcloud_server": {
+		address: []string{"ipv4_address"},
+	},
+	"equinix_metal_device": {
+		address: []string{"access_public_ipv4"},
+		ip:      []string{"access_private_ipv4"},
+	},
+	"vsphere_virtual_machine": {
+		address: []string{"default_ip_address"},
+	},
+}
+
+// tfTagAttributes are the resource attributes holding tags or labels
+var tfTagAttributes = []string{"tags", "labels", "metadata"}
+
+// tfResource is a resource instance from a state file or `terraform show`
+type tfResource struct {
+	address string
+	typ     string
+	name    string
+	attrs   map[string]interface{}
+}
+
+// ImportTerraform builds an inventory from Terraform JSON: a state file
+// (terraform.tfstate), the output of `terraform show -json` or the output of
+// `terraform output -json`. Compute instances in the state are imported with
+// their tags; when there are none, outputs listing addresses are used.
+//
+// Roles come from a role, Role or kubespray_role tag, or from resource or
+// output names containing master, control, etcd, worker or node. Rules can
+// match the fields resource, name, address, output and tag.<key>.
+func ImportTerraform(data []byte, rules []RoleRule) (*Inventory, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid Terraform JSON: %w", err)
+	}
+
+	var resources []tfResource
+	outputs := doc
+	switch {
+	case doc["values"] != nil:
+		values, _ := doc["values"].(map[string]interface{})
+		if root, ok := values["root_module"].(map[string]interface{}); ok {
+			resources = tfModuleResources(root)
+		}
+		outputs, _ = values["outputs"].(map[string]interface{})
+	case doc["resources"] != nil:
+		resources = tfStateResources(doc["resources"])
+		outputs, _ = doc["outputs"].(map[string]interface{})
+	}
+
+	records := []record{}
+	for _, res := range resources {
+		rec, ok := tfInstanceRecord(res)
+		if ok {
+			records = append(records, rec)
+		}
+	}
+	if len(records) == 0 {
+		records = tfOutputRecords(outputs)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no compute instances or host outputs found in Terraform JSON")
+	}
+
+	hosts, err := assignRoles(records, rules)
+	if err != nil {
+		return nil, err
+	}
+	return &Inventory{Hosts: hosts}, nil
+}
+
+// tfStateResources returns the managed resource instances of a version 4
+// state file
+func tfStateResources(v interface{}) []tfResource {
+	list, _ := v.([]interface{})
+	resources := []tfResource{}
+	for _, item := range list {
+		res, _ := item.(map[string]interface{})
+		if res["mode"] != "managed" {
+			continue
+		}
+		typ, _ := res["type"].(string)
+		name, _ := res["name"].(string)
+		address := typ + "." + name
+		if module, ok := res["module"].(string); ok && module != "" {
+			address = module + "." + address
+		}
+
+		instances, _ := res["instances"].([]interface{})
+		for _, inst := range instances {
+			instance, _ := inst.(map[string]interface{})
+			attrs, _ := instance["attributes"].(map[string]interface{})
+			addr := address
+			if key, ok := instance["index_key"]; ok {
+				addr += tfIndex(key)
+			}
+			resources = append(resources, tfResource{address: addr, typ: typ, name: name, attrs: attrs})
+		}
+	}
+	return resources
+}
+
+// tfModuleResources returns the managed resources of a `terraform show
+// -json` module and its child modules
+func tfModuleResources(module map[string]interface{}) []tfResource {
+	resources := []tfResource{}
+
+	list, _ := module["resources"].([]interface{})
+	for _, item := range list {
+		res, _ := item.(map[string]interface{})
+		if res["mode"] != "managed" {
+			continue
+		}
+		address, _ := res["address"].(string)
+		typ, _ := res["type"].(string)
+		name, _ := res["name"].(string)
+		attrs, _ := res["values"].(map[string]interface{})
+		resources = append(resources, tfResource{address: address, typ: typ, name: name, attrs: attrs})
+	}
+
+	children, _ := module["child_modules"].([]interface{})
+	for _, child := range children {
+		if m, ok := child.(map[string]interface{}); ok {
+			resources = append(resources, tfModuleResources(m)...)
+		}
+	}
+	return resources
+}
+
+// tfIndex formats an instance index key as in a resource address
+func tfIndex(key interface{}) string {
+	switch k := key.(type) {
+	case float64:
+		return "[" + strconv.Itoa(int(k)) + "]"
+	case string:
+		return "[" + strconv.Quote(k) + "]"
+	default:
+		return ""
+	}
+}
+
+// tfInstanceRecord converts a compute instance into a host record
+func tfInstanceRecord(res tfResource) (record, bool) {
+	kind, ok := terraformInstances[res.typ]
+	if !ok {
+		return record{}, false
+	}
+
+	rec := record{fields: map[string][]string{
+		"resource": {res.typ + "." + res.name, res.address},
+	}}
+	rec.host.Address = firstAttr(res.attrs, kind.address)
+	if rec.host.Address == "" {
+		return record{}, false
+	}
+	if ip := firstAttr(res.attrs, kind.ip); ip != rec.host.Address {
+		rec.host.IP = ip
+	}
+
+	for _, attr := range tfTagAttributes {
+		tags, _ := res.attrs[attr].(map[string]interface{})
+		for k, v := range tags {
+			rec.fields["tag."+strings.ToLower(k)] = []string{fmt.Sprint(v)}
+		}
+	}
+
+	name := firstAttr(res.attrs, []string{"tags.Name", "name"})
+	if name != "" && validateHostname(normalizeHostname(name)) == nil {
+		rec.host.Name = normalizeHostname(name)
+	}
+
+	rec.fields["name"] = []string{rec.host.Name}
+	rec.fields["address"] = []string{rec.host.Address}
+
+	for _, tag := range []string{"tag.role", "tag.kubespray_role"} {
+		if values := rec.fields[tag]; len(values) > 0 {
+			if roles, err := parseRoles(values[0]); err == nil {
+				rec.roles = roles
+				return rec, true
+			}
+		}
+	}
+	rec.roles = rolesFromName(res.name)
+	return rec, true
+}
+
+// tfOutputRecords reads hosts from outputs holding an address, a list of
+// addresses or a map of host names to addresses. Output names give the
+// roles; an address listed in several outputs is one host.
+func tfOutputRecords(outputs map[string]interface{}) []record {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	records := []record{}
+	byAddress := map[string]int{}
+	for _, output := range names {
+		value := outputs[output]
+		if wrapped, ok := value.(map[string]interface{}); ok {
+			if v, ok := wrapped["value"]; ok {
+				value = v
+			}
+		}
+
+		for _, entry := range tfOutputHosts(value) {
+			roles := rolesFromName(output)
+			if idx, ok := byAddress[entry.address]; ok {
+				rec := &records[idx]
+				rec.fields["output"] = append(rec.fields["output"], output)
+				for _, role := range roles {
+					rec.roles = appendUnique(rec.roles, role)
+				}
+				continue
+			}
+
+			byAddress[entry.address] = len(records)
+			records = append(records, record{
+				host:  Host{Name: entry.name, Address: entry.address},
+				roles: roles,
+				fields: map[string][]string{
+					"output":  {output},
+					"name":    {entry.name},
+					"address": {entry.address},
+				},
+			})
+		}
+	}
+	return records
+}
+
+// tfOutputHost is a host listed by an output
+type tfOutputHost struct {
+	name    string
+	address string
+}
+
+// tfOutputHosts extracts hosts from an output value. Values that are not
+// addresses are ignored.
+func tfOutputHosts(value interface{}) []tfOutputHost {
+	hosts := []tfOutputHost{}
+	switch v := value.(type) {
+	case string:
+		for _, addr := range strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == '\n' || r == ' '
+		}) {
+			if isIP(addr) {
+				hosts = append(hosts, tfOutputHost{address: addr})
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			hosts = append(hosts, tfOutputHosts(item)...)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			addr, ok := v[k].(string)
+			if !ok || !isIP(addr) {
+				continue
+			}
+			name := normalizeHostname(k)
+			if validateHostname(name) != nil {
+				name = ""
+			}
+			hosts = append(hosts, tfOutputHost{name: name, address: addr})
+		}
+	}
+	return hosts
+}
+
+// rolesFromName guesses roles from a resource or output name
+func rolesFromName(name string) []string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "master") || strings.Contains(name, "control"):
+		return []string{RoleControlPlane}
+	case strings.Contains(name, "etcd"):
+		return []string{RoleEtcd}
+	case strings.Contains(name, "worker") || strings.Contains(name, "node"):
+		return []string{RoleNode}
+	default:
+		return nil
+	}
+}
+
+// firstAttr returns the first non-empty attribute among dotted paths such
+// as network_interface.0.network_ip
+func firstAttr(attrs map[string]interface{}, paths []string) string {
+	for _, p := range paths {
+		var cur interface{} = attrs
+		for _, part := range strings.Split(p, ".") {
+			switch node := cur.(type) {
+			case map[string]interface{}:
+				cur = node[part]
+			case []interface{}:
+				i, err := strconv.Atoi(part)
+				if err != nil || i >= len(node) {
+					cur = nil
+				} else {
+					cur = node[i]
+				}
+			default:
+				cur = nil
+			}
+		}
+		if s, ok := cur.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// isIP reports whether s is an IP address
+func isIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
@@ -0,0 +1,105 @@
+package inventory
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestImportTerraformState(t *testing.T) {
+	data, err := os.ReadFile("testdata/terraform.tfstate")
+	if err != nil {
+		t.Fatalf("Failed to read state: %v", err)
+	}
+
+	inv, err := ImportTerraform(data, []RoleRule{{Field: "tag.pool", Pattern: "gpu", Roles: []string{RoleNode}}})
+	if err != nil {
+		t.Fatalf("ImportTerraform failed: %v", err)
+	}
+
+	expected := []Host{
+		{Name: "prod-cp-1", Address: "203.0.113.10", IP: "10.0.1.10", Roles: []string{RoleControlPlane}},
+		{Name: "prod-cp-2", Address: "203.0.113.11", IP: "10.0.1.11", Roles: []string{RoleControlPlane}},
+		{Name: "prod-worker-1", Address: "10.0.2.10", Roles: []string{RoleNode}},
+		{Name: "prod-gpu-1", Address: "10.0.2.11", Roles: []string{RoleNode}},
+	}
+	if !reflect.DeepEqual(inv.Hosts, expected) {
+		t.Errorf("Unexpected hosts:\n got %+v\nwant %+v", inv.Hosts, expected)
+	}
+}
+
+func TestImportTerraformShow(t *testing.T) {
+	data := []byte(`{
+  "format_version": "1.0",
+  "values": {
+    "root_module": {
+      "child_modules": [{
+        "address": "module.cluster",
+        "resources": [
+          {"address": "module.cluster.google_compute_instance.etcd[0]", "mode": "managed", "type": "google_compute_instance", "name": "etcd",
+           "values": {"name": "etcd-a", "network_interface": [{"network_ip": "10.1.0.5", "access_config": []}]}},
+          {"address": "module.cluster.google_compute_instance.node[0]", "mode": "managed", "type": "google_compute_instance", "name": "node",
+           "values": {"name": "node-a", "labels": {"role": "master"}, "network_interface": [{"network_ip": "10.1.0.6", "access_config": [{"nat_ip": "198.51.100.6"}]}]}}
+        ]
+      }]
+    }
+  }
+}`)
+
+	inv, err := ImportTerraform(data, nil)
+	if err != nil {
+		t.Fatalf("ImportTerraform failed: %v", err)
+	}
+
+	expected := []Host{
+		{Name: "etcd-a", Address: "10.1.0.5", Roles: []string{RoleEtcd}},
+		{Name: "node-a", Address: "198.51.100.6", IP: "10.1.0.6", Roles: []string{RoleControlPlane}},
+	}
+	if !reflect.DeepEqual(inv.Hosts, expected) {
+		t.Errorf("Unexpected hosts:\n got %+v\nwant %+v", inv.Hosts, expected)
+	}
+}
+
+func TestImportTerraformOutputs(t *testing.T) {
+	data := []byte(`{
+  "master_ips": {"sensitive": false, "type": ["list", "string"], "value": ["10.0.0.1", "10.0.0.2", "10.0.0.3"]},
+  "etcd_ips": {"sensitive": false, "type": "string", "value": "10.0.0.1,10.0.0.2,10.0.0.3"},
+  "workers": {"sensitive": false, "type": ["map", "string"], "value": {"Worker-B": "10.0.0.12", "worker-a": "10.0.0.11"}},
+  "bastion": {"sensitive": false, "type": "string", "value": "203.0.113.1"},
+  "vpc_id": {"sensitive": false, "type": "string", "value": "vpc-123"}
+}`)
+
+	if _, err := ImportTerraform(data, nil); err == nil {
+		t.Error("Expected error for bastion without a role")
+	}
+
+	inv, err := ImportTerraform(data, []RoleRule{{Field: "output", Pattern: "bastion"}})
+	if err != nil {
+		t.Fatalf("ImportTerraform failed: %v", err)
+	}
+
+	expected := []Host{
+		{Address: "10.0.0.1", Roles: []string{RoleEtcd, RoleControlPlane}},
+		{Address: "10.0.0.2", Roles: []string{RoleEtcd, RoleControlPlane}},
+		{Address: "10.0.0.3", Roles: []string{RoleEtcd, RoleControlPlane}},
+		{Name: "worker-b", Address: "10.0.0.12", Roles: []string{RoleNode}},
+		{Name: "worker-a", Address: "10.0.0.11", Roles: []string{RoleNode}},
+	}
+	if !reflect.DeepEqual(inv.Hosts, expected) {
+		t.Errorf("Unexpected hosts:\n got %+v\nwant %+v", inv.Hosts, expected)
+	}
+}
+
+func TestImportTerraformErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"Invalid JSON":  `{`,
+		"No hosts":      `{"vpc_id": {"value": "vpc-123"}}`,
+		"Unknown roles": `{"gateways": {"value": ["10.0.0.1"]}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ImportTerraform([]byte(data), nil); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
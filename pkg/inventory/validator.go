@@ -1,79 +1,405 @@
 package inventory
 
 import (
-	"bufio"
 	"fmt"
-	"os"
+	"net"
 	"strings"
 
 	"github.com/vjranagit/kubespray/pkg/config"
 )
 
+// Finding severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Finding codes. Each code is also the id of the rule that reports it.
+const (
+	FindingMissingGroup   = "missing-group"
+	FindingEmptyGroup     = "empty-group"
+	FindingHostNotInAll   = "host-not-in-all"
+	FindingUndefinedChild = "undefined-child"
+	FindingEtcdQuorum     = "etcd-quorum"
+	FindingEtcdNotInAll   = "etcd-not-in-all"
+	FindingDuplicateIP    = "duplicate-ip"
+	FindingControlPlane   = "control-plane-not-in-cluster"
+	FindingMixedAccessIP  = "mixed-access-ip"
+
+	// Reported by ValidateOnline
+	FindingUnreachable      = "host-unreachable"
+	FindingLoginFailed      = "ssh-login-failed"
+	FindingProbeFailed      = "probe-failed"
+	FindingIPNotConfigured  = "ip-not-configured"
+	FindingHostnameMismatch = "hostname-mismatch"
+)
+
+// Finding is a problem found in an inventory
+type Finding struct {
+	Code     string
+	Severity string
+	Group    string
+	Host     string
+	Message  string
+}
+
+// String formats the finding for display
+func (f Finding) String() string {
+	return fmt.Sprintf("%s [%s] %s", f.Severity, f.Code, f.Message)
+}
+
+// HasErrors reports whether any finding is an error
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule is a single inventory check
+type Rule struct {
+	ID          string
+	Description string
+	// Online rules inspect the live hosts and only run in ValidateOnline
+	Online bool
+	check  func(inv *AnsibleInventory) []Finding
+	probe  func(inv *AnsibleInventory, live map[string]liveHost) []Finding
+}
+
+// rules run in order; findings are reported in the same order
+var rules = []Rule{
+	{ID: FindingMissingGroup, Description: "the all group and every group Kubespray requires are defined", check: checkMissingGroups},
+	{ID: FindingEmptyGroup, Description: "required groups contain at least one host", check: checkEmptyGroups},
+	{ID: FindingUndefinedChild, Description: "child groups are defined", check: checkUndefinedChildren},
+	{ID: FindingHostNotInAll, Description: "hosts are declared in all", check: checkHostsInAll},
+	{ID: FindingEtcdQuorum, Description: "etcd has an odd number of members", check: checkEtcdQuorum},
+	{ID: FindingEtcdNotInAll, Description: "etcd members are declared in all", check: checkEtcdInAll},
+	{ID: FindingDuplicateIP, Description: "no address is shared by two hosts", check: checkDuplicateIPs},
+	{ID: FindingControlPlane, Description: "control plane hosts are members of k8s_cluster", check: checkControlPlaneInCluster},
+	{ID: FindingMixedAccessIP, Description: "access_ip values use a single address family", check: checkAccessIPFamily},
+	{ID: FindingUnreachable, Description: "ansible_host accepts SSH connections", Online: true, probe: checkReachable},
+	{ID: FindingLoginFailed, Description: "the SSH user can log in to the host", Online: true, probe: checkLogin},
+	{ID: FindingProbeFailed, Description: "the hostname and addresses of the host can be read", Online: true, probe: checkProbe},
+	{ID: FindingIPNotConfigured, Description: "ip is configured on an interface of the host", Online: true, probe: checkIPConfigured},
+	{ID: FindingHostnameMismatch, Description: "the hostname of the host matches its inventory name", Online: true, probe: checkHostname},
+}
+
+// Rules returns the available validation rules
+func Rules() []Rule {
+	return append([]Rule{}, rules...)
+}
+
+// requiredGroups are the groups Kubespray needs, each with its legacy alias
+var requiredGroups = [][]string{
+	{"kube_control_plane", "kube-master"},
+	{"kube_node", "kube-node"},
+	{"etcd"},
+	{"k8s_cluster", "k8s-cluster"},
+}
+
 // Validator validates Ansible inventory files
 type Validator struct {
-	config *config.Config
+	config   *config.Config
+	disabled map[string]bool
+	prober   HostProber
 }
 
 // NewValidator creates a new inventory validator
 func NewValidator(cfg *config.Config) *Validator {
-	return &Validator{config: cfg}
+	return &Validator{config: cfg, disabled: make(map[string]bool)}
+}
+
+// Disable turns off the rules with the given ids
+func (v *Validator) Disable(ids ...string) error {
+	for _, id := range ids {
+		if !knownRule(id) {
+			return fmt.Errorf("unknown inventory rule %q", id)
+		}
+		v.disabled[id] = true
+	}
+	return nil
 }
 
-// Validate checks if an inventory file is valid
-func (v *Validator) Validate(path string) error {
-	file, err := os.Open(path)
+// Validate parses an INI or YAML inventory file and returns every finding.
+// An error is returned only when the file cannot be read or parsed.
+func (v *Validator) Validate(path string) ([]Finding, error) {
+	inv, err := ParseFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to open inventory: %w", err)
+		return nil, err
+	}
+	return v.ValidateInventory(inv), nil
+}
+
+// ValidateInventory runs every enabled rule against a parsed inventory
+func (v *Validator) ValidateInventory(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+	for _, r := range rules {
+		if v.disabled[r.ID] || r.Online {
+			continue
+		}
+		findings = append(findings, r.check(inv)...)
+	}
+	return findings
+}
+
+func checkMissingGroups(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+
+	if _, ok := inv.Groups["all"]; !ok {
+		findings = append(findings, Finding{
+			Code:     FindingMissingGroup,
+			Severity: SeverityError,
+			Group:    "all",
+			Message:  "inventory missing [all] group",
+		})
+	}
+
+	for _, names := range requiredGroups {
+		if _, ok := firstDefined(inv, names); !ok {
+			findings = append(findings, Finding{
+				Code:     FindingMissingGroup,
+				Severity: SeverityError,
+				Group:    names[0],
+				Message:  fmt.Sprintf("inventory missing [%s] group", names[0]),
+			})
+		}
+	}
+
+	return findings
+}
+
+func checkEmptyGroups(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+	for _, names := range requiredGroups {
+		name, ok := firstDefined(inv, names)
+		if ok && len(inv.Members(name)) == 0 {
+			findings = append(findings, Finding{
+				Code:     FindingEmptyGroup,
+				Severity: SeverityError,
+				Group:    name,
+				Message:  fmt.Sprintf("group [%s] has no hosts", name),
+			})
+		}
+	}
+	return findings
+}
+
+func checkUndefinedChildren(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+	for _, name := range inv.GroupOrder {
+		for _, child := range inv.Groups[name].Children {
+			if _, ok := inv.Groups[child]; !ok {
+				findings = append(findings, Finding{
+					Code:     FindingUndefinedChild,
+					Severity: SeverityError,
+					Group:    name,
+					Message:  fmt.Sprintf("group [%s] lists undefined child group %s", name, child),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkHostsInAll(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+	if _, ok := inv.Groups["all"]; !ok {
+		return findings
+	}
+
+	declared := declaredInAll(inv)
+	for _, h := range inv.HostOrder {
+		if !declared[h] {
+			findings = append(findings, Finding{
+				Code:     FindingHostNotInAll,
+				Severity: SeverityWarning,
+				Host:     h,
+				Message:  fmt.Sprintf("host %s is not declared in [all]", h),
+			})
+		}
+	}
+	return findings
+}
+
+func checkEtcdQuorum(inv *AnsibleInventory) []Finding {
+	members := inv.Members("etcd")
+	if len(members) == 0 || len(members)%2 == 1 {
+		return nil
 	}
-	defer file.Close()
+	return []Finding{{
+		Code:     FindingEtcdQuorum,
+		Severity: SeverityError,
+		Group:    "etcd",
+		Message: fmt.Sprintf("etcd has %d members; an even count tolerates no more failures than %d, use %d or %d",
+			len(members), len(members)-1, len(members)-1, len(members)+1),
+	}}
+}
 
-	var (
-		hasAll          bool
-		hasControlPlane bool
-		hasNode         bool
-		hasEtcd         bool
-	)
+// checkEtcdInAll reports etcd members that are only listed in the etcd
+// group. Unlike other hosts they get no ip or access_ip from [all], which
+// etcd needs to build its peer URLs.
+func checkEtcdInAll(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+	if _, ok := inv.Groups["all"]; !ok {
+		return findings
+	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	declared := declaredInAll(inv)
+	for _, h := range inv.Members("etcd") {
+		if !declared[h] {
+			findings = append(findings, Finding{
+				Code:     FindingEtcdNotInAll,
+				Severity: SeverityError,
+				Group:    "etcd",
+				Host:     h,
+				Message:  fmt.Sprintf("etcd member %s is not defined in [all]", h),
+			})
+		}
+	}
+	return findings
+}
 
-		// Skip comments and empty lines
-		if line == "" || strings.HasPrefix(line, "#") {
+func checkDuplicateIPs(inv *AnsibleInventory) []Finding {
+	owners := map[string][]string{}
+	order := []string{}
+	for _, h := range inv.HostOrder {
+		for _, addr := range hostAddresses(inv, h) {
+			if _, ok := owners[addr]; !ok {
+				order = append(order, addr)
+			}
+			owners[addr] = appendUnique(owners[addr], h)
+		}
+	}
+
+	findings := []Finding{}
+	for _, addr := range order {
+		hosts := owners[addr]
+		if len(hosts) < 2 {
 			continue
 		}
+		findings = append(findings, Finding{
+			Code:     FindingDuplicateIP,
+			Severity: SeverityError,
+			Host:     hosts[1],
+			Message:  fmt.Sprintf("address %s is used by hosts %s", addr, strings.Join(hosts, ", ")),
+		})
+	}
+	return findings
+}
 
-		// Check for required sections
-		switch line {
-		case "[all]":
-			hasAll = true
-		case "[kube_control_plane]", "[kube-master]":
-			hasControlPlane = true
-		case "[kube_node]", "[kube-node]":
-			hasNode = true
-		case "[etcd]":
-			hasEtcd = true
+func checkControlPlaneInCluster(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+	cluster, ok := firstDefined(inv, []string{"k8s_cluster", "k8s-cluster"})
+	if !ok {
+		return findings
+	}
+
+	members := map[string]bool{}
+	for _, h := range inv.Members(cluster) {
+		members[h] = true
+	}
+	for _, h := range inv.Members("kube_control_plane", "kube-master") {
+		if !members[h] {
+			findings = append(findings, Finding{
+				Code:     FindingControlPlane,
+				Severity: SeverityError,
+				Group:    cluster,
+				Host:     h,
+				Message:  fmt.Sprintf("control plane host %s is not a member of [%s]", h, cluster),
+			})
 		}
 	}
+	return findings
+}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading inventory: %w", err)
+func checkAccessIPFamily(inv *AnsibleInventory) []Finding {
+	findings := []Finding{}
+	families := map[string][]string{}
+	for _, h := range inv.HostOrder {
+		v, ok := inv.Hosts[h]["access_ip"]
+		if !ok {
+			continue
+		}
+		ip := net.ParseIP(fmt.Sprint(v))
+		if ip == nil {
+			findings = append(findings, Finding{
+				Code:     FindingMixedAccessIP,
+				Severity: SeverityError,
+				Host:     h,
+				Message:  fmt.Sprintf("host %s has invalid access_ip %v", h, v),
+			})
+			continue
+		}
+		family := "IPv6"
+		if ip.To4() != nil {
+			family = "IPv4"
+		}
+		families[family] = append(families[family], h)
 	}
 
-	// Validate required sections exist
-	if !hasAll {
-		return fmt.Errorf("inventory missing [all] section")
+	if len(families) > 1 {
+		findings = append(findings, Finding{
+			Code:     FindingMixedAccessIP,
+			Severity: SeverityError,
+			Host:     families["IPv6"][0],
+			Message: fmt.Sprintf("access_ip mixes IPv4 (%s) and IPv6 (%s) addresses",
+				strings.Join(families["IPv4"], ", "), strings.Join(families["IPv6"], ", ")),
+		})
 	}
-	if !hasControlPlane {
-		return fmt.Errorf("inventory missing [kube_control_plane] section")
+	return findings
+}
+
+// hostAddresses returns the distinct node addresses of a host: ip (or
+// ansible_host when ip is unset) and access_ip
+func hostAddresses(inv *AnsibleInventory, name string) []string {
+	vars := inv.Hosts[name]
+	addrs := []string{}
+	for _, keys := range [][]string{{"ip", "ansible_host"}, {"access_ip"}} {
+		for _, key := range keys {
+			v, ok := vars[key]
+			if !ok || fmt.Sprint(v) == "" {
+				continue
+			}
+			addr := fmt.Sprint(v)
+			if ip := net.ParseIP(addr); ip != nil {
+				addr = ip.String()
+			}
+			addrs = appendUnique(addrs, addr)
+			break
+		}
 	}
-	if !hasNode {
-		return fmt.Errorf("inventory missing [kube_node] section")
+	return addrs
+}
+
+// declaredInAll returns the hosts listed directly in the all group
+func declaredInAll(inv *AnsibleInventory) map[string]bool {
+	declared := map[string]bool{}
+	if all, ok := inv.Groups["all"]; ok {
+		for _, h := range all.Hosts {
+			declared[h] = true
+		}
 	}
-	if !hasEtcd {
-		return fmt.Errorf("inventory missing [etcd] section")
+	return declared
+}
+
+// knownRule reports whether id names a validation rule
+func knownRule(id string) bool {
+	for _, r := range rules {
+		if r.ID == id {
+			return true
+		}
 	}
+	return false
+}
 
-	fmt.Printf("Inventory validation successful: %s\n", path)
-	return nil
+// firstDefined returns the first of names that is a group in inv
+func firstDefined(inv *AnsibleInventory, names []string) (string, bool) {
+	for _, name := range names {
+		if _, ok := inv.Groups[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,296 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+func TestValidateFindings(t *testing.T) {
+	validator := NewValidator(config.NewConfig())
+
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected []Finding
+	}{
+		{
+			name:     "Valid INI",
+			file:     "hosts.ini",
+			content:  sampleINI,
+			expected: []Finding{},
+		},
+		{
+			name:     "Valid YAML",
+			file:     "hosts.yaml",
+			content:  sampleYAML,
+			expected: []Finding{},
+		},
+		{
+			name: "Missing and empty groups",
+			file: "hosts.ini",
+			content: `[all]
+node1 ansible_host=10.0.0.1
+
+[kube_control_plane]
+node1
+
+[etcd]
+
+[k8s_cluster:children]
+kube_control_plane
+`,
+			expected: []Finding{
+				{Code: FindingMissingGroup, Group: "kube_node"},
+				{Code: FindingEmptyGroup, Group: "etcd"},
+			},
+		},
+		{
+			name: "Undefined child and host outside all",
+			file: "hosts.ini",
+			content: `[all]
+node1 ansible_host=10.0.0.1
+
+[kube_control_plane]
+node1
+
+[etcd]
+node1
+
+[kube_node]
+node2
+
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+calico_rr
+`,
+			expected: []Finding{
+				{Code: FindingUndefinedChild, Group: "k8s_cluster"},
+				{Code: FindingHostNotInAll, Host: "node2"},
+			},
+		},
+		{
+			name: "YAML missing all hosts",
+			file: "hosts.yml",
+			content: `all:
+  children:
+    kube_control_plane:
+      hosts:
+        node1:
+    kube_node:
+      hosts:
+        node1:
+    etcd:
+      hosts:
+        node1:
+    k8s_cluster:
+      children:
+        kube_control_plane:
+        kube_node:
+        calico_rr:
+`,
+			expected: []Finding{
+				{Code: FindingUndefinedChild, Group: "k8s_cluster"},
+				{Code: FindingHostNotInAll, Host: "node1"},
+				{Code: FindingEtcdNotInAll, Group: "etcd", Host: "node1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write inventory: %v", err)
+			}
+
+			findings, err := validator.Validate(path)
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+
+			if len(findings) != len(tt.expected) {
+				t.Fatalf("Expected %d findings, got %d: %v", len(tt.expected), len(findings), findings)
+			}
+			for i, f := range findings {
+				want := tt.expected[i]
+				if f.Code != want.Code || f.Group != want.Group || f.Host != want.Host {
+					t.Errorf("Finding %d: expected %s group=%q host=%q, got %v", i, want.Code, want.Group, want.Host, f)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name: "Even etcd members",
+			content: `[all]
+node1 ansible_host=10.0.0.1
+node2 ansible_host=10.0.0.2
+[kube_control_plane]
+node1
+[etcd]
+node1
+node2
+[kube_node]
+node2
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+`,
+			expected: []string{FindingEtcdQuorum},
+		},
+		{
+			name: "Duplicate IPs",
+			content: `[all]
+node1 ansible_host=10.0.0.1 ip=10.0.0.1
+node2 ansible_host=10.0.0.2 ip=10.0.0.1
+node3 ansible_host=10.0.0.3 access_ip=10.0.0.2
+[kube_control_plane]
+node1
+[etcd]
+node1
+[kube_node]
+node2
+node3
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+`,
+			expected: []string{FindingDuplicateIP},
+		},
+		{
+			name: "Control plane outside k8s_cluster",
+			content: `[all]
+node1 ansible_host=10.0.0.1
+node2 ansible_host=10.0.0.2
+[kube_control_plane]
+node1
+[etcd]
+node1
+[kube_node]
+node2
+[k8s_cluster:children]
+kube_node
+`,
+			expected: []string{FindingControlPlane},
+		},
+		{
+			name: "Mixed access_ip families",
+			content: `[all]
+node1 ansible_host=10.0.0.1 access_ip=10.0.0.1
+node2 ansible_host=10.0.0.2 access_ip=fd00::2
+[kube_control_plane]
+node1
+[etcd]
+node1
+[kube_node]
+node2
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+`,
+			expected: []string{FindingMixedAccessIP},
+		},
+		{
+			name: "Invalid access_ip",
+			content: `[all]
+node1 ansible_host=10.0.0.1 access_ip=node1.local
+[kube_control_plane]
+node1
+[etcd]
+node1
+[kube_node]
+node1
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+`,
+			expected: []string{FindingMixedAccessIP},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := ParseINI([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseINI failed: %v", err)
+			}
+
+			findings := NewValidator(config.NewConfig()).ValidateInventory(inv)
+			codes := []string{}
+			for _, f := range findings {
+				codes = append(codes, f.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.expected) {
+				t.Errorf("Expected findings %v, got %v", tt.expected, findings)
+			}
+		})
+	}
+}
+
+func TestValidatorDisable(t *testing.T) {
+	inv, err := ParseINI([]byte(`[all]
+node1 ansible_host=10.0.0.1
+node2 ansible_host=10.0.0.1
+[kube_control_plane]
+node1
+[etcd]
+node1
+node2
+[kube_node]
+node2
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+`))
+	if err != nil {
+		t.Fatalf("ParseINI failed: %v", err)
+	}
+
+	validator := NewValidator(config.NewConfig())
+	if len(validator.ValidateInventory(inv)) != 2 {
+		t.Fatalf("Expected quorum and duplicate IP findings, got %v", validator.ValidateInventory(inv))
+	}
+
+	if err := validator.Disable(FindingEtcdQuorum, FindingDuplicateIP); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if findings := validator.ValidateInventory(inv); len(findings) != 0 {
+		t.Errorf("Expected no findings with rules disabled, got %v", findings)
+	}
+
+	if err := validator.Disable("no-such-rule"); err == nil {
+		t.Error("Expected error for unknown rule")
+	}
+}
+
+func TestValidateSeverity(t *testing.T) {
+	findings := []Finding{{Code: FindingHostNotInAll, Severity: SeverityWarning}}
+	if HasErrors(findings) {
+		t.Error("Expected warnings alone not to count as errors")
+	}
+
+	findings = append(findings, Finding{Code: FindingEmptyGroup, Severity: SeverityError})
+	if !HasErrors(findings) {
+		t.Error("Expected error finding to be reported")
+	}
+}
+
+func TestValidateMissingFile(t *testing.T) {
+	validator := NewValidator(config.NewConfig())
+	if _, err := validator.Validate(filepath.Join(t.TempDir(), "missing.ini")); err == nil {
+		t.Error("Expected error for missing inventory file")
+	}
+}
@@ -0,0 +1,109 @@
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renderYAML renders a layout as a YAML inventory in the layout of the
+// upstream Kubespray hosts.yaml sample
+func renderYAML(l *layout) ([]byte, error) {
+	hosts := mappingNode()
+	for _, h := range l.hosts {
+		vars, err := yamlHostVars(h)
+		if err != nil {
+			return nil, err
+		}
+		addPair(hosts, h.Name, vars)
+	}
+
+	children := mappingNode()
+	for _, grp := range l.groups {
+		body := mappingNode()
+		if len(grp.children) > 0 {
+			members := mappingNode()
+			for _, child := range grp.children {
+				addPair(members, child, nullNode())
+			}
+			addPair(body, "children", members)
+		} else {
+			members := mappingNode()
+			for _, name := range grp.hosts {
+				addPair(members, name, nullNode())
+			}
+			addPair(body, "hosts", members)
+		}
+		if len(grp.vars) > 0 {
+			vars := &yaml.Node{}
+			if err := vars.Encode(grp.vars); err != nil {
+				return nil, fmt.Errorf("failed to encode %s vars: %w", grp.name, err)
+			}
+			addPair(body, "vars", vars)
+		}
+		addPair(children, grp.name, body)
+	}
+
+	all := mappingNode()
+	addPair(all, "hosts", hosts)
+	addPair(all, "children", children)
+
+	if len(l.vars) > 0 {
+		vars := &yaml.Node{}
+		if err := vars.Encode(l.vars); err != nil {
+			return nil, fmt.Errorf("failed to encode inventory vars: %w", err)
+		}
+		addPair(all, "vars", vars)
+	}
+
+	doc := mappingNode()
+	addPair(doc, "all", all)
+
+	return encodeYAML(doc)
+}
+
+// yamlHostVars renders the variables of a host as a mapping node
+func yamlHostVars(h Host) (*yaml.Node, error) {
+	vars := mappingNode()
+	for _, v := range h.vars() {
+		value := &yaml.Node{}
+		if err := value.Encode(v.value); err != nil {
+			return nil, fmt.Errorf("failed to encode host %s var %s: %w", h.Name, v.key, err)
+		}
+		addPair(vars, v.key, value)
+	}
+	return vars, nil
+}
+
+// encodeYAML encodes a node with the two-space indentation of the Kubespray
+// samples
+func encodeYAML(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode inventory: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode inventory: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func mappingNode() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode}
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+}
+
+// nullNode renders as an empty value, as Ansible expects for group members
+func nullNode() *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+}
+
+func addPair(m *yaml.Node, key string, value *yaml.Node) {
+	m.Content = append(m.Content, scalarNode(key), value)
+}
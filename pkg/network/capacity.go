@@ -0,0 +1,140 @@
+package network
+
+import (
+	"fmt"
+	"net"
+)
+
+// maxShift caps 2^n results so IPv6 calculations do not overflow int
+const maxShift = 62
+
+// CapacityInput describes the pod network to plan for
+type CapacityInput struct {
+	PodSubnet    string
+	NodePrefix   int
+	MaxPods      int
+	Nodes        int
+	PlannedNodes int
+}
+
+// PrefixOption is an alternative per-node prefix size
+type PrefixOption struct {
+	NodePrefix  int
+	MaxNodes    int
+	PodsPerNode int
+}
+
+// CapacityPlan is the result of a pod CIDR capacity calculation
+type CapacityPlan struct {
+	PodSubnet    string
+	NodePrefix   int
+	MaxNodes     int
+	PodsPerNode  int
+	Nodes        int
+	PlannedNodes int
+	Warnings     []string
+	Alternatives []PrefixOption
+}
+
+// Fits reports whether the plan has no capacity warnings
+func (p *CapacityPlan) Fits() bool {
+	return len(p.Warnings) == 0
+}
+
+// PlanCapacity computes how many nodes and pods per node the pod subnet can
+// hold with the given per-node prefix, and suggests alternative prefixes when
+// the current one does not fit the inventory or its planned growth.
+func (c *Calculator) PlanCapacity(in CapacityInput) (*CapacityPlan, error) {
+	_, podNet, err := net.ParseCIDR(in.PodSubnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod subnet: %w", err)
+	}
+
+	podPrefix, bits := podNet.Mask.Size()
+	if in.NodePrefix <= podPrefix || in.NodePrefix > bits {
+		return nil, fmt.Errorf("node prefix /%d must be between /%d and /%d for pod subnet %s",
+			in.NodePrefix, podPrefix+1, bits, podNet.String())
+	}
+
+	plan := &CapacityPlan{
+		PodSubnet:    podNet.String(),
+		NodePrefix:   in.NodePrefix,
+		MaxNodes:     pow2(in.NodePrefix - podPrefix),
+		PodsPerNode:  podsPerNode(bits, in.NodePrefix, in.MaxPods),
+		Nodes:        in.Nodes,
+		PlannedNodes: in.PlannedNodes,
+	}
+
+	if in.Nodes > plan.MaxNodes {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"inventory has %d nodes but %s with /%d per node holds only %d",
+			in.Nodes, plan.PodSubnet, in.NodePrefix, plan.MaxNodes))
+	}
+	if in.PlannedNodes > plan.MaxNodes {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"planned growth to %d nodes exceeds capacity of %d nodes",
+			in.PlannedNodes, plan.MaxNodes))
+	}
+	if in.MaxPods > 0 && plan.PodsPerNode < in.MaxPods {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"/%d per node provides %d pod addresses, below max pods %d",
+			in.NodePrefix, plan.PodsPerNode, in.MaxPods))
+	}
+
+	if !plan.Fits() {
+		plan.Alternatives = alternativePrefixes(podPrefix, bits, in)
+	}
+
+	return plan, nil
+}
+
+// alternativePrefixes lists node prefixes that fit the required node count
+// while still providing enough pod addresses per node
+func alternativePrefixes(podPrefix, bits int, in CapacityInput) []PrefixOption {
+	required := in.Nodes
+	if in.PlannedNodes > required {
+		required = in.PlannedNodes
+	}
+
+	options := []PrefixOption{}
+	for prefix := podPrefix + 1; prefix <= bits-2; prefix++ {
+		if prefix == in.NodePrefix {
+			continue
+		}
+		opt := PrefixOption{
+			NodePrefix:  prefix,
+			MaxNodes:    pow2(prefix - podPrefix),
+			PodsPerNode: podsPerNode(bits, prefix, in.MaxPods),
+		}
+		if opt.MaxNodes < required {
+			continue
+		}
+		if in.MaxPods > 0 && opt.PodsPerNode < in.MaxPods {
+			continue
+		}
+		options = append(options, opt)
+	}
+
+	return options
+}
+
+// podsPerNode returns the usable pod addresses in a node block, capped by
+// the kubelet max pods limit when set
+func podsPerNode(bits, nodePrefix, maxPods int) int {
+	usable := pow2(bits-nodePrefix) - 2
+	if usable < 0 {
+		usable = 0
+	}
+	if maxPods > 0 && usable > maxPods {
+		return maxPods
+	}
+	return usable
+}
+
+// pow2 returns 2^n, capped at 2^maxShift
+func pow2(n int) int {
+	if n > maxShift {
+		n = maxShift
+	}
+	return 1 << uint(n)
+}
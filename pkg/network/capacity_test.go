@@ -0,0 +1,153 @@
+package network
+
+import (
+	"testing"
+)
+
+func TestPlanCapacity(t *testing.T) {
+	calc := NewCalculator()
+
+	tests := []struct {
+		name         string
+		input        CapacityInput
+		maxNodes     int
+		podsPerNode  int
+		fits         bool
+		alternatives bool
+		shouldErr    bool
+	}{
+		{
+			name: "Kubespray defaults",
+			input: CapacityInput{
+				PodSubnet:  "10.233.64.0/18",
+				NodePrefix: 24,
+				MaxPods:    110,
+				Nodes:      5,
+			},
+			maxNodes:    64,
+			podsPerNode: 110,
+			fits:        true,
+		},
+		{
+			name: "Inventory exceeds capacity",
+			input: CapacityInput{
+				PodSubnet:  "10.233.64.0/22",
+				NodePrefix: 24,
+				MaxPods:    110,
+				Nodes:      6,
+			},
+			maxNodes:    4,
+			podsPerNode: 110,
+			fits:        false,
+		},
+		{
+			name: "Planned growth exceeds capacity",
+			input: CapacityInput{
+				PodSubnet:    "10.233.64.0/18",
+				NodePrefix:   24,
+				MaxPods:      110,
+				Nodes:        10,
+				PlannedNodes: 100,
+			},
+			maxNodes:     64,
+			podsPerNode:  110,
+			fits:         false,
+			alternatives: true,
+		},
+		{
+			name: "Node block smaller than max pods",
+			input: CapacityInput{
+				PodSubnet:  "10.233.64.0/18",
+				NodePrefix: 26,
+				MaxPods:    110,
+				Nodes:      3,
+			},
+			maxNodes:     256,
+			podsPerNode:  62,
+			fits:         false,
+			alternatives: true,
+		},
+		{
+			name: "IPv6 pod subnet",
+			input: CapacityInput{
+				PodSubnet:  "fd85:ee78:d8a6:8607::1:0000/112",
+				NodePrefix: 120,
+				MaxPods:    110,
+				Nodes:      3,
+			},
+			maxNodes:    256,
+			podsPerNode: 110,
+			fits:        true,
+		},
+		{
+			name: "Node prefix not inside pod subnet",
+			input: CapacityInput{
+				PodSubnet:  "10.233.64.0/18",
+				NodePrefix: 16,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "Invalid pod subnet",
+			input: CapacityInput{
+				PodSubnet:  "invalid-cidr",
+				NodePrefix: 24,
+			},
+			shouldErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := calc.PlanCapacity(tt.input)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Error("Expected error but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if plan.MaxNodes != tt.maxNodes {
+				t.Errorf("Expected %d max nodes, got %d", tt.maxNodes, plan.MaxNodes)
+			}
+			if plan.PodsPerNode != tt.podsPerNode {
+				t.Errorf("Expected %d pods per node, got %d", tt.podsPerNode, plan.PodsPerNode)
+			}
+			if plan.Fits() != tt.fits {
+				t.Errorf("Expected Fits=%v, got %v (warnings: %v)", tt.fits, plan.Fits(), plan.Warnings)
+			}
+			if tt.alternatives && len(plan.Alternatives) == 0 {
+				t.Error("Expected alternative prefixes but got none")
+			}
+		})
+	}
+}
+
+func TestPlanCapacityAlternativesFit(t *testing.T) {
+	calc := NewCalculator()
+
+	plan, err := calc.PlanCapacity(CapacityInput{
+		PodSubnet:    "10.233.64.0/18",
+		NodePrefix:   24,
+		MaxPods:      110,
+		Nodes:        10,
+		PlannedNodes: 100,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, opt := range plan.Alternatives {
+		if opt.MaxNodes < 100 {
+			t.Errorf("Alternative /%d holds only %d nodes", opt.NodePrefix, opt.MaxNodes)
+		}
+		if opt.PodsPerNode < 110 {
+			t.Errorf("Alternative /%d provides only %d pods per node", opt.NodePrefix, opt.PodsPerNode)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IP families
+const (
+	IPv4 = "ipv4"
+	IPv6 = "ipv6"
+)
+
+// Port is a port a CNI plugin needs open between nodes
+type Port struct {
+	Number      int
+	Protocol    string
+	Description string
+}
+
+// String formats the port as "number/protocol"
+func (p Port) String() string {
+	return fmt.Sprintf("%d/%s", p.Number, p.Protocol)
+}
+
+// CNIPlugin describes the requirements of a Kubespray network plugin
+type CNIPlugin struct {
+	Name string
+	// Ports must be reachable between all nodes
+	Ports []Port
+	// EncapOverhead is the per-packet encapsulation overhead in bytes
+	EncapOverhead int
+	// MinKernel is the oldest supported kernel version
+	MinKernel string
+	// KernelModules must be available on every node
+	KernelModules []string
+	// IPFamilies lists the supported pod and service address families
+	IPFamilies []string
+}
+
+// cniPlugins are the network plugins Kubespray can deploy, with the settings
+// of its default backend for each
+var cniPlugins = map[string]CNIPlugin{
+	"calico": {
+		Name: "calico",
+		Ports: []Port{
+			{179, "tcp", "BGP"},
+			{4789, "udp", "VXLAN"},
+			{5473, "tcp", "Typha"},
+		},
+		EncapOverhead: 50,
+		MinKernel:     "3.10",
+		KernelModules: []string{"ip_set", "vxlan"},
+		IPFamilies:    []string{IPv4, IPv6},
+	},
+	"cilium": {
+		Name: "cilium",
+		Ports: []Port{
+			{4240, "tcp", "health checks"},
+			{4244, "tcp", "Hubble"},
+			{8472, "udp", "VXLAN"},
+		},
+		EncapOverhead: 50,
+		MinKernel:     "4.19.57",
+		IPFamilies:    []string{IPv4, IPv6},
+	},
+	"flannel": {
+		Name: "flannel",
+		Ports: []Port{
+			{8472, "udp", "VXLAN"},
+		},
+		EncapOverhead: 50,
+		MinKernel:     "3.10",
+		KernelModules: []string{"vxlan"},
+		IPFamilies:    []string{IPv4, IPv6},
+	},
+	"kube-ovn": {
+		Name: "kube-ovn",
+		Ports: []Port{
+			{6081, "udp", "Geneve"},
+			{6641, "tcp", "OVN northbound"},
+			{6642, "tcp", "OVN southbound"},
+			{6643, "tcp", "OVN northbound raft"},
+			{6644, "tcp", "OVN southbound raft"},
+		},
+		EncapOverhead: 58,
+		MinKernel:     "3.10",
+		KernelModules: []string{"openvswitch", "geneve"},
+		IPFamilies:    []string{IPv4, IPv6},
+	},
+	"kube-router": {
+		Name: "kube-router",
+		Ports: []Port{
+			{179, "tcp", "BGP"},
+			{20244, "tcp", "health checks"},
+		},
+		EncapOverhead: 0,
+		MinKernel:     "3.10",
+		KernelModules: []string{"ip_set", "ipip"},
+		IPFamilies:    []string{IPv4, IPv6},
+	},
+	"weave": {
+		Name: "weave",
+		Ports: []Port{
+			{6783, "tcp", "control"},
+			{6783, "udp", "fastdp"},
+			{6784, "udp", "fastdp"},
+		},
+		EncapOverhead: 58,
+		MinKernel:     "3.8",
+		KernelModules: []string{"openvswitch", "vxlan"},
+		IPFamilies:    []string{IPv4},
+	},
+	"macvlan": {
+		Name:          "macvlan",
+		EncapOverhead: 0,
+		MinKernel:     "3.10",
+		KernelModules: []string{"macvlan"},
+		IPFamilies:    []string{IPv4},
+	},
+}
+
+// LookupCNI returns the model for a network plugin
+func LookupCNI(name string) (CNIPlugin, error) {
+	plugin, ok := cniPlugins[name]
+	if !ok {
+		return CNIPlugin{}, fmt.Errorf("unsupported network plugin %q (supported: %s)",
+			name, strings.Join(SupportedCNIs(), ", "))
+	}
+	return plugin, nil
+}
+
+// SupportedCNIs returns the supported plugin names in sorted order
+func SupportedCNIs() []string {
+	names := make([]string, 0, len(cniPlugins))
+	for name := range cniPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SupportsFamily reports whether the plugin handles the IP family
+func (p CNIPlugin) SupportsFamily(family string) bool {
+	for _, f := range p.IPFamilies {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// PodMTU returns the pod interface MTU for a node interface MTU
+func (p CNIPlugin) PodMTU(nodeMTU int) int {
+	return nodeMTU - p.EncapOverhead
+}
+
+// KernelSupported reports whether a kernel release such as
+// "5.15.0-91-generic" meets the plugin's minimum version
+func (p CNIPlugin) KernelSupported(release string) bool {
+	if p.MinKernel == "" {
+		return true
+	}
+	return compareVersions(release, p.MinKernel) >= 0
+}
+
+// ValidateCNI checks the network plugin supports the address families of the
+// pod and service subnets and leaves a usable pod MTU
+func (c *Calculator) ValidateCNI(plugin, podSubnet, serviceSubnet string, nodeMTU int) error {
+	p, err := LookupCNI(plugin)
+	if err != nil {
+		return err
+	}
+
+	for _, cidr := range []string{podSubnet, serviceSubnet} {
+		family, err := cidrFamily(cidr)
+		if err != nil {
+			return err
+		}
+		if !p.SupportsFamily(family) {
+			return fmt.Errorf("network plugin %s does not support %s subnet %s", p.Name, family, cidr)
+		}
+	}
+
+	if nodeMTU > 0 {
+		// IPv6 requires a link MTU of at least 1280, IPv4 576
+		minMTU := 576
+		if family, _ := cidrFamily(podSubnet); family == IPv6 {
+			minMTU = 1280
+		}
+		if mtu := p.PodMTU(nodeMTU); mtu < minMTU {
+			return fmt.Errorf("pod MTU %d with %s overhead %d is below the minimum %d",
+				mtu, p.Name, p.EncapOverhead, minMTU)
+		}
+	}
+
+	return nil
+}
+
+// cidrFamily returns the IP family of a CIDR
+func cidrFamily(cidr string) (string, error) {
+	ip, _, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR %s: %w", cidr, err)
+	}
+	if ip.To4() != nil {
+		return IPv4, nil
+	}
+	return IPv6, nil
+}
+
+// compareVersions compares the leading numeric components of two dotted
+// versions, ignoring any suffix such as "-91-generic"
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionParts extracts the numeric components of a version string
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexFunc(v, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		v = v[:i]
+	}
+
+	parts := []int{}
+	for _, s := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
@@ -0,0 +1,100 @@
+package network
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+func TestLookupCNI(t *testing.T) {
+	for _, name := range []string{"calico", "cilium", "flannel", "kube-ovn", "weave"} {
+		plugin, err := LookupCNI(name)
+		if err != nil {
+			t.Errorf("Expected %s to be supported: %v", name, err)
+			continue
+		}
+		if plugin.Name != name {
+			t.Errorf("Expected plugin name %s, got %s", name, plugin.Name)
+		}
+		if len(plugin.IPFamilies) == 0 {
+			t.Errorf("Plugin %s declares no IP families", name)
+		}
+	}
+
+	if _, err := LookupCNI("contiv"); err == nil {
+		t.Error("Expected error for unsupported plugin")
+	}
+}
+
+func TestConfigNetworkPlugins(t *testing.T) {
+	plugins := append([]string{}, config.NetworkPlugins...)
+	sort.Strings(plugins)
+	if !reflect.DeepEqual(plugins, SupportedCNIs()) {
+		t.Errorf("config.NetworkPlugins %v differs from the modelled plugins %v", plugins, SupportedCNIs())
+	}
+}
+
+func TestValidateCNI(t *testing.T) {
+	calc := NewCalculator()
+
+	tests := []struct {
+		name          string
+		plugin        string
+		podSubnet     string
+		serviceSubnet string
+		mtu           int
+		shouldErr     bool
+	}{
+		{"Calico defaults", "calico", "10.233.64.0/18", "10.233.0.0/18", 1500, false},
+		{"Cilium IPv6", "cilium", "fd85:ee78:d8a6:8607::1:0/112", "fd85:ee78:d8a6:8607::1000/116", 1500, false},
+		{"Weave IPv6 unsupported", "weave", "fd85:ee78:d8a6:8607::1:0/112", "10.233.0.0/18", 1500, true},
+		{"Unknown plugin", "contiv", "10.233.64.0/18", "10.233.0.0/18", 1500, true},
+		{"Invalid pod subnet", "calico", "invalid-cidr", "10.233.0.0/18", 1500, true},
+		{"IPv6 MTU too small", "kube-ovn", "fd85:ee78:d8a6:8607::1:0/112", "fd85:ee78:d8a6:8607::1000/116", 1300, true},
+		{"MTU not checked", "kube-ovn", "fd85:ee78:d8a6:8607::1:0/112", "fd85:ee78:d8a6:8607::1000/116", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := calc.ValidateCNI(tt.plugin, tt.podSubnet, tt.serviceSubnet, tt.mtu)
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error but got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestKernelSupported(t *testing.T) {
+	cilium, _ := LookupCNI("cilium")
+
+	tests := []struct {
+		release   string
+		supported bool
+	}{
+		{"5.15.0-91-generic", true},
+		{"4.19.57", true},
+		{"4.19.56-1.el7", false},
+		{"4.18.0-513.el8.x86_64", false},
+		{"6.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.release, func(t *testing.T) {
+			if got := cilium.KernelSupported(tt.release); got != tt.supported {
+				t.Errorf("Expected KernelSupported(%s)=%v, got %v", tt.release, tt.supported, got)
+			}
+		})
+	}
+}
+
+func TestPodMTU(t *testing.T) {
+	calico, _ := LookupCNI("calico")
+	if mtu := calico.PodMTU(1500); mtu != 1450 {
+		t.Errorf("Expected pod MTU 1450, got %d", mtu)
+	}
+}
@@ -0,0 +1,125 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// API endpoint modes
+const (
+	EndpointLocalhost = "localhost"
+	EndpointKubeVIP   = "kube-vip"
+	EndpointExternal  = "external"
+)
+
+// ValidateAPIEndpoint checks the API endpoint configuration against the
+// control plane hosts. A kube-vip VIP must be a free address in the node
+// subnet shared by every control plane host.
+func (c *Calculator) ValidateAPIEndpoint(ep config.APIEndpointConfig, masters []string) error {
+	if ep.Port < 1 || ep.Port > 65535 {
+		return fmt.Errorf("invalid API endpoint port %d", ep.Port)
+	}
+
+	switch ep.Mode {
+	case EndpointLocalhost:
+		if ep.LocalhostType != "nginx" && ep.LocalhostType != "haproxy" {
+			return fmt.Errorf("localhost load balancer type must be nginx or haproxy, got %q", ep.LocalhostType)
+		}
+		return nil
+
+	case EndpointExternal:
+		if ep.Address == "" && ep.Domain == "" {
+			return fmt.Errorf("external API endpoint requires an address or domain")
+		}
+		if ep.Address != "" && net.ParseIP(ep.Address) == nil {
+			return fmt.Errorf("invalid external load balancer address %s", ep.Address)
+		}
+		return nil
+
+	case EndpointKubeVIP:
+		return c.validateVIP(ep, masters)
+
+	default:
+		return fmt.Errorf("unknown API endpoint mode %q (expected %s, %s or %s)",
+			ep.Mode, EndpointLocalhost, EndpointKubeVIP, EndpointExternal)
+	}
+}
+
+// validateVIP checks a kube-vip address is usable on the node subnet
+func (c *Calculator) validateVIP(ep config.APIEndpointConfig, masters []string) error {
+	vip := net.ParseIP(ep.Address)
+	if vip == nil {
+		return fmt.Errorf("kube-vip requires a valid VIP address, got %q", ep.Address)
+	}
+
+	for _, m := range masters {
+		if vip.Equal(net.ParseIP(m)) {
+			return fmt.Errorf("VIP %s is already assigned to control plane host %s", vip, m)
+		}
+	}
+
+	if ep.Subnet == "" {
+		return nil
+	}
+
+	_, subnet, err := net.ParseCIDR(ep.Subnet)
+	if err != nil {
+		return fmt.Errorf("invalid node subnet: %w", err)
+	}
+	if !HostRange(subnet).Contains(vip) {
+		return fmt.Errorf("VIP %s is not a host address in node subnet %s", vip, subnet)
+	}
+	for _, m := range masters {
+		if !subnet.Contains(net.ParseIP(m)) {
+			return fmt.Errorf("control plane host %s is outside node subnet %s; kube-vip ARP mode needs a shared L2 segment", m, subnet)
+		}
+	}
+
+	return nil
+}
+
+// APIEndpointVars returns the Kubespray variables for the API endpoint mode
+func APIEndpointVars(ep config.APIEndpointConfig) map[string]interface{} {
+	vars := map[string]interface{}{}
+
+	switch ep.Mode {
+	case EndpointLocalhost:
+		vars["loadbalancer_apiserver_localhost"] = true
+		vars["loadbalancer_apiserver_type"] = ep.LocalhostType
+		vars["loadbalancer_apiserver_port"] = ep.Port
+
+	case EndpointExternal:
+		vars["loadbalancer_apiserver_localhost"] = false
+		if ep.Address != "" {
+			vars["loadbalancer_apiserver"] = map[string]interface{}{
+				"address": ep.Address,
+				"port":    ep.Port,
+			}
+		}
+		if ep.Domain != "" {
+			vars["apiserver_loadbalancer_domain_name"] = ep.Domain
+		}
+
+	case EndpointKubeVIP:
+		vars["loadbalancer_apiserver_localhost"] = false
+		vars["kube_proxy_strict_arp"] = true
+		vars["kube_vip_enabled"] = true
+		vars["kube_vip_controlplane_enabled"] = true
+		vars["kube_vip_arp_enabled"] = true
+		vars["kube_vip_address"] = ep.Address
+		if ep.Interface != "" {
+			vars["kube_vip_interface"] = ep.Interface
+		}
+		vars["loadbalancer_apiserver"] = map[string]interface{}{
+			"address": ep.Address,
+			"port":    ep.Port,
+		}
+		if ep.Domain != "" {
+			vars["apiserver_loadbalancer_domain_name"] = ep.Domain
+		}
+	}
+
+	return vars
+}
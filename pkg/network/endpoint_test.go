@@ -0,0 +1,112 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+func TestValidateAPIEndpoint(t *testing.T) {
+	calc := NewCalculator()
+	masters := []string{"192.168.1.10", "192.168.1.11", "192.168.1.12"}
+
+	tests := []struct {
+		name      string
+		endpoint  config.APIEndpointConfig
+		shouldErr bool
+	}{
+		{
+			name:     "Localhost nginx",
+			endpoint: config.APIEndpointConfig{Mode: EndpointLocalhost, LocalhostType: "nginx", Port: 6443},
+		},
+		{
+			name:      "Localhost unknown proxy",
+			endpoint:  config.APIEndpointConfig{Mode: EndpointLocalhost, LocalhostType: "envoy", Port: 6443},
+			shouldErr: true,
+		},
+		{
+			name:     "External by domain",
+			endpoint: config.APIEndpointConfig{Mode: EndpointExternal, Domain: "api.example.com", Port: 443},
+		},
+		{
+			name:      "External without address",
+			endpoint:  config.APIEndpointConfig{Mode: EndpointExternal, Port: 6443},
+			shouldErr: true,
+		},
+		{
+			name:     "kube-vip in node subnet",
+			endpoint: config.APIEndpointConfig{Mode: EndpointKubeVIP, Address: "192.168.1.100", Subnet: "192.168.1.0/24", Port: 6443},
+		},
+		{
+			name:      "kube-vip outside node subnet",
+			endpoint:  config.APIEndpointConfig{Mode: EndpointKubeVIP, Address: "192.168.2.100", Subnet: "192.168.1.0/24", Port: 6443},
+			shouldErr: true,
+		},
+		{
+			name:      "kube-vip on broadcast address",
+			endpoint:  config.APIEndpointConfig{Mode: EndpointKubeVIP, Address: "192.168.1.255", Subnet: "192.168.1.0/24", Port: 6443},
+			shouldErr: true,
+		},
+		{
+			name:      "kube-vip clashes with master",
+			endpoint:  config.APIEndpointConfig{Mode: EndpointKubeVIP, Address: "192.168.1.11", Port: 6443},
+			shouldErr: true,
+		},
+		{
+			name:      "kube-vip masters outside subnet",
+			endpoint:  config.APIEndpointConfig{Mode: EndpointKubeVIP, Address: "192.168.1.100", Subnet: "192.168.1.0/28", Port: 6443},
+			shouldErr: true,
+		},
+		{
+			name:      "Unknown mode",
+			endpoint:  config.APIEndpointConfig{Mode: "dns-round-robin", Port: 6443},
+			shouldErr: true,
+		},
+		{
+			name:      "Invalid port",
+			endpoint:  config.APIEndpointConfig{Mode: EndpointLocalhost, LocalhostType: "nginx", Port: 0},
+			shouldErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := calc.ValidateAPIEndpoint(tt.endpoint, masters)
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error but got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestAPIEndpointVars(t *testing.T) {
+	vars := APIEndpointVars(config.APIEndpointConfig{
+		Mode:      EndpointKubeVIP,
+		Address:   "192.168.1.100",
+		Interface: "eth0",
+		Port:      6443,
+	})
+
+	if vars["kube_vip_enabled"] != true {
+		t.Error("Expected kube_vip_enabled to be true")
+	}
+	if vars["kube_vip_address"] != "192.168.1.100" {
+		t.Errorf("Expected kube_vip_address 192.168.1.100, got %v", vars["kube_vip_address"])
+	}
+	if vars["loadbalancer_apiserver_localhost"] != false {
+		t.Error("Expected loadbalancer_apiserver_localhost to be false")
+	}
+
+	lb, ok := vars["loadbalancer_apiserver"].(map[string]interface{})
+	if !ok || lb["address"] != "192.168.1.100" || lb["port"] != 6443 {
+		t.Errorf("Unexpected loadbalancer_apiserver: %v", vars["loadbalancer_apiserver"])
+	}
+
+	local := APIEndpointVars(config.APIEndpointConfig{Mode: EndpointLocalhost, LocalhostType: "haproxy", Port: 6443})
+	if local["loadbalancer_apiserver_type"] != "haproxy" {
+		t.Errorf("Expected haproxy localhost proxy, got %v", local["loadbalancer_apiserver_type"])
+	}
+}
@@ -0,0 +1,433 @@
+package network
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Range is an inclusive span of IP addresses of a single family
+type Range struct {
+	Start net.IP
+	End   net.IP
+}
+
+// ParseRange parses a single IP, a CIDR or a "start-end" address range
+func ParseRange(s string) (Range, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid CIDR %s: %w", s, err)
+		}
+		return CIDRRange(ipNet), nil
+	}
+
+	if start, end, ok := strings.Cut(s, "-"); ok {
+		r := Range{Start: normalizeIP(net.ParseIP(strings.TrimSpace(start))), End: normalizeIP(net.ParseIP(strings.TrimSpace(end)))}
+		if r.Start == nil || r.End == nil {
+			return Range{}, fmt.Errorf("invalid IP range %s", s)
+		}
+		if len(r.Start) != len(r.End) {
+			return Range{}, fmt.Errorf("IP range %s mixes address families", s)
+		}
+		if compareIP(r.Start, r.End) > 0 {
+			return Range{}, fmt.Errorf("IP range %s starts after it ends", s)
+		}
+		return r, nil
+	}
+
+	ip := normalizeIP(net.ParseIP(s))
+	if ip == nil {
+		return Range{}, fmt.Errorf("invalid IP address %s", s)
+	}
+	return Range{Start: ip, End: ip}, nil
+}
+
+// CIDRRange returns the range covering every address of a network
+func CIDRRange(n *net.IPNet) Range {
+	return Range{Start: normalizeIP(n.IP.Mask(n.Mask)), End: Broadcast(n)}
+}
+
+// String formats the range as "start-end", or a single address
+func (r Range) String() string {
+	if r.Start.Equal(r.End) {
+		return r.Start.String()
+	}
+	return fmt.Sprintf("%s-%s", r.Start, r.End)
+}
+
+// Contains reports whether ip is inside the range
+func (r Range) Contains(ip net.IP) bool {
+	ip = normalizeIP(ip)
+	if ip == nil || len(ip) != len(r.Start) {
+		return false
+	}
+	return compareIP(ip, r.Start) >= 0 && compareIP(ip, r.End) <= 0
+}
+
+// Overlaps reports whether two ranges share any address
+func (r Range) Overlaps(o Range) bool {
+	if len(r.Start) != len(o.Start) {
+		return false
+	}
+	return compareIP(r.Start, o.End) <= 0 && compareIP(o.Start, r.End) <= 0
+}
+
+// Size returns the number of addresses in the range, saturating at MaxUint64
+func (r Range) Size() uint64 {
+	size := new(big.Int).Sub(ipToInt(r.End), ipToInt(r.Start))
+	size.Add(size, big.NewInt(1))
+	return saturate(size)
+}
+
+// CIDRs returns the minimal list of networks covering the range
+func (r Range) CIDRs() []*net.IPNet {
+	bits := len(r.Start) * 8
+	start := ipToInt(r.Start)
+	end := ipToInt(r.End)
+	one := big.NewInt(1)
+
+	nets := []*net.IPNet{}
+	for start.Cmp(end) <= 0 {
+		// Largest block aligned on start that does not pass end
+		size := 0
+		for size < bits && start.Bit(size) == 0 {
+			size++
+		}
+		for size > 0 {
+			last := new(big.Int).Add(start, new(big.Int).Lsh(one, uint(size)))
+			last.Sub(last, one)
+			if last.Cmp(end) <= 0 {
+				break
+			}
+			size--
+		}
+
+		nets = append(nets, &net.IPNet{
+			IP:   intToIP(start, bits),
+			Mask: net.CIDRMask(bits-size, bits),
+		})
+		start = new(big.Int).Add(start, new(big.Int).Lsh(one, uint(size)))
+	}
+
+	return nets
+}
+
+// NextIP returns the address following ip, or nil on overflow
+func NextIP(ip net.IP) net.IP {
+	return AddIP(ip, 1)
+}
+
+// PrevIP returns the address preceding ip, or nil on underflow
+func PrevIP(ip net.IP) net.IP {
+	return AddIP(ip, -1)
+}
+
+// AddIP offsets ip by n addresses, returning nil when the result leaves the
+// address family
+func AddIP(ip net.IP, n int64) net.IP {
+	ip = normalizeIP(ip)
+	if ip == nil {
+		return nil
+	}
+	bits := len(ip) * 8
+
+	v := new(big.Int).Add(ipToInt(ip), big.NewInt(n))
+	if v.Sign() < 0 || v.BitLen() > bits {
+		return nil
+	}
+	return intToIP(v, bits)
+}
+
+// Nth returns the i-th address of a network. Negative indexes count back
+// from the last address, so -1 is the broadcast address.
+func Nth(n *net.IPNet, i int64) (net.IP, error) {
+	r := CIDRRange(n)
+
+	var ip net.IP
+	if i >= 0 {
+		ip = AddIP(r.Start, i)
+	} else {
+		ip = AddIP(r.End, i+1)
+	}
+	if ip == nil || !r.Contains(ip) {
+		return nil, fmt.Errorf("index %d is outside %s", i, n.String())
+	}
+	return ip, nil
+}
+
+// Broadcast returns the last address of a network
+func Broadcast(n *net.IPNet) net.IP {
+	ip := normalizeIP(n.IP)
+	mask := n.Mask
+	if len(mask) != len(ip) {
+		mask = mask[len(mask)-len(ip):]
+	}
+
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		last[i] = ip[i] | ^mask[i]
+	}
+	return last
+}
+
+// HostCount returns the number of assignable host addresses in a network.
+// IPv4 networks larger than /31 exclude the network and broadcast addresses.
+// The result saturates at MaxUint64 for large IPv6 networks.
+func HostCount(n *net.IPNet) uint64 {
+	ones, bits := n.Mask.Size()
+	if bits-ones >= 64 {
+		return math.MaxUint64
+	}
+
+	total := uint64(1) << uint(bits-ones)
+	if bits == 32 && ones < 31 {
+		return total - 2
+	}
+	return total
+}
+
+// HostRange returns the range of assignable host addresses in a network
+func HostRange(n *net.IPNet) Range {
+	r := CIDRRange(n)
+	ones, bits := n.Mask.Size()
+	if bits == 32 && ones < 31 {
+		r.Start = NextIP(r.Start)
+		r.End = PrevIP(r.End)
+	}
+	return r
+}
+
+// Split divides a network into count equally sized subnets, using the
+// smallest prefix that yields at least count subnets
+func Split(n *net.IPNet, count int) ([]*net.IPNet, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("subnet count must be positive, got %d", count)
+	}
+
+	ones, bits := n.Mask.Size()
+	extra := 0
+	for (1 << uint(extra)) < count {
+		extra++
+	}
+	if ones+extra > bits {
+		return nil, fmt.Errorf("cannot split %s into %d subnets", n.String(), count)
+	}
+
+	prefix := ones + extra
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix))
+	start := ipToInt(n.IP.Mask(n.Mask))
+
+	subnets := make([]*net.IPNet, 0, count)
+	for i := 0; i < count; i++ {
+		subnets = append(subnets, &net.IPNet{
+			IP:   intToIP(start, bits),
+			Mask: net.CIDRMask(prefix, bits),
+		})
+		start = new(big.Int).Add(start, step)
+	}
+
+	return subnets, nil
+}
+
+// Summarize aggregates networks into the minimal set of CIDRs covering the
+// same addresses, merging overlapping and adjacent networks
+func Summarize(nets []*net.IPNet) []*net.IPNet {
+	ranges := make([]Range, 0, len(nets))
+	for _, n := range nets {
+		ranges = append(ranges, CIDRRange(n))
+	}
+
+	result := []*net.IPNet{}
+	for _, r := range mergeRanges(ranges) {
+		result = append(result, r.CIDRs()...)
+	}
+	return result
+}
+
+// Exclude removes the excluded ranges from base and returns what remains,
+// in address order
+func Exclude(base Range, excluded []Range) []Range {
+	remaining := []Range{base}
+
+	for _, ex := range mergeRanges(excluded) {
+		next := []Range{}
+		for _, r := range remaining {
+			if !r.Overlaps(ex) {
+				next = append(next, r)
+				continue
+			}
+			if compareIP(r.Start, ex.Start) < 0 {
+				next = append(next, Range{Start: r.Start, End: PrevIP(ex.Start)})
+			}
+			if compareIP(r.End, ex.End) > 0 {
+				next = append(next, Range{Start: NextIP(ex.End), End: r.End})
+			}
+		}
+		remaining = next
+	}
+
+	return remaining
+}
+
+// KubernetesServiceIP returns the in-cluster API service address, the first
+// host of the service subnet (kube_apiserver_ip)
+func (c *Calculator) KubernetesServiceIP(serviceSubnet string) (string, error) {
+	return nthInCIDR(serviceSubnet, 1)
+}
+
+// ClusterDNSIP returns the cluster DNS service address, the third host of the
+// service subnet (skydns_server)
+func (c *Calculator) ClusterDNSIP(serviceSubnet string) (string, error) {
+	return nthInCIDR(serviceSubnet, 3)
+}
+
+// AllocateVIP picks the highest host address in nodeSubnet that is not
+// covered by any of the used addresses or ranges
+func (c *Calculator) AllocateVIP(nodeSubnet string, used []string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(nodeSubnet)
+	if err != nil {
+		return "", fmt.Errorf("invalid node subnet: %w", err)
+	}
+
+	excluded, err := parseRanges(used)
+	if err != nil {
+		return "", err
+	}
+
+	free := Exclude(HostRange(ipNet), excluded)
+	if len(free) == 0 {
+		return "", fmt.Errorf("no free address in %s", ipNet.String())
+	}
+	return free[len(free)-1].End.String(), nil
+}
+
+// AllocateRange returns the first contiguous span of size free host
+// addresses in cidr, skipping the excluded addresses, ranges and networks
+func (c *Calculator) AllocateRange(cidr string, size int, exclude []string) (Range, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid CIDR: %w", err)
+	}
+	if size < 1 {
+		return Range{}, fmt.Errorf("range size must be positive, got %d", size)
+	}
+
+	excluded, err := parseRanges(exclude)
+	if err != nil {
+		return Range{}, err
+	}
+
+	r, ok := firstFree(HostRange(ipNet), size, excluded)
+	if !ok {
+		return Range{}, fmt.Errorf("no free range of %d addresses in %s", size, ipNet.String())
+	}
+	return r, nil
+}
+
+// firstFree returns the first span of size addresses in base that does not
+// overlap the excluded ranges
+func firstFree(base Range, size int, excluded []Range) (Range, bool) {
+	for _, free := range Exclude(base, excluded) {
+		if free.Size() >= uint64(size) {
+			return Range{Start: free.Start, End: AddIP(free.Start, int64(size-1))}, true
+		}
+	}
+	return Range{}, false
+}
+
+// nthInCIDR returns the i-th address of a CIDR as a string
+func nthInCIDR(cidr string, i int64) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR: %w", err)
+	}
+	ip, err := Nth(ipNet, i)
+	if err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+// parseRanges parses a list of addresses, CIDRs or ranges
+func parseRanges(values []string) ([]Range, error) {
+	ranges := make([]Range, 0, len(values))
+	for _, v := range values {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		r, err := ParseRange(v)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// mergeRanges sorts ranges and merges overlapping or adjacent ones
+func mergeRanges(ranges []Range) []Range {
+	sorted := make([]Range, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].Start) != len(sorted[j].Start) {
+			return len(sorted[i].Start) < len(sorted[j].Start)
+		}
+		return compareIP(sorted[i].Start, sorted[j].Start) < 0
+	})
+
+	merged := []Range{}
+	for _, r := range sorted {
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			if len(last.End) == len(r.Start) {
+				next := NextIP(last.End)
+				if next == nil || compareIP(r.Start, next) <= 0 {
+					if compareIP(r.End, last.End) > 0 {
+						last.End = r.End
+					}
+					continue
+				}
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// normalizeIP returns the 4-byte form of IPv4 addresses and the 16-byte form
+// of IPv6 addresses
+func normalizeIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+// compareIP compares two addresses of the same family
+func compareIP(a, b net.IP) int {
+	return ipToInt(a).Cmp(ipToInt(b))
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	return new(big.Int).SetBytes(normalizeIP(ip))
+}
+
+func intToIP(v *big.Int, bits int) net.IP {
+	ip := make(net.IP, bits/8)
+	v.FillBytes(ip)
+	return ip
+}
+
+// saturate converts a big integer to uint64, capping at MaxUint64
+func saturate(v *big.Int) uint64 {
+	if !v.IsUint64() {
+		return math.MaxUint64
+	}
+	return v.Uint64()
+}
@@ -0,0 +1,250 @@
+package network
+
+import (
+	"math"
+	"net"
+	"testing"
+)
+
+func mustCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("Failed to parse CIDR %s: %v", cidr, err)
+	}
+	return ipNet
+}
+
+func TestNextPrevIP(t *testing.T) {
+	if got := NextIP(net.ParseIP("10.0.0.255")).String(); got != "10.0.1.0" {
+		t.Errorf("Expected 10.0.1.0, got %s", got)
+	}
+	if got := PrevIP(net.ParseIP("10.0.1.0")).String(); got != "10.0.0.255" {
+		t.Errorf("Expected 10.0.0.255, got %s", got)
+	}
+	if got := NextIP(net.ParseIP("255.255.255.255")); got != nil {
+		t.Errorf("Expected nil on IPv4 overflow, got %s", got)
+	}
+	if got := PrevIP(net.ParseIP("::")); got != nil {
+		t.Errorf("Expected nil on IPv6 underflow, got %s", got)
+	}
+}
+
+func TestNth(t *testing.T) {
+	tests := []struct {
+		name      string
+		cidr      string
+		index     int64
+		expected  string
+		shouldErr bool
+	}{
+		{"First address", "10.233.0.0/18", 0, "10.233.0.0", false},
+		{"Third address", "10.233.0.0/18", 3, "10.233.0.3", false},
+		{"Last address", "10.233.0.0/18", -1, "10.233.63.255", false},
+		{"IPv6 address", "fd85:ee78:d8a6:8607::1000/116", 10, "fd85:ee78:d8a6:8607::100a", false},
+		{"Index past end", "192.168.1.0/30", 4, "", true},
+		{"Negative past start", "192.168.1.0/30", -5, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := Nth(mustCIDR(t, tt.cidr), tt.index)
+
+			if tt.shouldErr {
+				if err == nil {
+					t.Errorf("Expected error but got %s", ip)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ip.String() != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestBroadcastAndHostCount(t *testing.T) {
+	tests := []struct {
+		cidr      string
+		broadcast string
+		hosts     uint64
+	}{
+		{"192.168.1.0/24", "192.168.1.255", 254},
+		{"10.0.0.0/31", "10.0.0.1", 2},
+		{"10.0.0.5/32", "10.0.0.5", 1},
+		{"fd00::/120", "fd00::ff", 256},
+		{"fd00::/48", "fd00::ffff:ffff:ffff:ffff:ffff", math.MaxUint64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			n := mustCIDR(t, tt.cidr)
+			if got := Broadcast(n).String(); got != tt.broadcast {
+				t.Errorf("Expected broadcast %s, got %s", tt.broadcast, got)
+			}
+			if got := HostCount(n); got != tt.hosts {
+				t.Errorf("Expected %d hosts, got %d", tt.hosts, got)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	subnets, err := Split(mustCIDR(t, "10.0.0.0/24"), 3)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	expected := []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26"}
+	if len(subnets) != len(expected) {
+		t.Fatalf("Expected %d subnets, got %d", len(expected), len(subnets))
+	}
+	for i, subnet := range subnets {
+		if subnet.String() != expected[i] {
+			t.Errorf("Subnet %d: expected %s, got %s", i, expected[i], subnet)
+		}
+	}
+
+	v6, err := Split(mustCIDR(t, "fd00::/64"), 2)
+	if err != nil {
+		t.Fatalf("IPv6 split failed: %v", err)
+	}
+	if v6[1].String() != "fd00::8000:0:0:0/65" {
+		t.Errorf("Expected fd00::8000:0:0:0/65, got %s", v6[1])
+	}
+
+	if _, err := Split(mustCIDR(t, "10.0.0.0/31"), 4); err == nil {
+		t.Error("Expected error splitting /31 into 4 subnets")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	nets := []*net.IPNet{
+		mustCIDR(t, "10.0.1.0/24"),
+		mustCIDR(t, "10.0.0.0/24"),
+		mustCIDR(t, "10.0.0.128/25"),
+		mustCIDR(t, "10.0.2.0/24"),
+	}
+
+	summary := Summarize(nets)
+	expected := []string{"10.0.0.0/23", "10.0.2.0/24"}
+	if len(summary) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, summary)
+	}
+	for i, n := range summary {
+		if n.String() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], n)
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	base, _ := ParseRange("192.168.1.0/24")
+	holes := []Range{}
+	for _, s := range []string{"192.168.1.10", "192.168.1.100-192.168.1.199", "192.168.1.240/28"} {
+		r, err := ParseRange(s)
+		if err != nil {
+			t.Fatalf("ParseRange(%s) failed: %v", s, err)
+		}
+		holes = append(holes, r)
+	}
+
+	remaining := Exclude(base, holes)
+	expected := []string{
+		"192.168.1.0-192.168.1.9",
+		"192.168.1.11-192.168.1.99",
+		"192.168.1.200-192.168.1.239",
+	}
+	if len(remaining) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, remaining)
+	}
+	for i, r := range remaining {
+		if r.String() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], r)
+		}
+	}
+}
+
+func TestRangeCIDRs(t *testing.T) {
+	r, err := ParseRange("10.0.0.1-10.0.0.6")
+	if err != nil {
+		t.Fatalf("ParseRange failed: %v", err)
+	}
+
+	expected := []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}
+	cidrs := r.CIDRs()
+	if len(cidrs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, cidrs)
+	}
+	for i, c := range cidrs {
+		if c.String() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], c)
+		}
+	}
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for _, s := range []string{"", "10.0.0.5-10.0.0.1", "10.0.0.1-fd00::1", "10.0.0.0/33", "not-an-ip"} {
+		if _, err := ParseRange(s); err == nil {
+			t.Errorf("Expected error parsing %q", s)
+		}
+	}
+}
+
+func TestServiceAddresses(t *testing.T) {
+	calc := NewCalculator()
+
+	apiIP, err := calc.KubernetesServiceIP("10.233.0.0/18")
+	if err != nil || apiIP != "10.233.0.1" {
+		t.Errorf("Expected API service IP 10.233.0.1, got %s (%v)", apiIP, err)
+	}
+
+	dnsIP, err := calc.ClusterDNSIP("10.233.0.0/18")
+	if err != nil || dnsIP != "10.233.0.3" {
+		t.Errorf("Expected cluster DNS IP 10.233.0.3, got %s (%v)", dnsIP, err)
+	}
+
+	dnsIP6, err := calc.ClusterDNSIP("fd85:ee78:d8a6:8607::1000/116")
+	if err != nil || dnsIP6 != "fd85:ee78:d8a6:8607::1003" {
+		t.Errorf("Expected IPv6 cluster DNS IP fd85:ee78:d8a6:8607::1003, got %s (%v)", dnsIP6, err)
+	}
+}
+
+func TestAllocateVIP(t *testing.T) {
+	calc := NewCalculator()
+
+	vip, err := calc.AllocateVIP("192.168.1.0/24", []string{"192.168.1.250-192.168.1.254"})
+	if err != nil {
+		t.Fatalf("AllocateVIP failed: %v", err)
+	}
+	if vip != "192.168.1.249" {
+		t.Errorf("Expected 192.168.1.249, got %s", vip)
+	}
+
+	if _, err := calc.AllocateVIP("192.168.1.0/30", []string{"192.168.1.1", "192.168.1.2"}); err == nil {
+		t.Error("Expected error when subnet is exhausted")
+	}
+}
+
+func TestAllocateRange(t *testing.T) {
+	calc := NewCalculator()
+
+	r, err := calc.AllocateRange("192.168.1.0/24", 20, []string{"192.168.1.0/26", "192.168.1.70"})
+	if err != nil {
+		t.Fatalf("AllocateRange failed: %v", err)
+	}
+	if r.String() != "192.168.1.71-192.168.1.90" {
+		t.Errorf("Expected 192.168.1.71-192.168.1.90, got %s", r)
+	}
+	if r.Size() != 20 {
+		t.Errorf("Expected 20 addresses, got %d", r.Size())
+	}
+
+	if _, err := calc.AllocateRange("192.168.1.0/28", 20, nil); err == nil {
+		t.Error("Expected error when range does not fit")
+	}
+}
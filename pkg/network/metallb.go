@@ -0,0 +1,181 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+// MetalLB announcement protocols
+const (
+	MetalLBLayer2 = "layer2"
+	MetalLBBGP    = "bgp"
+)
+
+// Pool is an allocated MetalLB address pool
+type Pool struct {
+	Name     string
+	Protocol string
+	Range    Range
+}
+
+// AllocatePools carves the configured MetalLB pools out of the pool range in
+// order. The range must not overlap the pod or service subnets; node
+// addresses, the API VIP and configured exclusions are skipped.
+func (c *Calculator) AllocatePools(cfg *config.Config, nodeIPs []string) ([]Pool, error) {
+	lb := cfg.MetalLB
+
+	base, err := ParseRange(lb.Range)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MetalLB range: %w", err)
+	}
+	if strings.Contains(lb.Range, "/") {
+		_, ipNet, _ := net.ParseCIDR(lb.Range)
+		base = HostRange(ipNet)
+	}
+
+	for _, cidr := range []string{cfg.Kubernetes.PodSubnet, cfg.Kubernetes.ServiceSubnet} {
+		if cidr == "" {
+			continue
+		}
+		cluster, err := ParseRange(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster subnet: %w", err)
+		}
+		if base.Overlaps(cluster) {
+			return nil, fmt.Errorf("MetalLB range %s overlaps cluster subnet %s", lb.Range, cidr)
+		}
+	}
+
+	reserved := append([]string{}, lb.Exclude...)
+	reserved = append(reserved, nodeIPs...)
+	if cfg.APIEndpoint.Address != "" {
+		reserved = append(reserved, cfg.APIEndpoint.Address)
+	}
+	excluded, err := parseRanges(reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	hasPeers := len(lb.Peers) > 0
+	names := map[string]bool{}
+	pools := make([]Pool, 0, len(lb.Pools))
+	for _, req := range lb.Pools {
+		if req.Name == "" {
+			return nil, fmt.Errorf("MetalLB pool name is required")
+		}
+		if names[req.Name] {
+			return nil, fmt.Errorf("duplicate MetalLB pool %s", req.Name)
+		}
+		names[req.Name] = true
+
+		protocol := req.Protocol
+		if protocol == "" {
+			protocol = MetalLBLayer2
+		}
+		if protocol != MetalLBLayer2 && protocol != MetalLBBGP {
+			return nil, fmt.Errorf("MetalLB pool %s: protocol must be %s or %s", req.Name, MetalLBLayer2, MetalLBBGP)
+		}
+		if protocol == MetalLBBGP && !hasPeers {
+			return nil, fmt.Errorf("MetalLB pool %s uses bgp but no peers are configured", req.Name)
+		}
+		if req.Size < 1 {
+			return nil, fmt.Errorf("MetalLB pool %s: size must be positive", req.Name)
+		}
+
+		r, ok := firstFree(base, req.Size, excluded)
+		if !ok {
+			return nil, fmt.Errorf("no free range of %d addresses for MetalLB pool %s in %s", req.Size, req.Name, lb.Range)
+		}
+		excluded = append(excluded, r)
+		pools = append(pools, Pool{Name: req.Name, Protocol: protocol, Range: r})
+	}
+
+	return pools, nil
+}
+
+// CheckPoolRoutes verifies a pool against routes gathered from a node. Layer2
+// pools must sit inside a directly connected subnet, and no pool may overlap
+// a destination that is routed through a gateway.
+func CheckPoolRoutes(pool Pool, routes []Route) error {
+	connected := false
+	for _, rt := range routes {
+		if rt.Destination == nil {
+			continue
+		}
+		dest := CIDRRange(rt.Destination)
+		if !dest.Overlaps(pool.Range) {
+			continue
+		}
+		if !rt.Connected {
+			return fmt.Errorf("pool %s (%s) overlaps %s routed via %s", pool.Name, pool.Range, rt.Destination, rt.Gateway)
+		}
+		if dest.Contains(pool.Range.Start) && dest.Contains(pool.Range.End) {
+			connected = true
+		}
+	}
+
+	if pool.Protocol == MetalLBLayer2 && !connected {
+		return fmt.Errorf("layer2 pool %s (%s) is not inside a directly connected subnet", pool.Name, pool.Range)
+	}
+	return nil
+}
+
+// MetalLBVars returns the Kubespray addon variables for the allocated pools
+func MetalLBVars(pools []Pool, peers []config.MetalLBPeer) map[string]interface{} {
+	addressPools := map[string]interface{}{}
+	layer2 := []string{}
+	bgp := []string{}
+
+	for _, p := range pools {
+		// MetalLB accepts "start-end" ranges but needs CIDR form for one address
+		ipRange := p.Range.String()
+		if p.Range.Start.Equal(p.Range.End) {
+			ipRange = p.Range.CIDRs()[0].String()
+		}
+		addressPools[p.Name] = map[string]interface{}{
+			"ip_range":    []string{ipRange},
+			"auto_assign": true,
+		}
+		if p.Protocol == MetalLBBGP {
+			bgp = append(bgp, p.Name)
+		} else {
+			layer2 = append(layer2, p.Name)
+		}
+	}
+
+	metallbConfig := map[string]interface{}{
+		"address_pools": addressPools,
+	}
+	if len(layer2) > 0 {
+		metallbConfig["layer2"] = layer2
+	}
+	if len(bgp) > 0 {
+		bgpPeers := map[string]interface{}{}
+		for _, peer := range peers {
+			bgpPeers[peer.Name] = map[string]interface{}{
+				"peer_address": peer.Address,
+				"peer_asn":     peer.ASN,
+				"my_asn":       peer.MyASN,
+				"address_pool": bgp,
+			}
+		}
+		metallbConfig["layer3"] = map[string]interface{}{
+			"defaults": map[string]interface{}{
+				"peer_port": 179,
+				"hold_time": "120s",
+			},
+			"metallb_peers": bgpPeers,
+		}
+	}
+
+	return map[string]interface{}{
+		"metallb_enabled":         true,
+		"metallb_speaker_enabled": true,
+		"metallb_namespace":       "metallb-system",
+		"kube_proxy_strict_arp":   true,
+		"metallb_config":          metallbConfig,
+	}
+}
@@ -0,0 +1,152 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+)
+
+func metalLBConfig() *config.Config {
+	cfg := config.NewConfig()
+	cfg.MetalLB = config.MetalLBConfig{
+		Enabled: true,
+		Range:   "192.168.1.0/24",
+		Exclude: []string{"192.168.1.1-192.168.1.99"},
+		Pools: []config.MetalLBPool{
+			{Name: "primary", Size: 20},
+			{Name: "secondary", Size: 8},
+		},
+	}
+	return cfg
+}
+
+func TestAllocatePools(t *testing.T) {
+	calc := NewCalculator()
+	cfg := metalLBConfig()
+	cfg.APIEndpoint.Address = "192.168.1.110"
+
+	pools, err := calc.AllocatePools(cfg, []string{"192.168.1.100", "192.168.1.101"})
+	if err != nil {
+		t.Fatalf("AllocatePools failed: %v", err)
+	}
+
+	expected := []string{"192.168.1.111-192.168.1.130", "192.168.1.102-192.168.1.109"}
+	if len(pools) != len(expected) {
+		t.Fatalf("Expected %d pools, got %d", len(expected), len(pools))
+	}
+	for i, pool := range pools {
+		if pool.Range.String() != expected[i] {
+			t.Errorf("Pool %s: expected %s, got %s", pool.Name, expected[i], pool.Range)
+		}
+		if pool.Protocol != MetalLBLayer2 {
+			t.Errorf("Pool %s: expected layer2 protocol, got %s", pool.Name, pool.Protocol)
+		}
+	}
+
+	// Allocation is deterministic across runs
+	again, _ := calc.AllocatePools(cfg, []string{"192.168.1.100", "192.168.1.101"})
+	for i := range pools {
+		if pools[i].Range.String() != again[i].Range.String() {
+			t.Errorf("Pool %s allocation changed between runs", pools[i].Name)
+		}
+	}
+}
+
+func TestAllocatePoolsErrors(t *testing.T) {
+	calc := NewCalculator()
+
+	tests := []struct {
+		name   string
+		modify func(*config.Config)
+	}{
+		{"Overlaps pod subnet", func(cfg *config.Config) { cfg.MetalLB.Range = "10.233.64.0/24" }},
+		{"Invalid range", func(cfg *config.Config) { cfg.MetalLB.Range = "not-a-range" }},
+		{"Pool too large", func(cfg *config.Config) { cfg.MetalLB.Pools[0].Size = 200 }},
+		{"Duplicate pool", func(cfg *config.Config) { cfg.MetalLB.Pools[1].Name = "primary" }},
+		{"BGP without peers", func(cfg *config.Config) { cfg.MetalLB.Pools[0].Protocol = MetalLBBGP }},
+		{"Unknown protocol", func(cfg *config.Config) { cfg.MetalLB.Pools[0].Protocol = "ospf" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := metalLBConfig()
+			tt.modify(cfg)
+			if _, err := calc.AllocatePools(cfg, nil); err == nil {
+				t.Error("Expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestCheckPoolRoutes(t *testing.T) {
+	routes := ParseRoutes(`default via 192.168.1.1 dev eth0 proto dhcp src 192.168.1.10 metric 100
+192.168.1.0/24 dev eth0 proto kernel scope link src 192.168.1.10
+10.20.0.0/16 via 192.168.1.254 dev eth0
+blackhole 10.233.100.0/24 proto bird
+`)
+	if len(routes) != 4 {
+		t.Fatalf("Expected 4 routes, got %d", len(routes))
+	}
+
+	pool := func(r, protocol string) Pool {
+		rng, err := ParseRange(r)
+		if err != nil {
+			t.Fatalf("ParseRange(%s) failed: %v", r, err)
+		}
+		return Pool{Name: "test", Protocol: protocol, Range: rng}
+	}
+
+	tests := []struct {
+		name      string
+		pool      Pool
+		shouldErr bool
+	}{
+		{"Layer2 in connected subnet", pool("192.168.1.200-192.168.1.220", MetalLBLayer2), false},
+		{"Layer2 outside connected subnet", pool("172.16.0.10-172.16.0.20", MetalLBLayer2), true},
+		{"BGP outside connected subnet", pool("172.16.0.10-172.16.0.20", MetalLBBGP), false},
+		{"Overlaps gateway route", pool("10.20.1.0/28", MetalLBBGP), true},
+		{"Overlaps blackhole route", pool("10.233.100.10-10.233.100.20", MetalLBBGP), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPoolRoutes(tt.pool, routes)
+			if tt.shouldErr && err == nil {
+				t.Error("Expected error but got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("Expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestMetalLBVars(t *testing.T) {
+	primary, _ := ParseRange("192.168.1.200-192.168.1.219")
+	single, _ := ParseRange("192.168.1.250")
+	pools := []Pool{
+		{Name: "primary", Protocol: MetalLBLayer2, Range: primary},
+		{Name: "edge", Protocol: MetalLBBGP, Range: single},
+	}
+	peers := []config.MetalLBPeer{{Name: "tor", Address: "192.168.1.1", ASN: 64512, MyASN: 64513}}
+
+	vars := MetalLBVars(pools, peers)
+	if vars["metallb_enabled"] != true {
+		t.Error("Expected metallb_enabled to be true")
+	}
+
+	cfg := vars["metallb_config"].(map[string]interface{})
+	addressPools := cfg["address_pools"].(map[string]interface{})
+	edge := addressPools["edge"].(map[string]interface{})
+	if r := edge["ip_range"].([]string); len(r) != 1 || r[0] != "192.168.1.250/32" {
+		t.Errorf("Expected single address as /32, got %v", edge["ip_range"])
+	}
+
+	if l2 := cfg["layer2"].([]string); len(l2) != 1 || l2[0] != "primary" {
+		t.Errorf("Expected layer2 pools [primary], got %v", cfg["layer2"])
+	}
+	layer3 := cfg["layer3"].(map[string]interface{})
+	if _, ok := layer3["metallb_peers"].(map[string]interface{})["tor"]; !ok {
+		t.Error("Expected BGP peer tor in layer3 config")
+	}
+}
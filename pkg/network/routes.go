@@ -0,0 +1,114 @@
+package network
+
+import (
+	"net"
+	"strings"
+)
+
+// Route is an entry of a host routing table
+type Route struct {
+	// Destination is nil for the default route
+	Destination *net.IPNet
+	Gateway     string
+	Device      string
+	// Connected is true for on-link routes without a gateway
+	Connected bool
+}
+
+// routeTypes are `ip route` type keywords that may precede the destination
+var routeTypes = map[string]bool{
+	"unicast": true, "local": true, "broadcast": true, "multicast": true,
+	"blackhole": true, "unreachable": true, "prohibit": true, "throw": true,
+}
+
+// ParseRoutes parses the output of `ip route show` (IPv4 or IPv6). Lines that
+// cannot be parsed are skipped.
+func ParseRoutes(output string) []Route {
+	routes := []Route{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		routeType := ""
+		if routeTypes[fields[0]] {
+			routeType = fields[0]
+			fields = fields[1:]
+			if len(fields) == 0 {
+				continue
+			}
+		}
+
+		rt := Route{}
+		if fields[0] != "default" {
+			dest := fields[0]
+			if !strings.Contains(dest, "/") {
+				if ip := net.ParseIP(dest); ip != nil && ip.To4() != nil {
+					dest += "/32"
+				} else {
+					dest += "/128"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(dest)
+			if err != nil {
+				continue
+			}
+			rt.Destination = ipNet
+		}
+
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				rt.Gateway = fields[i+1]
+			case "dev":
+				rt.Device = fields[i+1]
+			}
+		}
+
+		switch {
+		case routeType != "" && routeType != "unicast":
+			rt.Gateway = routeType
+		case rt.Gateway == "" && rt.Device != "":
+			rt.Connected = true
+		}
+
+		routes = append(routes, rt)
+	}
+
+	return routes
+}
+
+// InterfaceAddress is an address configured on a host interface
+type InterfaceAddress struct {
+	Interface string
+	IP        net.IP
+	Network   *net.IPNet
+}
+
+// ParseAddresses parses the output of `ip -o addr show` (IPv4 and IPv6).
+// Lines that cannot be parsed are skipped.
+func ParseAddresses(output string) []InterfaceAddress {
+	addrs := []InterfaceAddress{}
+
+	for _, line := range strings.Split(output, "\n") {
+		// 2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+
+		ip, ipNet, err := net.ParseCIDR(fields[3])
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, InterfaceAddress{
+			Interface: strings.TrimSuffix(fields[1], ":"),
+			IP:        ip,
+			Network:   ipNet,
+		})
+	}
+
+	return addrs
+}
@@ -0,0 +1,34 @@
+package network
+
+import "testing"
+
+func TestParseAddresses(t *testing.T) {
+	addrs := ParseAddresses(`1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+1: lo    inet6 ::1/128 scope host \       valid_lft forever preferred_lft forever
+2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet6 fe80::1/64 scope link \       valid_lft forever preferred_lft forever
+3: vlan10@eth0: <BROADCAST,MULTICAST,UP> mtu 1500
+4: wg0    inet bogus scope global wg0
+`)
+
+	expected := []struct {
+		iface   string
+		ip      string
+		network string
+	}{
+		{"lo", "127.0.0.1", "127.0.0.0/8"},
+		{"lo", "::1", "::1/128"},
+		{"eth0", "10.0.0.5", "10.0.0.0/24"},
+		{"eth0", "fe80::1", "fe80::/64"},
+	}
+
+	if len(addrs) != len(expected) {
+		t.Fatalf("Expected %d addresses, got %d: %v", len(expected), len(addrs), addrs)
+	}
+	for i, e := range expected {
+		a := addrs[i]
+		if a.Interface != e.iface || a.IP.String() != e.ip || a.Network.String() != e.network {
+			t.Errorf("Address %d: expected %s %s %s, got %s %s %s", i, e.iface, e.ip, e.network, a.Interface, a.IP, a.Network)
+		}
+	}
+}
@@ -91,10 +91,21 @@ func TestSubnetSplit(t *testing.T) {
 				t.Skip("Invalid test: new prefix must be larger than parent")
 			}
 			
-			// Calculate expected number of subnets
-			expectedCount := 1 << (tt.newPrefix - parentPrefix)
-			if expectedCount != tt.expectedNets {
-				t.Errorf("Expected %d subnets, calculated %d", tt.expectedNets, expectedCount)
+			subnets, err := Split(parent, tt.expectedNets)
+			if err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+			if len(subnets) != tt.expectedNets {
+				t.Fatalf("Expected %d subnets, got %d", tt.expectedNets, len(subnets))
+			}
+			for _, subnet := range subnets {
+				ones, _ := subnet.Mask.Size()
+				if ones != tt.newPrefix {
+					t.Errorf("Expected /%d subnet, got %s", tt.newPrefix, subnet.String())
+				}
+				if !parent.Contains(subnet.IP) {
+					t.Errorf("Subnet %s is outside %s", subnet.String(), tt.parentCIDR)
+				}
 			}
 		})
 	}
@@ -125,6 +136,12 @@ func TestIPIncrement(t *testing.T) {
 			count:    1,
 			expected: "192.168.2.0",
 		},
+		{
+			name:     "IPv6 increment across boundary",
+			startIP:  "fd00::ffff",
+			count:    1,
+			expected: "fd00::1:0",
+		},
 	}
 	
 	for _, tt := range tests {
@@ -134,23 +151,7 @@ func TestIPIncrement(t *testing.T) {
 				t.Fatalf("Invalid start IP: %s", tt.startIP)
 			}
 			
-			// Convert to 4-byte representation
-			ip = ip.To4()
-			if ip == nil {
-				t.Fatal("Not an IPv4 address")
-			}
-			
-			// Increment IP
-			for i := 0; i < tt.count; i++ {
-				for j := len(ip) - 1; j >= 0; j-- {
-					ip[j]++
-					if ip[j] != 0 {
-						break
-					}
-				}
-			}
-			
-			result := ip.String()
+			result := AddIP(ip, int64(tt.count)).String()
 			if result != tt.expected {
 				t.Errorf("Expected %s, got %s", tt.expected, result)
 			}
@@ -11,6 +11,10 @@ import (
 	"time"
 
 	"golang.org/x/crypto/ssh"
+
+	"github.com/vjranagit/kubespray/pkg/config"
+	"github.com/vjranagit/kubespray/pkg/inventory"
+	"github.com/vjranagit/kubespray/pkg/network"
 )
 
 // CheckResult represents the result of a preflight check
@@ -28,6 +32,7 @@ type Checker struct {
 	sshKeyPath string
 	sshPort    int
 	timeout    time.Duration
+	cluster    *config.Config
 }
 
 // NewChecker creates a new preflight checker
@@ -41,6 +46,12 @@ func NewChecker(hosts []string, user, keyPath string, port int) *Checker {
 	}
 }
 
+// WithConfig enables cluster configuration checks such as pod CIDR capacity
+func (c *Checker) WithConfig(cfg *config.Config) *Checker {
+	c.cluster = cfg
+	return c
+}
+
 // RunAll executes all preflight checks
 func (c *Checker) RunAll(ctx context.Context) ([]CheckResult, error) {
 	results := []CheckResult{}
@@ -61,6 +72,16 @@ func (c *Checker) RunAll(ctx context.Context) ([]CheckResult, error) {
 	k8sResult := c.CheckKubernetesVersion()
 	results = append(results, k8sResult)
 
+	// Check cluster network settings when configuration is available
+	if c.cluster != nil {
+		results = append(results, c.CheckPodCapacity())
+		results = append(results, c.CheckAPIEndpoint(ctx))
+		results = append(results, c.CheckNetworkPlugin(ctx)...)
+		if c.cluster.MetalLB.Enabled {
+			results = append(results, c.CheckMetalLBPools(ctx)...)
+		}
+	}
+
 	return results, nil
 }
 
@@ -228,20 +249,383 @@ func (c *Checker) CheckKubernetesVersion() CheckResult {
 	return result
 }
 
+// CheckPodCapacity validates the pod subnet can hold every host with the
+// configured per-node prefix
+func (c *Checker) CheckPodCapacity() CheckResult {
+	result := CheckResult{
+		Name:    "Pod Network Capacity",
+		Details: make(map[string]interface{}),
+	}
+
+	if c.cluster == nil {
+		result.Passed = false
+		result.Message = "No cluster configuration provided"
+		return result
+	}
+
+	k8s := c.cluster.Kubernetes
+	plan, err := network.NewCalculator().PlanCapacity(network.CapacityInput{
+		PodSubnet:  k8s.PodSubnet,
+		NodePrefix: k8s.NodePrefix,
+		MaxPods:    k8s.MaxPods,
+		Nodes:      len(c.hosts),
+	})
+	if err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Details["max_nodes"] = plan.MaxNodes
+	result.Details["pods_per_node"] = plan.PodsPerNode
+	if !plan.Fits() {
+		result.Passed = false
+		result.Message = strings.Join(plan.Warnings, "; ")
+		return result
+	}
+
+	result.Passed = true
+	result.Message = fmt.Sprintf("%d nodes of capacity %d, %d pods per node", len(c.hosts), plan.MaxNodes, plan.PodsPerNode)
+	return result
+}
+
+// CheckAPIEndpoint validates the API endpoint configuration against the
+// control plane hosts of the cluster inventory and, for kube-vip, probes from
+// the first of them that the VIP is not already answering ping or ARP
+func (c *Checker) CheckAPIEndpoint(ctx context.Context) CheckResult {
+	result := CheckResult{
+		Name:    "API Endpoint",
+		Details: make(map[string]interface{}),
+	}
+
+	if c.cluster == nil {
+		result.Passed = false
+		result.Message = "No cluster configuration provided"
+		return result
+	}
+
+	ep := c.cluster.APIEndpoint
+	result.Details["mode"] = ep.Mode
+
+	masters := c.controlPlaneHosts()
+	ips := make([]string, 0, len(masters))
+	for _, h := range masters {
+		ips = append(ips, h.IP)
+	}
+	if err := network.NewCalculator().ValidateAPIEndpoint(ep, ips); err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		return result
+	}
+
+	if ep.Mode != network.EndpointKubeVIP || len(masters) == 0 {
+		result.Passed = true
+		result.Message = fmt.Sprintf("API endpoint mode %s is valid", ep.Mode)
+		return result
+	}
+
+	result.Details["probe_host"] = masters[0].Address
+	client, err := c.dial(inventory.Connection{Address: masters[0].Address, User: masters[0].User})
+	if err != nil {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Cannot connect to probe VIP: %v", err)
+		return result
+	}
+	defer client.Close()
+
+	// ping may be filtered, so also look for a resolved ARP/NDP entry
+	probeCmd := fmt.Sprintf("ping -c 2 -W 1 %s >/dev/null 2>&1 && echo vip-replied; ip neigh show %s", ep.Address, ep.Address)
+	output, _ := c.runSSHCommand(client, probeCmd)
+	if strings.Contains(output, "vip-replied") || strings.Contains(output, "lladdr") {
+		result.Passed = false
+		result.Message = fmt.Sprintf("VIP %s is already in use on the network", ep.Address)
+		result.Details["probe_output"] = strings.TrimSpace(output)
+		return result
+	}
+
+	result.Passed = true
+	result.Message = fmt.Sprintf("VIP %s is free", ep.Address)
+	return result
+}
+
+// controlPlaneHosts returns the control plane hosts of the cluster inventory,
+// with IP defaulting to the address
+func (c *Checker) controlPlaneHosts() []inventory.Host {
+	inv := inventory.FromConfig(c.cluster)
+	hosts := []inventory.Host{}
+	for _, addr := range inv.Masters {
+		hosts = append(hosts, inventory.Host{Address: addr, IP: addr})
+	}
+	for _, h := range inv.Hosts {
+		if !h.HasRole(inventory.RoleControlPlane) {
+			continue
+		}
+		if h.IP == "" {
+			h.IP = h.Address
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// CheckNetworkPlugin validates the configured CNI plugin against the cluster
+// subnets and checks each host's kernel version and required modules
+func (c *Checker) CheckNetworkPlugin(ctx context.Context) []CheckResult {
+	results := []CheckResult{}
+
+	result := CheckResult{
+		Name:    "Network Plugin",
+		Details: make(map[string]interface{}),
+	}
+
+	if c.cluster == nil {
+		result.Passed = false
+		result.Message = "No cluster configuration provided"
+		return append(results, result)
+	}
+
+	k8s := c.cluster.Kubernetes
+	if err := network.NewCalculator().ValidateCNI(k8s.NetworkPlugin, k8s.PodSubnet, k8s.ServiceSubnet, 0); err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		return append(results, result)
+	}
+
+	plugin, _ := network.LookupCNI(k8s.NetworkPlugin)
+	ports := make([]string, 0, len(plugin.Ports))
+	for _, p := range plugin.Ports {
+		ports = append(ports, p.String())
+	}
+	result.Details["required_ports"] = ports
+	result.Passed = true
+	result.Message = fmt.Sprintf("%s supports the cluster subnets", plugin.Name)
+	results = append(results, result)
+
+	for _, host := range c.hosts {
+		hostResult := CheckResult{
+			Name:    fmt.Sprintf("Network Plugin Kernel - %s", host),
+			Details: make(map[string]interface{}),
+		}
+
+		client, err := c.sshConnect(host)
+		if err != nil {
+			hostResult.Passed = false
+			hostResult.Message = fmt.Sprintf("Cannot connect: %v", err)
+			results = append(results, hostResult)
+			continue
+		}
+
+		checkKernel(&hostResult, plugin, func(command string) (string, error) {
+			return c.runSSHCommand(client, command)
+		})
+		client.Close()
+		results = append(results, hostResult)
+	}
+
+	return results
+}
+
+// checkKernel checks the kernel release and modules of a host against a
+// network plugin, running commands on the host with run
+func checkKernel(result *CheckResult, plugin network.CNIPlugin, run func(command string) (string, error)) {
+	release, err := run("uname -r")
+	if err != nil {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Cannot read kernel release: %v", err)
+		return
+	}
+	release = strings.TrimSpace(release)
+	result.Details["kernel"] = release
+	if !plugin.KernelSupported(release) {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Kernel %s is older than %s required by %s", release, plugin.MinKernel, plugin.Name)
+		return
+	}
+
+	missing := []string{}
+	for _, module := range plugin.KernelModules {
+		if _, err := run(fmt.Sprintf("modinfo -n %s || grep -qw %s /proc/modules", module, module)); err != nil {
+			missing = append(missing, module)
+		}
+	}
+	if len(missing) > 0 {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Missing kernel modules for %s: %s", plugin.Name, strings.Join(missing, ", "))
+		return
+	}
+
+	result.Passed = true
+	result.Message = fmt.Sprintf("Kernel meets %s requirements", plugin.Name)
+}
+
+// GatherRoutes collects the IPv4 and IPv6 routing tables of every host
+func (c *Checker) GatherRoutes(ctx context.Context) (map[string][]network.Route, error) {
+	routes := make(map[string][]network.Route)
+
+	for _, host := range c.hosts {
+		hostRoutes, err := c.hostRoutes(host)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", host, err)
+		}
+		routes[host] = hostRoutes
+	}
+
+	return routes, nil
+}
+
+// hostRoutes reads the IPv4 and IPv6 routing tables of a host
+func (c *Checker) hostRoutes(host string) ([]network.Route, error) {
+	client, err := c.sshConnect(host)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect: %w", err)
+	}
+	defer client.Close()
+
+	output, err := c.runSSHCommand(client, "ip -4 route show; ip -6 route show")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read routes: %w", err)
+	}
+
+	return network.ParseRoutes(output), nil
+}
+
+// CheckMetalLBPools allocates the configured MetalLB pools and checks them
+// against the routing table of each host
+func (c *Checker) CheckMetalLBPools(ctx context.Context) []CheckResult {
+	results := []CheckResult{}
+
+	result := CheckResult{
+		Name:    "MetalLB Address Pools",
+		Details: make(map[string]interface{}),
+	}
+
+	if c.cluster == nil {
+		result.Passed = false
+		result.Message = "No cluster configuration provided"
+		return append(results, result)
+	}
+
+	pools, err := network.NewCalculator().AllocatePools(c.cluster, c.hosts)
+	if err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		return append(results, result)
+	}
+
+	for _, pool := range pools {
+		result.Details[pool.Name] = pool.Range.String()
+	}
+	result.Passed = true
+	result.Message = fmt.Sprintf("Allocated %d pools", len(pools))
+	results = append(results, result)
+
+	for _, host := range c.hosts {
+		hostResult := CheckResult{
+			Name:    fmt.Sprintf("MetalLB Routes - %s", host),
+			Details: make(map[string]interface{}),
+		}
+
+		routes, err := c.hostRoutes(host)
+		if err != nil {
+			hostResult.Passed = false
+			hostResult.Message = err.Error()
+			results = append(results, hostResult)
+			continue
+		}
+
+		hostResult.Passed = true
+		hostResult.Message = "Pools do not conflict with host routes"
+		for _, pool := range pools {
+			if err := network.CheckPoolRoutes(pool, routes); err != nil {
+				hostResult.Passed = false
+				hostResult.Message = err.Error()
+				break
+			}
+		}
+
+		results = append(results, hostResult)
+	}
+
+	return results
+}
+
+// Hostname returns the hostname a host reports over SSH
+func (c *Checker) Hostname(address string) (string, error) {
+	client, err := c.sshConnect(address)
+	if err != nil {
+		return "", fmt.Errorf("cannot connect: %w", err)
+	}
+	defer client.Close()
+
+	output, err := c.runSSHCommand(client, "hostname")
+	if err != nil {
+		return "", fmt.Errorf("cannot read hostname: %w", err)
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+// Probe reads the hostname and the interface addresses of a host over one
+// SSH connection, with the user, port and key the inventory sets for it
+func (c *Checker) Probe(conn inventory.Connection) (inventory.HostFacts, error) {
+	client, err := c.dial(conn)
+	if err != nil {
+		return inventory.HostFacts{}, err
+	}
+	defer client.Close()
+
+	hostname, err := c.runSSHCommand(client, "hostname")
+	if err != nil {
+		return inventory.HostFacts{}, &inventory.ProbeError{Stage: inventory.ProbeCommand, Err: fmt.Errorf("cannot read hostname: %w", err)}
+	}
+	output, err := c.runSSHCommand(client, "ip -o addr show")
+	if err != nil {
+		return inventory.HostFacts{}, &inventory.ProbeError{Stage: inventory.ProbeCommand, Err: fmt.Errorf("cannot read addresses: %w", err)}
+	}
+
+	facts := inventory.HostFacts{Hostname: strings.TrimSpace(hostname), Addresses: []string{}}
+	for _, a := range network.ParseAddresses(output) {
+		facts.Addresses = append(facts.Addresses, a.IP.String())
+	}
+	return facts, nil
+}
+
 // sshConnect establishes SSH connection to a host
 func (c *Checker) sshConnect(host string) (*ssh.Client, error) {
-	key, err := exec.Command("cat", c.sshKeyPath).Output()
+	return c.dial(inventory.Connection{Address: host})
+}
+
+// dial connects and logs in to a host over SSH. The user, port and key of
+// conn override those of the checker. Errors are *inventory.ProbeError, so
+// a host that cannot be reached is told apart from a failed login.
+func (c *Checker) dial(conn inventory.Connection) (*ssh.Client, error) {
+	user, port, keyPath := c.sshUser, c.sshPort, c.sshKeyPath
+	if conn.User != "" {
+		user = conn.User
+	}
+	if conn.Port > 0 {
+		port = conn.Port
+	}
+	if conn.KeyPath != "" {
+		keyPath = conn.KeyPath
+	}
+
+	addr := net.JoinHostPort(conn.Address, strconv.Itoa(port))
+	tcp, err := net.DialTimeout("tcp", addr, c.timeout)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read SSH key: %w", err)
+		return nil, &inventory.ProbeError{Stage: inventory.ProbeConnect, Err: fmt.Errorf("SSH dial failed: %w", err)}
 	}
 
-	signer, err := ssh.ParsePrivateKey(key)
+	// The key is only loaded once the host is known to be reachable, so an
+	// unreachable host is not reported as a login failure
+	signer, err := loadKey(keyPath)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse SSH key: %w", err)
+		tcp.Close()
+		return nil, &inventory.ProbeError{Stage: inventory.ProbeLogin, Err: err}
 	}
 
 	config := &ssh.ClientConfig{
-		User: c.sshUser,
+		User: user,
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeys(signer),
 		},
@@ -249,13 +633,30 @@ func (c *Checker) sshConnect(host string) (*ssh.Client, error) {
 		Timeout:         c.timeout,
 	}
 
-	addr := net.JoinHostPort(host, strconv.Itoa(c.sshPort))
-	client, err := ssh.Dial("tcp", addr, config)
+	// The handshake and login share the connect timeout
+	tcp.SetDeadline(time.Now().Add(c.timeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(tcp, addr, config)
+	if err != nil {
+		tcp.Close()
+		return nil, &inventory.ProbeError{Stage: inventory.ProbeLogin, Err: fmt.Errorf("SSH login as %s failed: %w", user, err)}
+	}
+	tcp.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// loadKey reads and parses a private SSH key
+func loadKey(path string) (ssh.Signer, error) {
+	key, err := exec.Command("cat", path).Output()
 	if err != nil {
-		return nil, fmt.Errorf("SSH dial failed: %w", err)
+		return nil, fmt.Errorf("cannot read SSH key %s: %w", path, err)
 	}
 
-	return client, nil
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse SSH key %s: %w", path, err)
+	}
+	return signer, nil
 }
 
 // runSSHCommand executes a command on remote host via SSH
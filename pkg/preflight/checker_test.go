@@ -0,0 +1,54 @@
+package preflight
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vjranagit/kubespray/pkg/network"
+)
+
+func TestCheckKernel(t *testing.T) {
+	calico, err := network.LookupCNI("calico")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		release  string
+		unameErr bool
+		missing  string
+		passed   bool
+		message  string
+	}{
+		{"Supported kernel", "5.15.0-91-generic\n", false, "", true, "Kernel meets calico requirements"},
+		{"Old kernel", "3.2.0\n", false, "", false, "Kernel 3.2.0 is older than 3.10 required by calico"},
+		{"Missing module", "5.15.0\n", false, "vxlan", false, "Missing kernel modules for calico: vxlan"},
+		{"uname fails", "", true, "", false, "Cannot read kernel release: uname: command not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CheckResult{Details: make(map[string]interface{})}
+			checkKernel(&result, calico, func(command string) (string, error) {
+				switch {
+				case command == "uname -r" && tt.unameErr:
+					return "", fmt.Errorf("uname: command not found")
+				case command == "uname -r":
+					return tt.release, nil
+				case tt.missing != "" && strings.Contains(command, tt.missing):
+					return "", fmt.Errorf("exit status 1")
+				}
+				return "", nil
+			})
+
+			if result.Passed != tt.passed || result.Message != tt.message {
+				t.Errorf("Expected passed=%v %q, got passed=%v %q", tt.passed, tt.message, result.Passed, result.Message)
+			}
+			if _, ok := result.Details["kernel"]; ok == tt.unameErr {
+				t.Errorf("Unexpected kernel detail %v", result.Details["kernel"])
+			}
+		})
+	}
+}